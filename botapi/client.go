@@ -0,0 +1,277 @@
+package botapi
+
+import (
+	"bot/telegram/structs"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// How many times a message is resent after Telegram answers 429.
+const maxFloodRetries = 3
+
+// Client talks to the Telegram Bot API for a single bot token.
+type Client struct {
+	Token      string
+	BaseURL    string
+	HTTPClient *http.Client
+	// Limiter throttles methods that post into a chat. Nil disables throttling.
+	Limiter *RateLimiter
+}
+
+func NewClient(baseURL string, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		Token:      token,
+		BaseURL:    baseURL,
+		HTTPClient: httpClient,
+	}
+}
+
+type apiResponse struct {
+	OK          bool                `json:"ok"`
+	Result      json.RawMessage     `json:"result"`
+	ErrorCode   int                 `json:"error_code"`
+	Description string              `json:"description"`
+	Parameters  *responseParameters `json:"parameters"`
+}
+
+type responseParameters struct {
+	MigrateToChatID int64 `json:"migrate_to_chat_id"`
+	RetryAfter      int   `json:"retry_after"`
+}
+
+// Call posts params as JSON to the given Bot API method and decodes the result
+// into result, which may be nil when the caller does not need it.
+func (c *Client) Call(ctx context.Context, method string, params any, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("encode %s request: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.methodURL(method), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram API %s request failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read %s response: %w", method, err)
+	}
+
+	var decoded apiResponse
+	if err := json.Unmarshal(responseBody, &decoded); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			// Proxies and outages answer with HTML; keep the status so callers
+			// can still branch on it.
+			return &APIError{
+				Method:      method,
+				ErrorCode:   resp.StatusCode,
+				Description: strings.TrimSpace(string(responseBody)),
+			}
+		}
+
+		return fmt.Errorf("parse %s response: %w", method, err)
+	}
+
+	if !decoded.OK {
+		apiErr := &APIError{
+			Method:      method,
+			ErrorCode:   decoded.ErrorCode,
+			Description: decoded.Description,
+		}
+		if apiErr.ErrorCode == 0 {
+			apiErr.ErrorCode = resp.StatusCode
+		}
+		if decoded.Parameters != nil {
+			apiErr.RetryAfter = decoded.Parameters.RetryAfter
+			apiErr.MigrateToChatID = decoded.Parameters.MigrateToChatID
+		}
+
+		return apiErr
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(decoded.Result, result); err != nil {
+		return fmt.Errorf("parse %s result: %w", method, err)
+	}
+
+	return nil
+}
+
+// callChat is Call for methods that post into chatID: it waits for a send slot
+// and, when Telegram still answers 429, pauses the chat for retry_after and
+// tries again.
+func (c *Client) callChat(ctx context.Context, chatID int64, method string, params any, result any) error {
+	for attempt := 0; ; attempt++ {
+		if c.Limiter != nil {
+			if err := c.Limiter.Wait(ctx, chatID); err != nil {
+				return err
+			}
+		}
+
+		err := c.Call(ctx, method, params, result)
+		if !IsTooManyRequests(err) || attempt >= maxFloodRetries {
+			return err
+		}
+
+		apiErr, _ := AsAPIError(err)
+		wait := apiErr.RetryAfterDuration()
+		if wait <= 0 {
+			wait = time.Second
+		}
+
+		if c.Limiter != nil {
+			c.Limiter.Pause(chatID, wait)
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) methodURL(method string) string {
+	return c.BaseURL + c.Token + "/" + method
+}
+
+type GetUpdatesParams struct {
+	Offset         int      `json:"offset,omitempty"`
+	Limit          int      `json:"limit,omitempty"`
+	Timeout        int      `json:"timeout,omitempty"`
+	AllowedUpdates []string `json:"allowed_updates,omitempty"`
+}
+
+func (c *Client) GetUpdates(ctx context.Context, params GetUpdatesParams) ([]structs.Update, error) {
+	var updates []structs.Update
+	if err := c.Call(ctx, "getUpdates", params, &updates); err != nil {
+		return nil, err
+	}
+
+	return updates, nil
+}
+
+func (c *Client) GetMe(ctx context.Context) (*structs.User, error) {
+	var user structs.User
+	if err := c.Call(ctx, "getMe", struct{}{}, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+type SendMessageParams struct {
+	ChatID                int64                         `json:"chat_id"`
+	MessageThreadID       int                           `json:"message_thread_id,omitempty"`
+	Text                  string                        `json:"text"`
+	ParseMode             string                        `json:"parse_mode,omitempty"`
+	ReplyToMessageID      int64                         `json:"reply_to_message_id,omitempty"`
+	DisableWebPagePreview bool                          `json:"disable_web_page_preview,omitempty"`
+	ReplyMarkup           *structs.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+func (c *Client) SendMessage(ctx context.Context, params SendMessageParams) (*structs.Message, error) {
+	var message structs.Message
+	if err := c.callChat(ctx, params.ChatID, "sendMessage", params, &message); err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+type SetMyCommandsParams struct {
+	Commands     []structs.BotCommand     `json:"commands"`
+	Scope        *structs.BotCommandScope `json:"scope,omitempty"`
+	LanguageCode string                   `json:"language_code,omitempty"`
+}
+
+func (c *Client) SetMyCommands(ctx context.Context, params SetMyCommandsParams) error {
+	return c.Call(ctx, "setMyCommands", params, nil)
+}
+
+type getChatAdministratorsParams struct {
+	ChatID int64 `json:"chat_id"`
+}
+
+func (c *Client) GetChatAdministrators(ctx context.Context, chatID int64) ([]structs.ChatMember, error) {
+	var members []structs.ChatMember
+	if err := c.Call(ctx, "getChatAdministrators", getChatAdministratorsParams{ChatID: chatID}, &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+type SetWebhookParams struct {
+	URL            string   `json:"url"`
+	SecretToken    string   `json:"secret_token,omitempty"`
+	AllowedUpdates []string `json:"allowed_updates,omitempty"`
+}
+
+func (c *Client) SetWebhook(ctx context.Context, params SetWebhookParams) error {
+	return c.Call(ctx, "setWebhook", params, nil)
+}
+
+type DeleteWebhookParams struct {
+	DropPendingUpdates bool `json:"drop_pending_updates"`
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, params DeleteWebhookParams) error {
+	return c.Call(ctx, "deleteWebhook", params, nil)
+}
+
+type AnswerCallbackQueryParams struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`
+	ShowAlert       bool   `json:"show_alert,omitempty"`
+}
+
+func (c *Client) AnswerCallbackQuery(ctx context.Context, params AnswerCallbackQueryParams) error {
+	return c.Call(ctx, "answerCallbackQuery", params, nil)
+}
+
+type EditMessageTextParams struct {
+	ChatID                int64                         `json:"chat_id"`
+	MessageID             int                           `json:"message_id"`
+	Text                  string                        `json:"text"`
+	ParseMode             string                        `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool                          `json:"disable_web_page_preview,omitempty"`
+	ReplyMarkup           *structs.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+func (c *Client) EditMessageText(ctx context.Context, params EditMessageTextParams) error {
+	return c.callChat(ctx, params.ChatID, "editMessageText", params, nil)
+}
+
+type EditMessageReplyMarkupParams struct {
+	ChatID      int64                         `json:"chat_id"`
+	MessageID   int                           `json:"message_id"`
+	ReplyMarkup *structs.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+func (c *Client) EditMessageReplyMarkup(ctx context.Context, params EditMessageReplyMarkupParams) error {
+	return c.callChat(ctx, params.ChatID, "editMessageReplyMarkup", params, nil)
+}
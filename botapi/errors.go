@@ -0,0 +1,83 @@
+package botapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is the decoded body of a Telegram response with "ok": false.
+type APIError struct {
+	Method          string
+	ErrorCode       int
+	Description     string
+	RetryAfter      int
+	MigrateToChatID int64
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram API %s failed with error_code %d: %s", e.Method, e.ErrorCode, e.Description)
+}
+
+// RetryAfterDuration is how long Telegram asked us to wait before retrying.
+func (e *APIError) RetryAfterDuration() time.Duration {
+	return time.Duration(e.RetryAfter) * time.Second
+}
+
+// AsAPIError unwraps err into an *APIError when it carries one.
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+
+	return nil, false
+}
+
+func IsTooManyRequests(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.ErrorCode == http.StatusTooManyRequests
+}
+
+func IsConflict(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.ErrorCode == http.StatusConflict
+}
+
+// IsBotBlocked reports whether the user blocked the bot or the bot was removed
+// from the chat, i.e. retrying the request will never succeed.
+func IsBotBlocked(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.ErrorCode == http.StatusForbidden
+}
+
+func IsChatNotFound(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.ErrorCode == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Description), "chat not found")
+}
+
+// IsThreadNotFound reports whether the forum topic a message was sent to has
+// been deleted.
+func IsThreadNotFound(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.ErrorCode == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Description), "thread not found")
+}
+
+// IsChatMigrated reports whether the group was upgraded to a supergroup and
+// returns the new chat ID Telegram wants us to use.
+func IsChatMigrated(err error) (int64, bool) {
+	apiErr, ok := AsAPIError(err)
+	if !ok || apiErr.MigrateToChatID == 0 {
+		return 0, false
+	}
+
+	return apiErr.MigrateToChatID, true
+}
+
+// IsServerError reports 5xx responses, which are worth retrying later.
+func IsServerError(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.ErrorCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,151 @@
+package botapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Telegram's documented flood limits for bots. Exceeding them gets 429s with a
+// retry_after that can run into minutes, so we stay just under.
+const (
+	globalSendInterval  = time.Second / 30
+	perChatSendInterval = time.Second
+	groupSendsPerMinute = 20
+)
+
+// RateLimiter schedules outbound messages so the bot stays within Telegram's
+// global, per-chat and per-group limits, and backs off when Telegram answers
+// with retry_after anyway.
+type RateLimiter struct {
+	mu          sync.Mutex
+	nextGlobal  time.Time
+	pausedUntil time.Time
+	chats       map[int64]*chatSendWindow
+	lastPrune   time.Time
+	now         func() time.Time
+}
+
+type chatSendWindow struct {
+	lastSent    time.Time
+	pausedUntil time.Time
+	// Send times within the last minute; only tracked for groups.
+	recent []time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		chats: make(map[int64]*chatSendWindow),
+		now:   time.Now,
+	}
+}
+
+// Wait blocks until a message may be sent to chatID and reserves that slot.
+func (l *RateLimiter) Wait(ctx context.Context, chatID int64) error {
+	for {
+		delay := l.reserve(chatID)
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve claims a send slot for chatID if one is free now, otherwise it
+// returns how long to wait before asking again.
+func (l *RateLimiter) reserve(chatID int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	window, ok := l.chats[chatID]
+	if !ok {
+		window = &chatSendWindow{}
+		l.chats[chatID] = window
+	}
+
+	isGroup := chatID < 0
+	if isGroup {
+		cutoff := now.Add(-time.Minute)
+		kept := window.recent[:0]
+		for _, sentAt := range window.recent {
+			if sentAt.After(cutoff) {
+				kept = append(kept, sentAt)
+			}
+		}
+		window.recent = kept
+	}
+
+	readyAt := now
+	readyAt = latest(readyAt, l.pausedUntil)
+	readyAt = latest(readyAt, l.nextGlobal)
+	readyAt = latest(readyAt, window.pausedUntil)
+	readyAt = latest(readyAt, window.lastSent.Add(perChatSendInterval))
+	if isGroup && len(window.recent) >= groupSendsPerMinute {
+		readyAt = latest(readyAt, window.recent[0].Add(time.Minute))
+	}
+
+	if readyAt.After(now) {
+		return readyAt.Sub(now)
+	}
+
+	l.nextGlobal = now.Add(globalSendInterval)
+	window.lastSent = now
+	if isGroup {
+		window.recent = append(window.recent, now)
+	}
+
+	return 0
+}
+
+// Pause stops sends to chatID for d, as requested by a 429's retry_after.
+// A chatID of 0 pauses every chat.
+func (l *RateLimiter) Pause(chatID int64, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := l.now().Add(d)
+	if chatID == 0 {
+		l.pausedUntil = latest(l.pausedUntil, until)
+		return
+	}
+
+	window, ok := l.chats[chatID]
+	if !ok {
+		window = &chatSendWindow{}
+		l.chats[chatID] = window
+	}
+	window.pausedUntil = latest(window.pausedUntil, until)
+}
+
+// prune forgets chats that have been quiet for longer than any limit window.
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+
+	cutoff := now.Add(-time.Minute)
+	for chatID, window := range l.chats {
+		if window.lastSent.Before(cutoff) && window.pausedUntil.Before(now) {
+			delete(l.chats, chatID)
+		}
+	}
+}
+
+func latest(a time.Time, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+
+	return a
+}
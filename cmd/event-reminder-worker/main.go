@@ -49,6 +49,28 @@ func main() {
 		return
 	}
 
+	prune := func() {
+		if err := pruneProcessedUpdates(); err != nil {
+			fmt.Printf("Processed updates prune failed: %s\n", err)
+		}
+	}
+
+	if _, err := scheduler.NewJob(gocron.DurationJob(24*time.Hour), gocron.NewTask(prune)); err != nil {
+		fmt.Printf("Failed to schedule processed updates prune: %s\n", err)
+		return
+	}
+
+	seasons := func() {
+		if err := processDueKarmaSeasons(); err != nil {
+			fmt.Printf("Karma seasons run failed: %s\n", err)
+		}
+	}
+
+	if _, err := scheduler.NewJob(gocron.DurationJob(5*time.Minute), gocron.NewTask(seasons), gocron.WithSingletonMode(gocron.LimitModeReschedule)); err != nil {
+		fmt.Printf("Failed to schedule karma seasons: %s\n", err)
+		return
+	}
+
 	run()
 	scheduler.Start()
 	fmt.Println("Event reminder worker started")
@@ -74,3 +96,29 @@ func processDueReminders() error {
 
 	return services.ProcessDueEventReminders(ctx, conn.Conn())
 }
+
+func pruneProcessedUpdates() error {
+	conn, err := services.GlobalPoolManager.GetConnectionFromPool(config.Current.DBName)
+	if err != nil {
+		return fmt.Errorf("get database connection: %w", err)
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	return services.PruneProcessedUpdates(ctx, conn)
+}
+
+func processDueKarmaSeasons() error {
+	conn, err := services.GlobalPoolManager.GetConnectionFromPool(config.Current.DBName)
+	if err != nil {
+		return fmt.Errorf("get database connection: %w", err)
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Minute)
+	defer cancel()
+
+	return services.ProcessDueKarmaSeasons(ctx, conn)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/joho/godotenv"
@@ -22,8 +23,19 @@ type Env struct {
 	DBHost              string
 	DBPort              string
 	DBDefaultName       string
+	UpdateMode          string
+	WebhookURL          string
+	WebhookSecretToken  string
+	WebhookListenAddr   string
+	WebhookPath         string
+	UpdateWorkers       int
 }
 
+const (
+	UpdateModePolling = "polling"
+	UpdateModeWebhook = "webhook"
+)
+
 var Current Env
 
 func Init() error {
@@ -53,12 +65,23 @@ func Load() (Env, error) {
 		DBHost:              getEnvOrDefault("DB_HOST", "localhost"),
 		DBPort:              getEnvOrDefault("DB_PORT", "5432"),
 		DBDefaultName:       getEnvOrDefault("DB_DEFAULT_NAME", "postgres"),
+		UpdateMode:          strings.ToLower(getEnvOrDefault("UPDATE_MODE", UpdateModePolling)),
+		WebhookURL:          strings.TrimSpace(os.Getenv("WEBHOOK_URL")),
+		WebhookSecretToken:  strings.TrimSpace(os.Getenv("WEBHOOK_SECRET_TOKEN")),
+		WebhookListenAddr:   getEnvOrDefault("WEBHOOK_LISTEN_ADDR", ":8080"),
+		WebhookPath:         getEnvOrDefault("WEBHOOK_PATH", "/telegram/webhook"),
 	}
 
 	if env.DBName == "" {
 		return env, fmt.Errorf("missing required environment variable: DB_NAME")
 	}
 
+	updateWorkers, err := strconv.Atoi(getEnvOrDefault("UPDATE_WORKERS", "8"))
+	if err != nil || updateWorkers < 1 {
+		return env, fmt.Errorf("invalid UPDATE_WORKERS: must be a positive integer")
+	}
+	env.UpdateWorkers = updateWorkers
+
 	return env, nil
 }
 
@@ -73,6 +96,20 @@ func (e Env) ValidateBot() error {
 		missing = append(missing, "WEB_APP_CONTEXT_SECRET")
 	}
 
+	switch e.UpdateMode {
+	case UpdateModePolling:
+	case UpdateModeWebhook:
+		if e.WebhookURL == "" {
+			missing = append(missing, "WEBHOOK_URL")
+		}
+
+		if e.WebhookSecretToken == "" {
+			missing = append(missing, "WEBHOOK_SECRET_TOKEN")
+		}
+	default:
+		return fmt.Errorf("invalid UPDATE_MODE %q: use %q or %q", e.UpdateMode, UpdateModePolling, UpdateModeWebhook)
+	}
+
 	if len(missing) > 0 {
 		return fmt.Errorf("missing required environment variables: %s", strings.Join(missing, ", "))
 	}
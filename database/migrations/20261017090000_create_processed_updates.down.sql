@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS bot_update_offset;
+DROP INDEX IF EXISTS idx_processed_updates_processed_at;
+DROP TABLE IF EXISTS processed_updates;
@@ -0,0 +1,19 @@
+CREATE TABLE processed_updates (
+    update_id BIGINT PRIMARY KEY,
+    chat_id BIGINT,
+    status TEXT NOT NULL DEFAULT 'processed',
+    error TEXT,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_processed_updates_status CHECK (status IN ('processed', 'failed'))
+);
+
+CREATE INDEX idx_processed_updates_processed_at ON processed_updates (processed_at);
+
+CREATE TABLE bot_update_offset (
+    id SMALLINT PRIMARY KEY DEFAULT 1,
+    next_offset BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_bot_update_offset_singleton CHECK (id = 1)
+);
+
+INSERT INTO bot_update_offset (id, next_offset) VALUES (1, 0);
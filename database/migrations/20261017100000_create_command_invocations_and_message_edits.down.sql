@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_message_edits_chat_message;
+DROP TABLE IF EXISTS message_edits;
+DROP TABLE IF EXISTS command_invocations;
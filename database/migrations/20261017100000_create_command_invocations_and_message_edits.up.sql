@@ -0,0 +1,27 @@
+CREATE TABLE command_invocations (
+    chat_id BIGINT NOT NULL,
+    message_id BIGINT NOT NULL,
+    update_id BIGINT NOT NULL,
+    user_id BIGINT,
+    command TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, message_id),
+    CONSTRAINT chk_command_invocations_status CHECK (status IN ('ok', 'usage_error', 'rejected', 'failed'))
+);
+
+CREATE TABLE message_edits (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    message_id BIGINT NOT NULL,
+    update_id BIGINT NOT NULL,
+    user_id BIGINT,
+    edit_date TIMESTAMPTZ,
+    text TEXT,
+    action TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_message_edits_action CHECK (action IN ('command_rerun', 'karma_ignored', 'ignored'))
+);
+
+CREATE INDEX idx_message_edits_chat_message ON message_edits (chat_id, message_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS topic_karma;
+
+ALTER TABLE events DROP COLUMN IF EXISTS message_thread_id;
@@ -0,0 +1,11 @@
+ALTER TABLE events ADD COLUMN message_thread_id BIGINT;
+
+CREATE TABLE topic_karma (
+    group_id BIGINT NOT NULL,
+    message_thread_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    karma INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, message_thread_id, user_id)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE chat_settings (
+    chat_id BIGINT PRIMARY KEY,
+    language TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,14 @@
+CREATE TABLE karma_triggers (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    kind TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    position TEXT NOT NULL,
+    karma_value INT NOT NULL,
+    created_by_user_id BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_karma_triggers_kind CHECK (kind IN ('token', 'emoji', 'keyword', 'regex')),
+    CONSTRAINT chk_karma_triggers_position CHECK (position IN ('start', 'anywhere', 'whole')),
+    CONSTRAINT chk_karma_triggers_karma_value CHECK (karma_value <> 0),
+    CONSTRAINT uq_karma_triggers_chat_rule UNIQUE (chat_id, kind, position, pattern)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS karma_opening_balances;
+DROP TABLE IF EXISTS karma_events;
@@ -0,0 +1,37 @@
+CREATE TABLE karma_events (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    giver_user_id BIGINT NOT NULL,
+    receiver_user_id BIGINT NOT NULL,
+    karma_value INT NOT NULL,
+    kind TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    message_id BIGINT,
+    update_id BIGINT,
+    message_thread_id INT NOT NULL DEFAULT 0,
+    reverts_event_id BIGINT UNIQUE REFERENCES karma_events (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_karma_events_kind CHECK (kind IN ('karma', 'undo')),
+    CONSTRAINT chk_karma_events_undo CHECK ((kind = 'undo') = (reverts_event_id IS NOT NULL))
+);
+
+CREATE INDEX idx_karma_events_chat_receiver ON karma_events (chat_id, receiver_user_id, created_at);
+CREATE INDEX idx_karma_events_chat_giver ON karma_events (chat_id, giver_user_id, created_at);
+
+-- The counters in users_ranking predate the ledger. What they held when the
+-- ledger started is kept here, so totals can be rebuilt as these plus the
+-- events.
+CREATE TABLE karma_opening_balances (
+    chat_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    karma INT NOT NULL DEFAULT 0,
+    karma_given INT NOT NULL DEFAULT 0,
+    karma_taken INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, user_id)
+);
+
+INSERT INTO karma_opening_balances (chat_id, user_id, karma, karma_given, karma_taken)
+SELECT group_id, user_id, COALESCE(karma, 0), karma_given, karma_taken
+FROM users_ranking
+WHERE COALESCE(karma, 0) <> 0 OR karma_given <> 0 OR karma_taken <> 0;
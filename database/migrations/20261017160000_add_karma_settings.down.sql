@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS chat_members;
+
+ALTER TABLE chat_settings
+    DROP CONSTRAINT IF EXISTS chk_chat_settings_karma_limits,
+    DROP COLUMN IF EXISTS karma_cooldown_seconds,
+    DROP COLUMN IF EXISTS karma_receiver_cooldown_seconds,
+    DROP COLUMN IF EXISTS karma_daily_cap,
+    DROP COLUMN IF EXISTS karma_allow_negative,
+    DROP COLUMN IF EXISTS karma_min_member_hours,
+    DROP COLUMN IF EXISTS karma_bots_can_receive;
@@ -0,0 +1,29 @@
+ALTER TABLE chat_settings
+    ADD COLUMN karma_cooldown_seconds INT NOT NULL DEFAULT 60,
+    ADD COLUMN karma_receiver_cooldown_seconds INT NOT NULL DEFAULT 0,
+    ADD COLUMN karma_daily_cap INT NOT NULL DEFAULT 0,
+    ADD COLUMN karma_allow_negative BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN karma_min_member_hours INT NOT NULL DEFAULT 0,
+    ADD COLUMN karma_bots_can_receive BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD CONSTRAINT chk_chat_settings_karma_limits CHECK (
+        karma_cooldown_seconds >= 0
+        AND karma_receiver_cooldown_seconds >= 0
+        AND karma_daily_cap >= 0
+        AND karma_min_member_hours >= 0
+    );
+
+-- When the bot first saw each user in each chat, for the minimum membership
+-- age before giving karma.
+CREATE TABLE chat_members (
+    chat_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, user_id)
+);
+
+-- Users who already have karma were around before the bot tracked members,
+-- so they count as long-standing.
+INSERT INTO chat_members (chat_id, user_id, first_seen_at)
+SELECT group_id, user_id, to_timestamp(0)
+FROM users_ranking
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS karma_restriction_log;
+
+ALTER TABLE users_ranking
+    DROP COLUMN IF EXISTS give_karma_blocked_until,
+    DROP COLUMN IF EXISTS receive_karma_blocked_until;
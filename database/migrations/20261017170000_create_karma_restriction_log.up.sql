@@ -0,0 +1,18 @@
+-- NULL with the matching allowed_to_* flag off means the restriction has no
+-- end.
+ALTER TABLE users_ranking
+    ADD COLUMN give_karma_blocked_until TIMESTAMPTZ,
+    ADD COLUMN receive_karma_blocked_until TIMESTAMPTZ;
+
+CREATE TABLE karma_restriction_log (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    admin_user_id BIGINT NOT NULL,
+    action TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_karma_restriction_log_action CHECK (action IN ('ban', 'mute_receive', 'unban'))
+);
+
+CREATE INDEX idx_karma_restriction_log_chat ON karma_restriction_log (chat_id, created_at);
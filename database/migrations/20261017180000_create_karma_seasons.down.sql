@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS karma_season_standings;
+DROP TABLE IF EXISTS karma_seasons;
+
+ALTER TABLE chat_settings
+    DROP CONSTRAINT IF EXISTS chk_chat_settings_season,
+    DROP COLUMN IF EXISTS season_mode,
+    DROP COLUMN IF EXISTS season_carry_percent;
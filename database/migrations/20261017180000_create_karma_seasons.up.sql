@@ -0,0 +1,40 @@
+-- How a chat's karma seasons end: by an admin (manual) or at the start of
+-- each calendar month or quarter (UTC). season_carry_percent is the share of
+-- each user's karma kept into the next season; 0 resets it.
+ALTER TABLE chat_settings
+    ADD COLUMN season_mode TEXT NOT NULL DEFAULT 'manual',
+    ADD COLUMN season_carry_percent INT NOT NULL DEFAULT 0,
+    ADD CONSTRAINT chk_chat_settings_season CHECK (
+        season_mode IN ('manual', 'monthly', 'quarterly')
+        AND season_carry_percent BETWEEN 0 AND 100
+    );
+
+-- A chat has at most one open season, the one without ended_at.
+CREATE TABLE karma_seasons (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    number INT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ended_at TIMESTAMPTZ,
+    CONSTRAINT uq_karma_seasons_chat_number UNIQUE (chat_id, number)
+);
+
+CREATE UNIQUE INDEX uq_karma_seasons_open ON karma_seasons (chat_id) WHERE ended_at IS NULL;
+
+-- The final leaderboard of each ended season. Names are kept as they were
+-- when the season ended.
+CREATE TABLE karma_season_standings (
+    season_id BIGINT NOT NULL REFERENCES karma_seasons (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    karma INT NOT NULL,
+    rank INT NOT NULL,
+    PRIMARY KEY (season_id, user_id)
+);
+
+CREATE INDEX idx_karma_season_standings_rank ON karma_season_standings (season_id, rank);
+
+-- Karma so far counts towards the first season of every chat that has some.
+INSERT INTO karma_seasons (chat_id, number)
+SELECT DISTINCT group_id, 1
+FROM users_ranking;
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS karma_abuse_flags;
+
+ALTER TABLE chat_settings
+    DROP CONSTRAINT IF EXISTS chk_chat_settings_abuse,
+    DROP COLUMN IF EXISTS abuse_action,
+    DROP COLUMN IF EXISTS abuse_window_hours,
+    DROP COLUMN IF EXISTS abuse_pair_limit,
+    DROP COLUMN IF EXISTS abuse_burst_givers,
+    DROP COLUMN IF EXISTS abuse_burst_minutes,
+    DROP COLUMN IF EXISTS abuse_reduce_percent;
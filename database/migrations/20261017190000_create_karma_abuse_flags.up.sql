@@ -0,0 +1,43 @@
+-- How a chat responds to karma that looks like abuse, and what counts as
+-- abuse. Chats opt in by picking an action other than off. A limit of 0 turns
+-- that check off.
+ALTER TABLE chat_settings
+    ADD COLUMN abuse_action TEXT NOT NULL DEFAULT 'off',
+    ADD COLUMN abuse_window_hours INT NOT NULL DEFAULT 24,
+    ADD COLUMN abuse_pair_limit INT NOT NULL DEFAULT 3,
+    ADD COLUMN abuse_burst_givers INT NOT NULL DEFAULT 3,
+    ADD COLUMN abuse_burst_minutes INT NOT NULL DEFAULT 10,
+    ADD COLUMN abuse_reduce_percent INT NOT NULL DEFAULT 50,
+    ADD CONSTRAINT chk_chat_settings_abuse CHECK (
+        abuse_action IN ('off', 'report', 'ignore', 'reduce', 'approve')
+        AND abuse_window_hours >= 1
+        AND abuse_pair_limit >= 0
+        AND (abuse_burst_givers = 0 OR abuse_burst_givers >= 2)
+        AND abuse_burst_minutes >= 1
+        AND abuse_reduce_percent BETWEEN 0 AND 100
+    );
+
+-- Karma the abuse checks flagged, and what became of it. karma_value is what
+-- the message asked for and applied_value what it counted as, reduced or not,
+-- NULL while it waits for an admin or when it never was.
+CREATE TABLE karma_abuse_flags (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    giver_user_id BIGINT NOT NULL,
+    receiver_user_id BIGINT NOT NULL,
+    karma_value INT NOT NULL,
+    applied_value NUMERIC(10, 2),
+    rule TEXT NOT NULL,
+    status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    message_id BIGINT,
+    update_id BIGINT,
+    message_thread_id INT NOT NULL DEFAULT 0,
+    resolved_by_user_id BIGINT,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_karma_abuse_flags_rule CHECK (rule IN ('reciprocal', 'repeated', 'burst')),
+    CONSTRAINT chk_karma_abuse_flags_status CHECK (status IN ('counted', 'ignored', 'reduced', 'pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX idx_karma_abuse_flags_chat ON karma_abuse_flags (chat_id, created_at);
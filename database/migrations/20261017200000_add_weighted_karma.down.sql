@@ -0,0 +1,11 @@
+ALTER TABLE karma_opening_balances
+    DROP COLUMN IF EXISTS weighted_karma;
+
+ALTER TABLE karma_events
+    DROP COLUMN IF EXISTS weighted_value;
+
+ALTER TABLE users_ranking
+    DROP COLUMN IF EXISTS weighted_karma;
+
+ALTER TABLE chat_settings
+    DROP COLUMN IF EXISTS karma_weighting;
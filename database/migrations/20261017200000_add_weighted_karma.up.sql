@@ -0,0 +1,24 @@
+-- With karma_weighting on, each karma is worth more or less depending on who
+-- gives it. The raw karma columns keep counting every karma as its value; the
+-- weighted ones count what it was worth. Karma from before weighting existed
+-- was worth its raw value.
+ALTER TABLE chat_settings
+    ADD COLUMN karma_weighting BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE users_ranking
+    ADD COLUMN weighted_karma NUMERIC(12, 2) NOT NULL DEFAULT 0;
+
+UPDATE users_ranking SET weighted_karma = karma WHERE COALESCE(karma, 0) <> 0;
+
+ALTER TABLE karma_events
+    ADD COLUMN weighted_value NUMERIC(10, 2);
+
+UPDATE karma_events SET weighted_value = karma_value;
+
+ALTER TABLE karma_events
+    ALTER COLUMN weighted_value SET NOT NULL;
+
+ALTER TABLE karma_opening_balances
+    ADD COLUMN weighted_karma NUMERIC(12, 2) NOT NULL DEFAULT 0;
+
+UPDATE karma_opening_balances SET weighted_karma = karma;
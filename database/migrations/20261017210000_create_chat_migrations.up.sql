@@ -0,0 +1,8 @@
+-- Groups already moved to their supergroup. MigrateChat records each one
+-- while holding a lock on the old chat ID, so a second run for the same
+-- upgrade sees it and moves nothing.
+CREATE TABLE chat_migrations (
+    old_chat_id BIGINT PRIMARY KEY,
+    new_chat_id BIGINT NOT NULL,
+    migrated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
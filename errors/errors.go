@@ -1,10 +1,12 @@
 package errors
 
 import (
+	"bot/telegram/botapi"
+	"bot/telegram/shared"
 	"context"
+	stdErrors "errors"
+	"net"
 	"strings"
-
-	"github.com/jackc/pgx/v5"
 )
 
 type ErrorRecordInput struct {
@@ -14,7 +16,7 @@ type ErrorRecordInput struct {
 	Error      string
 }
 
-func CreateErrorRecord(conn *pgx.Conn, input ErrorRecordInput) error {
+func CreateErrorRecord(conn shared.DBTX, input ErrorRecordInput) error {
 	sqlInsertErrorRecord := `
 		INSERT INTO bot_errors (sender_id, receiver_id, group_id, error)
 		VALUES ($1, $2, $3, $4)
@@ -30,6 +32,20 @@ func IsNetworkError(err error) bool {
 		return false
 	}
 
+	if botapi.IsServerError(err) || botapi.IsTooManyRequests(err) {
+		return true
+	}
+
+	if _, ok := botapi.AsAPIError(err); ok {
+		// Telegram answered, so the network is fine; the request itself was bad.
+		return false
+	}
+
+	var netErr net.Error
+	if stdErrors.As(err, &netErr) {
+		return true
+	}
+
 	errStr := err.Error()
 	networkErrors := []string{
 		"connection refused",
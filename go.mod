@@ -3,13 +3,13 @@ module bot/telegram
 go 1.25.0
 
 require (
+	github.com/go-co-op/gocron/v2 v2.21.1
 	github.com/golang-migrate/migrate/v4 v4.19.1
 	github.com/jackc/pgx/v5 v5.9.1
 	github.com/joho/godotenv v1.5.1
 )
 
 require (
-	github.com/go-co-op/gocron/v2 v2.21.1 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
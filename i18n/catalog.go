@@ -0,0 +1,145 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultLanguage is used when neither the chat nor the user picked a
+// supported language, and for keys a bundle is missing.
+const DefaultLanguage = "en"
+
+// Args fills the {name} placeholders of a message.
+type Args map[string]any
+
+// Plural holds the forms of a message that depends on a count. The count is
+// available to both forms as {count}.
+type Plural struct {
+	One   string
+	Other string
+}
+
+// Bundle is every message in one language.
+type Bundle struct {
+	Messages map[string]string
+	Plurals  map[string]Plural
+	// IsOne reports whether n takes the One form of a plural.
+	IsOne func(n int) bool
+}
+
+var bundles = map[string]Bundle{
+	"en": english,
+	"es": spanish,
+}
+
+// Languages lists the supported language codes, default first.
+func Languages() []string {
+	languages := make([]string, 0, len(bundles))
+	for language := range bundles {
+		if language != DefaultLanguage {
+			languages = append(languages, language)
+		}
+	}
+	sort.Strings(languages)
+
+	return append([]string{DefaultLanguage}, languages...)
+}
+
+// Normalize maps a Telegram language_code such as "es-419" to a supported
+// language, or "" when there is none.
+func Normalize(code string) string {
+	language := strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		language = language[:i]
+	}
+
+	if _, ok := bundles[language]; !ok {
+		return ""
+	}
+
+	return language
+}
+
+// T returns the message for key in language, falling back to the default
+// language and then to the key itself.
+func T(language string, key string, args Args) string {
+	for _, candidate := range []string{language, DefaultLanguage} {
+		if message, ok := bundles[candidate].Messages[key]; ok {
+			return format(message, args)
+		}
+	}
+
+	return key
+}
+
+// N returns the form of a plural message that fits count.
+func N(language string, key string, count int, args Args) string {
+	for _, candidate := range []string{language, DefaultLanguage} {
+		bundle := bundles[candidate]
+		plural, ok := bundle.Plurals[key]
+		if !ok {
+			continue
+		}
+
+		message := plural.Other
+		if bundle.IsOne(count) {
+			message = plural.One
+		}
+
+		withCount := Args{"count": count}
+		for name, value := range args {
+			withCount[name] = value
+		}
+
+		return format(message, withCount)
+	}
+
+	return key
+}
+
+// Keys lists every message and plural key in language.
+func Keys(language string) []string {
+	bundle := bundles[language]
+	keys := make([]string, 0, len(bundle.Messages)+len(bundle.Plurals))
+	for key := range bundle.Messages {
+		keys = append(keys, key)
+	}
+	for key := range bundle.Plurals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Forms returns the raw text of a message, one entry per plural form, or nil
+// when language has no such key.
+func Forms(language string, key string) []string {
+	bundle := bundles[language]
+	if message, ok := bundle.Messages[key]; ok {
+		return []string{message}
+	}
+	if plural, ok := bundle.Plurals[key]; ok {
+		return []string{plural.One, plural.Other}
+	}
+
+	return nil
+}
+
+func format(message string, args Args) string {
+	if len(args) == 0 {
+		return message
+	}
+
+	replacements := make([]string, 0, len(args)*2)
+	for name, value := range args {
+		replacements = append(replacements, "{"+name+"}", fmt.Sprint(value))
+	}
+
+	return strings.NewReplacer(replacements...).Replace(message)
+}
+
+func isOneExactly(n int) bool {
+	return n == 1
+}
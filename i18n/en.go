@@ -0,0 +1,283 @@
+package i18n
+
+var english = Bundle{
+	IsOne: isOneExactly,
+	Messages: map[string]string{
+		"language.name": "English",
+
+		"cmd.command.description":              "Show command help",
+		"cmd.command.help":                     "Shows this help message with details for every command.",
+		"cmd.new_event.description":            "Open the event form",
+		"cmd.new_event.help":                   "Opens the event Web App. Use it to create custom events, reminders, or birthdays with a form.",
+		"cmd.ask_catholic_church.description":  "Ask a Catholic teaching question",
+		"cmd.ask_catholic_church.help":         "Asks Magisterium AI a question about Catholic teaching and replies with the answer.",
+		"cmd.ask_catholic_church.example":      "/ask_catholic_church What does the Church teach about forgiveness?",
+		"cmd.set_birthday.description":         "Reply with DD-MM-YYYY to save a birthday",
+		"cmd.set_birthday.help":                "Creates a yearly birthday event. Use it as a reply to the person's message so the bot knows whose birthday to save.",
+		"cmd.set_birthday.example":             "reply to Maria and send /set_birthday 24-12-1990",
+		"cmd.set_birthday.failure":             "Birthday event was not created. Please try again later.",
+		"cmd.show_events.description":          "Show all active events in this group",
+		"cmd.show_events.help":                 "Shows all active events in this group with their IDs, types, titles, and dates.",
+		"cmd.delete_event.description":         "Delete an event by ID (admins only)",
+		"cmd.delete_event.help":                "Deletes an event by its ID once you confirm with the buttons.",
+		"cmd.delete_event.example":             "/delete_event 42",
+		"cmd.lovedusers.description":           "Show users with the most positive karma",
+		"cmd.lovedusers.help":                  "Shows the users with the most positive karma in this chat. Add today, week, month or year to count only the karma received since then, a number to show up to 50 users, in a forum topic, \"topic\" to count only the karma given in that topic, and \"weighted\" to rank weighted karma.",
+		"cmd.lovedusers.example":               "/lovedusers week 5",
+		"cmd.hatedusers.description":           "Show users with the most negative karma",
+		"cmd.hatedusers.help":                  "Shows the users with the most negative karma in this chat. Add today, week, month or year to count only the karma received since then, a number to show up to 50 users, in a forum topic, \"topic\" to count only the karma given in that topic, and \"weighted\" to rank weighted karma.",
+		"cmd.hatedusers.example":               "/hatedusers month topic",
+		"cmd.generoususers.description":        "Show who gives the most karma",
+		"cmd.generoususers.help":               "Shows the users who gave the most positive karma in this chat. Takes the same today, week, month or year, number and \"topic\" options as /lovedusers.",
+		"cmd.generoususers.example":            "/generoususers year",
+		"cmd.criticalusers.description":        "Show who takes the most karma",
+		"cmd.criticalusers.help":               "Shows the users who gave the most negative karma in this chat. Takes the same today, week, month or year, number and \"topic\" options as /lovedusers.",
+		"cmd.criticalusers.example":            "/criticalusers week",
+		"cmd.language.description":             "Show or change the bot's language",
+		"cmd.language.help":                    "Shows the language the bot uses in this chat, or changes it. \"auto\" answers everyone in their own Telegram language. In groups, only admins can change it.",
+		"cmd.language.example":                 "/language es",
+		"cmd.karma.description":                "Show someone's karma and rank",
+		"cmd.karma.help":                       "Shows the karma, rank, karma given and taken, top givers and last karma of you, of the person you reply to, or of the person you mention.",
+		"cmd.karma.example":                    "/karma @maria",
+		"cmd.karma_history.description":        "Show the latest karma someone gave and got",
+		"cmd.karma_history.help":               "Shows the latest karma given and received in this group by you, by the person you reply to, or by the person you mention.",
+		"cmd.karma_history.example":            "/karma_history @maria",
+		"cmd.undo_karma.description":           "Take back the karma you just gave",
+		"cmd.undo_karma.help":                  "Takes back the karma of your latest karma message, if you sent it in the last 5 minutes.",
+		"cmd.rebuild_karma.description":        "Recount karma from the history (admins only)",
+		"cmd.rebuild_karma.help":               "Recounts everyone's karma, karma given and karma taken in this group from the karma history, fixing totals that got out of step.",
+		"cmd.karma_settings.description":       "Show or change the karma rules",
+		"cmd.karma_settings.help":              "Shows this group's karma rules: cooldowns, daily cap, negative karma, how long people must be here before giving karma, and whether bots can get it. Admins can change one by adding its name and a new value.",
+		"cmd.karma_settings.example":           "/karma_settings cooldown 120",
+		"cmd.season.description":               "Show the current karma season",
+		"cmd.season.help":                      "Shows the current karma season, when it started and ends, and its top 10. Admins can close it now with /season end: the final leaderboard is archived and the winners announced. Set monthly or quarterly seasons and how much karma carries over with /karma_settings.",
+		"cmd.season.example":                   "/season",
+		"cmd.season_history.description":       "Show the champions of past seasons",
+		"cmd.season_history.help":              "Shows the champions of the last karma seasons, 5 unless you give a number.",
+		"cmd.season_history.example":           "/season_history 10",
+		"cmd.karma_ban.description":            "Stop someone from giving karma (admins only)",
+		"cmd.karma_ban.help":                   "Stops the person you reply to or mention from giving karma in this group, for up to a year (like 30m, 12h, 7d or 2w) or until /karma_unban.",
+		"cmd.karma_ban.example":                "/karma_ban @maria 7d",
+		"cmd.karma_mute_receive.description":   "Stop someone from receiving karma (admins only)",
+		"cmd.karma_mute_receive.help":          "Stops the person you reply to or mention from receiving karma in this group, for up to a year (like 30m, 12h, 7d or 2w) or until /karma_unban.",
+		"cmd.karma_mute_receive.example":       "/karma_mute_receive @maria 12h",
+		"cmd.karma_unban.description":          "Lift someone's karma restrictions (admins only)",
+		"cmd.karma_unban.help":                 "Lets the person you reply to or mention give and receive karma again.",
+		"cmd.karma_unban.example":              "/karma_unban @maria",
+		"cmd.karma_bans.description":           "List karma restrictions (admins only)",
+		"cmd.karma_bans.help":                  "Lists who can't give or receive karma in this group and until when. Add \"log\" to see the latest changes and which admin made them.",
+		"cmd.karma_bans.example":               "/karma_bans log",
+		"cmd.karma_flags.description":          "Show karma flagged as possible abuse (admins only)",
+		"cmd.karma_flags.help":                 "Lists the latest karma the abuse checks flagged: people trading karma, giving the same person karma too often, or several people taking karma from someone at once, and what became of it.",
+		"cmd.karma_approve.description":        "Count karma held for approval (admins only)",
+		"cmd.karma_approve.help":               "Counts flagged karma that is waiting for an admin, by the ID /karma_flags shows.",
+		"cmd.karma_approve.example":            "/karma_approve 12",
+		"cmd.karma_reject.description":         "Drop karma held for approval (admins only)",
+		"cmd.karma_reject.help":                "Drops flagged karma that is waiting for an admin, by the ID /karma_flags shows.",
+		"cmd.karma_reject.example":             "/karma_reject 12",
+		"cmd.karma_triggers.description":       "List this group's karma triggers",
+		"cmd.karma_triggers.help":              "Lists the words, emoji and patterns that give or take karma in this group, with the IDs to remove them by.",
+		"cmd.add_karma_trigger.description":    "Add a karma trigger (admins only)",
+		"cmd.add_karma_trigger.help":           "Makes replies that match a pattern give or take karma. A token is one word like ++, an emoji is matched as written, a keyword is a comma-separated list of words, and a regex is a regular expression. The position says where it must appear: at the start, anywhere, or as the whole message. Case is ignored.",
+		"cmd.add_karma_trigger.example":        "/add_karma_trigger keyword anywhere 1 gracias, thanks",
+		"cmd.remove_karma_trigger.description": "Remove a karma trigger by ID (admins only)",
+		"cmd.remove_karma_trigger.help":        "Removes one of this group's karma triggers. /karma_triggers shows their IDs.",
+		"cmd.remove_karma_trigger.example":     "/remove_karma_trigger 3",
+
+		"arg.command":             "command",
+		"arg.question":            "question",
+		"arg.date":                "DD-MM-YYYY",
+		"arg.id":                  "id",
+		"arg.user":                "@user",
+		"arg.user_duration":       "@user duration",
+		"arg.value":               "value",
+		"arg.pattern":             "pattern",
+		"arg.count":               "count",
+		"arg.leaderboard_options": "today|week|month|year|all limit topic",
+		"arg.weighted_options":    "today|week|month|year|all limit topic weighted",
+
+		"command.only_in_private":       "/{command} only works in private chats.",
+		"command.only_in_groups":        "/{command} only works in groups.",
+		"command.admin_check_failed":    "Failed to verify admin permissions.",
+		"command.admin_only":            "Only group admins can use /{command}.",
+		"command.admin_only_note":       "Only group admins can use this command.",
+		"command.usage":                 "Usage: {usage}",
+		"command.example":               "Example: {example}",
+		"command.unknown":               "Unknown command {command}. Send /command to see them all.",
+		"command.help_header":           "Available bot commands:",
+		"callback.expired":              "This button no longer works.",
+		"callback.failed":               "Something went wrong. Please try again later.",
+		"language.current":              "This chat uses {language}.",
+		"language.current_auto":         "This chat answers everyone in their own Telegram language. Yours is {language}.",
+		"language.set":                  "Language set to {language}.",
+		"language.reset":                "I'll answer everyone in their own Telegram language again.",
+		"language.admin_only":           "Only group admins can change the language.",
+		"language.failed":               "Failed to save the language. Please try again later.",
+		"karma.self":                    "Wew. You can't give karma to yourself dummy ~",
+		"karma.sender_blocked":          "Sorry bro you can't give aura points around here.",
+		"karma.receiver_blocked":        "Sorry bro this person can't receive aura points.",
+		"karma.cooldown":                "Whoops you are not allowed to give karma yet :(",
+		"karma.given":                   "Karma given to {name}. Total karma: {total}",
+		"karma.taken":                   "Karma taken from {name}. Total karma: {total}",
+		"karma.failed":                  "Error adding karma",
+		"karma.abuse_ignored":           "Karma for {name} wasn't counted: it looks like karma trading or piling on. The admins have been told.",
+		"karma.abuse_pending":           "Karma for {name} is waiting for an admin to approve it (#{id}).",
+		"karma.abuse_reduced":           "Karma for {name} counts as {value} weighted karma after a reduction. Total karma: {total}",
+		"karma.weighted":                "(counted as {value} weighted)",
+		"karma.unknown_users":           "I don't know {usernames} yet. They need to write here first so I can give them karma.",
+		"karma.negative_disabled":       "Negative karma is turned off in this group.",
+		"karma.bots_blocked":            "Bots can't receive karma in this group.",
+		"karma.receiver_cooldown":       "You gave karma to {names} not long ago. Try again later.",
+		"settings.header":               "Karma settings for this group:",
+		"settings.cooldown":             "seconds between two karma messages from the same person",
+		"settings.receiver_cooldown":    "seconds before giving karma to the same person again, 0 for no limit",
+		"settings.daily_cap":            "karma one person can give in 24 hours, 0 for no limit",
+		"settings.negative":             "whether -1 and other negative karma count",
+		"settings.min_member_hours":     "hours someone must have been here before giving karma",
+		"settings.bots":                 "whether bots can receive karma",
+		"settings.season":               "when karma seasons end: manual (with /season end), monthly or quarterly",
+		"settings.season_carry":         "percent of karma kept into the next season, 0 to start again from 0",
+		"settings.abuse":                "what happens to karma that looks like abuse: off, report, ignore, reduce (needs weighting on) or approve",
+		"settings.abuse_window_hours":   "hours the karma trading and repeated karma checks look back",
+		"settings.abuse_pair_limit":     "karma one person can give the same person in that time before it is flagged, 0 for no check",
+		"settings.abuse_burst_givers":   "people taking karma from the same person at once that is flagged, at least 2, or 0 for no check",
+		"settings.abuse_burst_minutes":  "minutes that count as at once",
+		"settings.abuse_reduce_percent": "percent of flagged karma counted with reduce",
+		"settings.weighting":            "weigh karma by the giver's own karma, time in the group and karma given in the last day",
+		"settings.how_to_change":        "Admins can change a setting with /karma_settings <setting> <value>.",
+		"settings.admin_only":           "Only group admins can change the karma settings.",
+		"settings.bad_number":           "{setting} takes a number from {min} to {max}.",
+		"settings.bad_number_or_off":    "{setting} takes 0 to turn it off, or a number from {min} to {max}.",
+		"settings.bad_switch":           "{setting} takes on or off.",
+		"settings.bad_choice":           "{setting} takes one of: {choices}.",
+		"settings.reduce_unweighted":    "abuse can only be reduce while weighting is on. Turn it on first with /karma_settings weighting on.",
+		"settings.weighting_reduces":    "weighting can't be turned off while abuse is reduce. Pick another abuse action first.",
+		"settings.changed":              "{setting} is now {value}.",
+		"settings.failed":               "Failed to save the karma settings. Please try again later.",
+		"leaderboard.topic_only":        "Topic leaderboards only work inside a forum topic.",
+		"leaderboard.empty":             "No users found for this group yet.",
+		"leaderboard.empty_topic":       "No karma has been given in this topic yet.",
+		"leaderboard.empty_window":      "No karma was given in this period.",
+		"leaderboard.window_today":      "Today",
+		"leaderboard.window_week":       "This week",
+		"leaderboard.window_month":      "This month",
+		"leaderboard.window_year":       "This year",
+		"leaderboard.weighted":          "Weighted by who gave the karma",
+		"leaderboard.no_weighting":      "Only /lovedusers and /hatedusers can be weighted.",
+		"leaderboard.unknown_user":      "Unknown",
+		"events.query_failed":           "Failed to retrieve events.",
+		"events.read_failed":            "Failed to read events.",
+		"events.none":                   "No active events in this group.",
+		"events.not_found":              "Event #{id} not found in this group.",
+		"events.delete_failed":          "Failed to delete event.",
+		"events.delete_confirm":         "Delete event #{id} \"{title}\"?",
+		"events.delete_button":          "Delete",
+		"events.cancel_button":          "Cancel",
+		"events.delete_admin_only":      "Only group admins can delete events.",
+		"events.delete_cancelled":       "Deleting event #{id} was cancelled.",
+		"events.deleted":                "Event #{id} deleted.",
+		"events.webapp_prompt":          "Create a new event from the Telegram Web App.",
+		"events.webapp_button":          "Create event",
+		"events.webapp_link":            "Open the event form here: {url}",
+		"events.reminder":               "Reminder: {title}",
+		"birthday.no_sender":            "I need to know who is setting the birthday. Try again from a normal user account.",
+		"birthday.no_reply":             "Reply to someone's message with /set_birthday DD-MM-YYYY.",
+		"birthday.usage":                "Use /set_birthday DD-MM-YYYY. Example: /set_birthday 24-12-1990",
+		"birthday.title":                "Celebrate {name}'s birthday! \U0001F382\U0001F389",
+		"birthday.description":          "Don't forget to wish {name} a happy birthday!",
+		"birthday.day_before":           "Tomorrow is {name}'s birthday \U0001F382",
+		"birthday.day_of":               "Happy Birthday, {name}!!! \U0001F382\U0001F389\U0001F382",
+		"birthday.duplicate":            "A birthday is already saved for this chat on {date}.",
+		"birthday.created":              "Birthday event created \U00002705\nPerson: {name}\nDate: {date}\nReminder time: {hour}:00 UTC\nEvent ID: {id}\nI'll remind this chat every year.",
+		"profile.none":                  "{name} has no karma in this group yet.",
+		"profile.header":                "{name} has {karma} karma.",
+		"profile.rank":                  "Rank: #{rank} of {users}",
+		"profile.given_taken":           "Karma given: {given} · Karma taken: {taken}",
+		"profile.top_givers":            "Top givers: {givers}",
+		"profile.last_karma":            "Last karma received: {date}",
+		"history.header":                "Latest karma of {name} (UTC):",
+		"history.none":                  "{name} hasn't given or received karma here yet.",
+		"history.undo":                  "(undone)",
+		"undo.nothing":                  "You haven't given any karma in the last {minutes} minutes that could be taken back.",
+		"undo.done":                     "Took back {value} karma from {name}. Total karma: {total}",
+		"undo.failed":                   "Failed to take back the karma. Please try again later.",
+		"rebuild.failed":                "Failed to recount karma. Please try again later.",
+		"magisterium.usage":             "Use /ask_catholic_church followed by your question. Example: /ask_catholic_church What does the Church teach about forgiveness?",
+		"magisterium.unavailable":       "I couldn't get an answer from Magisterium AI right now. Please try again later.",
+		"magisterium.sources":           "Sources:",
+		"magisterium.unknown_source":    "Catholic source",
+		"bans.ban":                      "{name} can't give karma here {until}.",
+		"bans.mute_receive":             "{name} can't receive karma here {until}.",
+		"bans.unban":                    "{name} can give and receive karma again.",
+		"bans.until":                    "until {date} UTC",
+		"bans.no_end":                   "until an admin lifts it",
+		"bans.none":                     "Nobody has karma restrictions in this group.",
+		"bans.no_give":                  "can't give karma {until}",
+		"bans.no_receive":               "can't receive karma {until}",
+		"bans.log_none":                 "No karma restrictions have been changed in this group yet.",
+		"bans.log_header":               "Latest karma restriction changes (UTC):",
+		"bans.log_ban":                  "{admin} stopped {name} from giving karma {until}",
+		"bans.log_mute_receive":         "{admin} stopped {name} from receiving karma {until}",
+		"bans.log_unban":                "{admin} lifted {name}'s karma restrictions",
+		"bans.failed":                   "Failed to change the karma restrictions. Please try again later.",
+		"abuse.report":                  "⚠️ Possible karma abuse in {chat}:\n{flag}\n\nSee /karma_flags in the group.",
+		"abuse.rule_reciprocal":         "{giver} and {receiver} keep trading karma",
+		"abuse.rule_repeated":           "{giver} keeps giving {receiver} karma",
+		"abuse.rule_burst":              "several people took karma from {receiver} at once, the last {giver}",
+		"abuse.status_counted":          "counted",
+		"abuse.status_ignored":          "not counted",
+		"abuse.status_reduced":          "counted as {applied} weighted",
+		"abuse.status_pending":          "waiting for /karma_approve {id} or /karma_reject {id}",
+		"abuse.status_approved":         "approved by {admin}",
+		"abuse.status_rejected":         "rejected by {admin}",
+		"abuse.none":                    "No karma has been flagged in this group.",
+		"abuse.header":                  "Latest karma flagged as possible abuse:",
+		"abuse.not_pending":             "No karma with ID {id} is waiting for approval.",
+		"abuse.approved":                "Approved #{id}. {name} now has {total} karma.",
+		"abuse.rejected":                "Rejected #{id}.",
+		"abuse.failed":                  "Failed to review the flagged karma. Please try again later.",
+		"season.current":                "Karma season {number}, since {date}.",
+		"season.ends":                   "It ends on {date}.",
+		"season.ends_manual":            "It ends when an admin closes it with /season end.",
+		"season.no_standings":           "Nobody has karma this season yet.",
+		"season.admin_only":             "Only group admins can end the season.",
+		"season.ended":                  "🏁 Karma season {number} is over!",
+		"season.no_winners":             "Nobody got any karma this season.",
+		"season.reset":                  "Everyone starts season {number} from 0 karma.",
+		"season.carried":                "Everyone keeps {percent}% of their karma into season {number}.",
+		"season.bad_count":              "Give a number of seasons from 1 to {max}.",
+		"season.history_none":           "No karma season has ended in this group yet.",
+		"season.history_line":           "Season {number} ({from} – {to}): {champions}",
+		"season.history_no_champion":    "no champion",
+		"season.failed":                 "Failed to end the karma season. Please try again later.",
+		"triggers.none":                 "This group has no karma triggers of its own. +1 and -1 at the start of a reply always count.",
+		"triggers.builtin":              "+1 and -1 at the start of a reply always count too.",
+		"triggers.added":                "Karma trigger #{id} added. Matching replies give {value} karma.",
+		"triggers.duplicate":            "This group already has that karma trigger.",
+		"triggers.removed":              "Karma trigger #{id} removed.",
+		"triggers.not_found":            "Karma trigger #{id} not found in this group.",
+		"triggers.bad_value":            "The value must be between -{max} and {max}, and not 0.",
+		"triggers.bad_pattern":          "The pattern must have between 1 and {max} characters.",
+		"triggers.token_spaces":         "A token is a single word. Use a keyword trigger for phrases.",
+		"triggers.bad_regex":            "That regular expression doesn't work: {error}",
+		"triggers.failed":               "Failed to save the karma triggers. Please try again later.",
+	},
+	Plurals: map[string]Plural{
+		"leaderboard.loved":          {One: "Most loved user:", Other: "Most loved users (top {count}):"},
+		"leaderboard.loved_topic":    {One: "Most loved user in this topic:", Other: "Most loved users in this topic (top {count}):"},
+		"leaderboard.hated":          {One: "Most hated folk here:", Other: "Most hated folks here (top {count}):"},
+		"leaderboard.hated_topic":    {One: "Most hated folk in this topic:", Other: "Most hated folks in this topic (top {count}):"},
+		"leaderboard.generous":       {One: "Most generous user:", Other: "Most generous users (top {count}):"},
+		"leaderboard.generous_topic": {One: "Most generous user in this topic:", Other: "Most generous users in this topic (top {count}):"},
+		"leaderboard.critical":       {One: "Most critical user:", Other: "Most critical users (top {count}):"},
+		"leaderboard.critical_topic": {One: "Most critical user in this topic:", Other: "Most critical users in this topic (top {count}):"},
+		"events.header":              {One: "{count} active event:", Other: "{count} active events:"},
+		"triggers.header":            {One: "{count} karma trigger:", Other: "{count} karma triggers:"},
+		"rebuild.done":               {One: "Karma recounted. {count} user's totals changed.", Other: "Karma recounted. {count} users' totals changed."},
+		"bans.header":                {One: "{count} user with karma restrictions:", Other: "{count} users with karma restrictions:"},
+		"season.history_header":      {One: "Champion of the last season:", Other: "Champions of the last {count} seasons:"},
+		"karma.too_new":              {One: "You need to be in this group for {count} hour before giving karma.", Other: "You need to be in this group for {count} hours before giving karma."},
+		"karma.daily_cap":            {One: "You can give karma {count} time every 24 hours here. Try again later.", Other: "You can give karma {count} times every 24 hours here. Try again later."},
+		"profile.rank_tied":          {One: "Rank: #{rank} of {users}, tied with {count} other", Other: "Rank: #{rank} of {users}, tied with {count} others"},
+	},
+}
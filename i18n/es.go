@@ -0,0 +1,283 @@
+package i18n
+
+var spanish = Bundle{
+	IsOne: isOneExactly,
+	Messages: map[string]string{
+		"language.name": "Español",
+
+		"cmd.command.description":              "Mostrar la ayuda de los comandos",
+		"cmd.command.help":                     "Muestra este mensaje de ayuda con los detalles de cada comando.",
+		"cmd.new_event.description":            "Abrir el formulario de eventos",
+		"cmd.new_event.help":                   "Abre la Web App de eventos. Úsala para crear eventos, recordatorios o cumpleaños con un formulario.",
+		"cmd.ask_catholic_church.description":  "Preguntar sobre la enseñanza católica",
+		"cmd.ask_catholic_church.help":         "Le pregunta a Magisterium AI sobre la enseñanza católica y responde con lo que diga.",
+		"cmd.ask_catholic_church.example":      "/ask_catholic_church ¿Qué enseña la Iglesia sobre el perdón?",
+		"cmd.set_birthday.description":         "Responde con DD-MM-AAAA para guardar un cumpleaños",
+		"cmd.set_birthday.help":                "Crea un evento de cumpleaños anual. Úsalo respondiendo al mensaje de la persona para que el bot sepa de quién es el cumpleaños.",
+		"cmd.set_birthday.example":             "responde a María y envía /set_birthday 24-12-1990",
+		"cmd.set_birthday.failure":             "No se creó el cumpleaños. Inténtalo de nuevo más tarde.",
+		"cmd.show_events.description":          "Mostrar los eventos activos del grupo",
+		"cmd.show_events.help":                 "Muestra todos los eventos activos de este grupo con su ID, tipo, título y fecha.",
+		"cmd.delete_event.description":         "Eliminar un evento por ID (solo admins)",
+		"cmd.delete_event.help":                "Elimina un evento por su ID cuando lo confirmas con los botones.",
+		"cmd.delete_event.example":             "/delete_event 42",
+		"cmd.lovedusers.description":           "Mostrar a quienes tienen más karma positivo",
+		"cmd.lovedusers.help":                  "Muestra a los usuarios con más karma positivo en este chat. Agrega today, week, month o year para contar solo el karma recibido desde entonces, un número para mostrar hasta 50 usuarios, en un tema del foro, \"topic\" para contar solo el karma dado en ese tema, y \"weighted\" para ordenar por karma ponderado.",
+		"cmd.lovedusers.example":               "/lovedusers week 5",
+		"cmd.hatedusers.description":           "Mostrar a quienes tienen más karma negativo",
+		"cmd.hatedusers.help":                  "Muestra a los usuarios con más karma negativo en este chat. Agrega today, week, month o year para contar solo el karma recibido desde entonces, un número para mostrar hasta 50 usuarios, en un tema del foro, \"topic\" para contar solo el karma dado en ese tema, y \"weighted\" para ordenar por karma ponderado.",
+		"cmd.hatedusers.example":               "/hatedusers month topic",
+		"cmd.generoususers.description":        "Mostrar quién da más karma",
+		"cmd.generoususers.help":               "Muestra a los usuarios que más karma positivo dieron en este chat. Acepta las mismas opciones today, week, month o year, número y \"topic\" que /lovedusers.",
+		"cmd.generoususers.example":            "/generoususers year",
+		"cmd.criticalusers.description":        "Mostrar quién quita más karma",
+		"cmd.criticalusers.help":               "Muestra a los usuarios que más karma negativo dieron en este chat. Acepta las mismas opciones today, week, month o year, número y \"topic\" que /lovedusers.",
+		"cmd.criticalusers.example":            "/criticalusers week",
+		"cmd.language.description":             "Ver o cambiar el idioma del bot",
+		"cmd.language.help":                    "Muestra el idioma que usa el bot en este chat, o lo cambia. Con \"auto\" responde a cada quien en su idioma de Telegram. En grupos, solo los administradores pueden cambiarlo.",
+		"cmd.language.example":                 "/language es",
+		"cmd.karma.description":                "Ver el karma y el puesto de alguien",
+		"cmd.karma.help":                       "Muestra el karma, el puesto, el karma dado y quitado, quiénes más karma le dieron y su último karma: el tuyo, el de la persona a quien respondes o el de la persona que mencionas.",
+		"cmd.karma.example":                    "/karma @maria",
+		"cmd.karma_history.description":        "Ver el karma que alguien dio y recibió",
+		"cmd.karma_history.help":               "Muestra el último karma dado y recibido en este grupo por ti, por la persona a quien respondes o por la persona que mencionas.",
+		"cmd.karma_history.example":            "/karma_history @maria",
+		"cmd.undo_karma.description":           "Retirar el karma que acabas de dar",
+		"cmd.undo_karma.help":                  "Retira el karma de tu último mensaje de karma, si lo enviaste en los últimos 5 minutos.",
+		"cmd.rebuild_karma.description":        "Recontar el karma desde el historial (solo admins)",
+		"cmd.rebuild_karma.help":               "Vuelve a contar el karma, el karma dado y el karma quitado de todos en este grupo a partir del historial, corrigiendo totales desfasados.",
+		"cmd.karma_settings.description":       "Ver o cambiar las reglas del karma",
+		"cmd.karma_settings.help":              "Muestra las reglas de karma del grupo: esperas, límite diario, karma negativo, cuánto tiempo hay que llevar aquí para dar karma y si los bots pueden recibirlo. Los administradores pueden cambiar una agregando su nombre y el nuevo valor.",
+		"cmd.karma_settings.example":           "/karma_settings cooldown 120",
+		"cmd.season.description":               "Ver la temporada de karma actual",
+		"cmd.season.help":                      "Muestra la temporada de karma actual, cuándo empezó y cuándo termina, y su top 10. Los administradores pueden cerrarla ya con /season end: se archiva la clasificación final y se anuncian los ganadores. Las temporadas mensuales o trimestrales y cuánto karma se conserva se configuran con /karma_settings.",
+		"cmd.season.example":                   "/season",
+		"cmd.season_history.description":       "Ver los campeones de temporadas pasadas",
+		"cmd.season_history.help":              "Muestra los campeones de las últimas temporadas de karma, 5 salvo que indiques un número.",
+		"cmd.season_history.example":           "/season_history 10",
+		"cmd.karma_ban.description":            "Impedir que alguien dé karma (solo admins)",
+		"cmd.karma_ban.help":                   "Impide que la persona a quien respondes o mencionas dé karma en este grupo, por hasta un año (como 30m, 12h, 7d o 2w) o hasta usar /karma_unban.",
+		"cmd.karma_ban.example":                "/karma_ban @maria 7d",
+		"cmd.karma_mute_receive.description":   "Impedir que alguien reciba karma (solo admins)",
+		"cmd.karma_mute_receive.help":          "Impide que la persona a quien respondes o mencionas reciba karma en este grupo, por hasta un año (como 30m, 12h, 7d o 2w) o hasta usar /karma_unban.",
+		"cmd.karma_mute_receive.example":       "/karma_mute_receive @maria 12h",
+		"cmd.karma_unban.description":          "Quitar las restricciones de karma de alguien (solo admins)",
+		"cmd.karma_unban.help":                 "Permite que la persona a quien respondes o mencionas vuelva a dar y recibir karma.",
+		"cmd.karma_unban.example":              "/karma_unban @maria",
+		"cmd.karma_bans.description":           "Ver las restricciones de karma (solo admins)",
+		"cmd.karma_bans.help":                  "Muestra quién no puede dar o recibir karma en este grupo y hasta cuándo. Agrega \"log\" para ver los últimos cambios y qué administrador los hizo.",
+		"cmd.karma_bans.example":               "/karma_bans log",
+		"cmd.karma_flags.description":          "Ver karma marcado como posible abuso (solo admins)",
+		"cmd.karma_flags.help":                 "Muestra el último karma que marcaron los controles de abuso: gente intercambiando karma, dando karma a la misma persona demasiado seguido o varias personas quitándole karma a alguien a la vez, y qué pasó con él.",
+		"cmd.karma_approve.description":        "Contar karma pendiente de aprobación (solo admins)",
+		"cmd.karma_approve.help":               "Cuenta karma marcado que espera a un administrador, por el ID que muestra /karma_flags.",
+		"cmd.karma_approve.example":            "/karma_approve 12",
+		"cmd.karma_reject.description":         "Descartar karma pendiente de aprobación (solo admins)",
+		"cmd.karma_reject.help":                "Descarta karma marcado que espera a un administrador, por el ID que muestra /karma_flags.",
+		"cmd.karma_reject.example":             "/karma_reject 12",
+		"cmd.karma_triggers.description":       "Ver los disparadores de karma del grupo",
+		"cmd.karma_triggers.help":              "Muestra las palabras, emojis y patrones que dan o quitan karma en este grupo, con el ID para eliminarlos.",
+		"cmd.add_karma_trigger.description":    "Agregar un disparador de karma (solo admins)",
+		"cmd.add_karma_trigger.help":           "Hace que las respuestas que coincidan con un patrón den o quiten karma. Un token es una sola palabra como ++, un emoji se busca tal cual, un keyword es una lista de palabras separadas por comas y un regex es una expresión regular. La posición indica dónde debe aparecer: al inicio (start), en cualquier parte (anywhere) o como el mensaje completo (whole). No distingue mayúsculas.",
+		"cmd.add_karma_trigger.example":        "/add_karma_trigger keyword anywhere 1 gracias, thanks",
+		"cmd.remove_karma_trigger.description": "Eliminar un disparador de karma por ID (solo admins)",
+		"cmd.remove_karma_trigger.help":        "Elimina uno de los disparadores de karma del grupo. /karma_triggers muestra sus IDs.",
+		"cmd.remove_karma_trigger.example":     "/remove_karma_trigger 3",
+
+		"arg.command":             "comando",
+		"arg.question":            "pregunta",
+		"arg.date":                "DD-MM-AAAA",
+		"arg.id":                  "id",
+		"arg.user":                "@usuario",
+		"arg.user_duration":       "@usuario duración",
+		"arg.value":               "valor",
+		"arg.pattern":             "patrón",
+		"arg.count":               "cantidad",
+		"arg.leaderboard_options": "today|week|month|year|all cantidad topic",
+		"arg.weighted_options":    "today|week|month|year|all cantidad topic weighted",
+
+		"command.only_in_private":       "/{command} solo funciona en chats privados.",
+		"command.only_in_groups":        "/{command} solo funciona en grupos.",
+		"command.admin_check_failed":    "No pude verificar los permisos de administrador.",
+		"command.admin_only":            "Solo los administradores del grupo pueden usar /{command}.",
+		"command.admin_only_note":       "Solo los administradores del grupo pueden usar este comando.",
+		"command.usage":                 "Uso: {usage}",
+		"command.example":               "Ejemplo: {example}",
+		"command.unknown":               "No conozco el comando {command}. Envía /command para verlos todos.",
+		"command.help_header":           "Comandos disponibles del bot:",
+		"callback.expired":              "Este botón ya no funciona.",
+		"callback.failed":               "Algo salió mal. Inténtalo de nuevo más tarde.",
+		"language.current":              "Este chat usa {language}.",
+		"language.current_auto":         "Este chat le responde a cada quien en su idioma de Telegram. El tuyo es {language}.",
+		"language.set":                  "Idioma cambiado a {language}.",
+		"language.reset":                "Volveré a responder a cada quien en su idioma de Telegram.",
+		"language.admin_only":           "Solo los administradores del grupo pueden cambiar el idioma.",
+		"language.failed":               "No pude guardar el idioma. Inténtalo de nuevo más tarde.",
+		"karma.self":                    "Wew. No puedes darte karma a ti mismo, tontito ~",
+		"karma.sender_blocked":          "Lo siento bro, no puedes dar puntos de aura por aquí.",
+		"karma.receiver_blocked":        "Lo siento bro, esta persona no puede recibir puntos de aura.",
+		"karma.cooldown":                "Ups, todavía no puedes dar karma :(",
+		"karma.given":                   "Karma dado a {name}. Karma total: {total}",
+		"karma.taken":                   "Karma quitado a {name}. Karma total: {total}",
+		"karma.failed":                  "Error al dar karma",
+		"karma.abuse_ignored":           "El karma para {name} no se contó: parece intercambio de karma o un ataque en grupo. Se avisó a los administradores.",
+		"karma.abuse_pending":           "El karma para {name} espera la aprobación de un administrador (#{id}).",
+		"karma.abuse_reduced":           "El karma para {name} cuenta como {value} de karma ponderado tras una reducción. Karma total: {total}",
+		"karma.weighted":                "(cuenta como {value} ponderado)",
+		"karma.unknown_users":           "Todavía no conozco a {usernames}. Tienen que escribir aquí primero para que pueda darles karma.",
+		"karma.negative_disabled":       "El karma negativo está desactivado en este grupo.",
+		"karma.bots_blocked":            "Los bots no pueden recibir karma en este grupo.",
+		"karma.receiver_cooldown":       "Le diste karma a {names} hace poco. Inténtalo más tarde.",
+		"settings.header":               "Configuración del karma en este grupo:",
+		"settings.cooldown":             "segundos entre dos mensajes de karma de la misma persona",
+		"settings.receiver_cooldown":    "segundos antes de volver a darle karma a la misma persona, 0 para no limitar",
+		"settings.daily_cap":            "karma que una persona puede dar en 24 horas, 0 para no limitar",
+		"settings.negative":             "si cuentan el -1 y el resto del karma negativo",
+		"settings.min_member_hours":     "horas que alguien debe llevar aquí antes de dar karma",
+		"settings.bots":                 "si los bots pueden recibir karma",
+		"settings.season":               "cuándo terminan las temporadas de karma: manual (con /season end), monthly o quarterly",
+		"settings.season_carry":         "porcentaje del karma que se conserva en la siguiente temporada, 0 para empezar de 0",
+		"settings.abuse":                "qué pasa con el karma que parece abuso: off, report, ignore, reduce (requiere weighting activado) o approve",
+		"settings.abuse_window_hours":   "horas hacia atrás que miran los controles de intercambio y karma repetido",
+		"settings.abuse_pair_limit":     "karma que una persona puede dar a la misma persona en ese tiempo antes de marcarlo, 0 para no controlarlo",
+		"settings.abuse_burst_givers":   "personas quitándole karma a la misma persona a la vez que se marcan, al menos 2, o 0 para no controlarlo",
+		"settings.abuse_burst_minutes":  "minutos que cuentan como a la vez",
+		"settings.abuse_reduce_percent": "porcentaje del karma marcado que se cuenta con reduce",
+		"settings.weighting":            "ponderar el karma según el karma de quien lo da, su tiempo en el grupo y el karma que dio en el último día",
+		"settings.how_to_change":        "Los administradores pueden cambiar un valor con /karma_settings <ajuste> <valor>.",
+		"settings.admin_only":           "Solo los administradores del grupo pueden cambiar la configuración del karma.",
+		"settings.bad_number":           "{setting} acepta un número de {min} a {max}.",
+		"settings.bad_number_or_off":    "{setting} acepta 0 para desactivarlo o un número de {min} a {max}.",
+		"settings.bad_switch":           "{setting} acepta on u off.",
+		"settings.bad_choice":           "{setting} acepta uno de: {choices}.",
+		"settings.reduce_unweighted":    "abuse solo puede ser reduce con weighting activado. Actívalo primero con /karma_settings weighting on.",
+		"settings.weighting_reduces":    "weighting no se puede desactivar mientras abuse sea reduce. Elige antes otra acción para abuse.",
+		"settings.changed":              "{setting} ahora es {value}.",
+		"settings.failed":               "No pude guardar la configuración del karma. Inténtalo de nuevo más tarde.",
+		"leaderboard.topic_only":        "Los rankings por tema solo funcionan dentro de un tema del foro.",
+		"leaderboard.empty":             "Todavía no hay usuarios en este grupo.",
+		"leaderboard.empty_topic":       "Todavía no se ha dado karma en este tema.",
+		"leaderboard.empty_window":      "No se dio karma en este periodo.",
+		"leaderboard.window_today":      "Hoy",
+		"leaderboard.window_week":       "Esta semana",
+		"leaderboard.window_month":      "Este mes",
+		"leaderboard.window_year":       "Este año",
+		"leaderboard.weighted":          "Ponderado según quién dio el karma",
+		"leaderboard.no_weighting":      "Solo /lovedusers y /hatedusers se pueden ponderar.",
+		"leaderboard.unknown_user":      "Desconocido",
+		"events.query_failed":           "No pude obtener los eventos.",
+		"events.read_failed":            "No pude leer los eventos.",
+		"events.none":                   "No hay eventos activos en este grupo.",
+		"events.not_found":              "No encontré el evento #{id} en este grupo.",
+		"events.delete_failed":          "No pude eliminar el evento.",
+		"events.delete_confirm":         "¿Eliminar el evento #{id} \"{title}\"?",
+		"events.delete_button":          "Eliminar",
+		"events.cancel_button":          "Cancelar",
+		"events.delete_admin_only":      "Solo los administradores del grupo pueden eliminar eventos.",
+		"events.delete_cancelled":       "Se canceló la eliminación del evento #{id}.",
+		"events.deleted":                "Evento #{id} eliminado.",
+		"events.webapp_prompt":          "Crea un evento nuevo desde la Web App de Telegram.",
+		"events.webapp_button":          "Crear evento",
+		"events.webapp_link":            "Abre el formulario de eventos aquí: {url}",
+		"events.reminder":               "Recordatorio: {title}",
+		"birthday.no_sender":            "Necesito saber quién guarda el cumpleaños. Inténtalo de nuevo desde una cuenta de usuario normal.",
+		"birthday.no_reply":             "Responde al mensaje de alguien con /set_birthday DD-MM-AAAA.",
+		"birthday.usage":                "Usa /set_birthday DD-MM-AAAA. Ejemplo: /set_birthday 24-12-1990",
+		"birthday.title":                "¡Celebremos el cumpleaños de {name}! \U0001F382\U0001F389",
+		"birthday.description":          "¡No olvides desearle feliz cumpleaños a {name}!",
+		"birthday.day_before":           "Mañana es el cumpleaños de {name} \U0001F382",
+		"birthday.day_of":               "¡¡¡Feliz cumpleaños, {name}!!! \U0001F382\U0001F389\U0001F382",
+		"birthday.duplicate":            "Ya hay un cumpleaños guardado en este chat para el {date}.",
+		"birthday.created":              "Cumpleaños guardado \U00002705\nPersona: {name}\nFecha: {date}\nHora del recordatorio: {hour}:00 UTC\nID del evento: {id}\nLo recordaré en este chat cada año.",
+		"profile.none":                  "{name} todavía no tiene karma en este grupo.",
+		"profile.header":                "{name} tiene {karma} de karma.",
+		"profile.rank":                  "Puesto: #{rank} de {users}",
+		"profile.given_taken":           "Karma dado: {given} · Karma quitado: {taken}",
+		"profile.top_givers":            "Quienes más karma le dieron: {givers}",
+		"profile.last_karma":            "Último karma recibido: {date}",
+		"history.header":                "Último karma de {name} (UTC):",
+		"history.none":                  "{name} todavía no ha dado ni recibido karma aquí.",
+		"history.undo":                  "(retirado)",
+		"undo.nothing":                  "No has dado karma en los últimos {minutes} minutos que se pueda retirar.",
+		"undo.done":                     "Retiré {value} de karma a {name}. Karma total: {total}",
+		"undo.failed":                   "No pude retirar el karma. Inténtalo de nuevo más tarde.",
+		"rebuild.failed":                "No pude recontar el karma. Inténtalo de nuevo más tarde.",
+		"magisterium.usage":             "Usa /ask_catholic_church seguido de tu pregunta. Ejemplo: /ask_catholic_church ¿Qué enseña la Iglesia sobre el perdón?",
+		"magisterium.unavailable":       "No pude obtener una respuesta de Magisterium AI en este momento. Inténtalo de nuevo más tarde.",
+		"magisterium.sources":           "Fuentes:",
+		"magisterium.unknown_source":    "Fuente católica",
+		"bans.ban":                      "{name} no puede dar karma aquí {until}.",
+		"bans.mute_receive":             "{name} no puede recibir karma aquí {until}.",
+		"bans.unban":                    "{name} ya puede dar y recibir karma otra vez.",
+		"bans.until":                    "hasta el {date} UTC",
+		"bans.no_end":                   "hasta que un administrador lo quite",
+		"bans.none":                     "Nadie tiene restricciones de karma en este grupo.",
+		"bans.no_give":                  "no puede dar karma {until}",
+		"bans.no_receive":               "no puede recibir karma {until}",
+		"bans.log_none":                 "Todavía no se han cambiado restricciones de karma en este grupo.",
+		"bans.log_header":               "Últimos cambios de restricciones de karma (UTC):",
+		"bans.log_ban":                  "{admin} impidió que {name} dé karma {until}",
+		"bans.log_mute_receive":         "{admin} impidió que {name} reciba karma {until}",
+		"bans.log_unban":                "{admin} quitó las restricciones de karma de {name}",
+		"bans.failed":                   "No pude cambiar las restricciones de karma. Inténtalo de nuevo más tarde.",
+		"abuse.report":                  "⚠️ Posible abuso de karma en {chat}:\n{flag}\n\nMira /karma_flags en el grupo.",
+		"abuse.rule_reciprocal":         "{giver} y {receiver} se intercambian karma una y otra vez",
+		"abuse.rule_repeated":           "{giver} da karma a {receiver} una y otra vez",
+		"abuse.rule_burst":              "varias personas le quitaron karma a {receiver} a la vez, la última {giver}",
+		"abuse.status_counted":          "contado",
+		"abuse.status_ignored":          "no contado",
+		"abuse.status_reduced":          "contado como {applied} ponderado",
+		"abuse.status_pending":          "esperando /karma_approve {id} o /karma_reject {id}",
+		"abuse.status_approved":         "aprobado por {admin}",
+		"abuse.status_rejected":         "rechazado por {admin}",
+		"abuse.none":                    "No se ha marcado karma en este grupo.",
+		"abuse.header":                  "Último karma marcado como posible abuso:",
+		"abuse.not_pending":             "No hay karma con el ID {id} esperando aprobación.",
+		"abuse.approved":                "Aprobado #{id}. {name} tiene ahora {total} de karma.",
+		"abuse.rejected":                "Rechazado #{id}.",
+		"abuse.failed":                  "No pude revisar el karma marcado. Inténtalo de nuevo más tarde.",
+		"season.current":                "Temporada de karma {number}, desde el {date}.",
+		"season.ends":                   "Termina el {date}.",
+		"season.ends_manual":            "Termina cuando un administrador la cierre con /season end.",
+		"season.no_standings":           "Nadie tiene karma en esta temporada todavía.",
+		"season.admin_only":             "Solo los administradores del grupo pueden terminar la temporada.",
+		"season.ended":                  "🏁 ¡Terminó la temporada de karma {number}!",
+		"season.no_winners":             "Nadie recibió karma en esta temporada.",
+		"season.reset":                  "Todos empiezan la temporada {number} con 0 de karma.",
+		"season.carried":                "Todos conservan el {percent}% de su karma en la temporada {number}.",
+		"season.bad_count":              "Indica un número de temporadas entre 1 y {max}.",
+		"season.history_none":           "Todavía no ha terminado ninguna temporada de karma en este grupo.",
+		"season.history_line":           "Temporada {number} ({from} – {to}): {champions}",
+		"season.history_no_champion":    "sin campeón",
+		"season.failed":                 "No pude terminar la temporada de karma. Inténtalo de nuevo más tarde.",
+		"triggers.none":                 "Este grupo no tiene disparadores de karma propios. +1 y -1 al inicio de una respuesta siempre cuentan.",
+		"triggers.builtin":              "+1 y -1 al inicio de una respuesta también cuentan siempre.",
+		"triggers.added":                "Disparador de karma #{id} agregado. Las respuestas que coincidan dan {value} de karma.",
+		"triggers.duplicate":            "Este grupo ya tiene ese disparador de karma.",
+		"triggers.removed":              "Disparador de karma #{id} eliminado.",
+		"triggers.not_found":            "No encontré el disparador de karma #{id} en este grupo.",
+		"triggers.bad_value":            "El valor debe estar entre -{max} y {max}, y no puede ser 0.",
+		"triggers.bad_pattern":          "El patrón debe tener entre 1 y {max} caracteres.",
+		"triggers.token_spaces":         "Un token es una sola palabra. Usa un disparador keyword para frases.",
+		"triggers.bad_regex":            "Esa expresión regular no funciona: {error}",
+		"triggers.failed":               "No pude guardar los disparadores de karma. Inténtalo de nuevo más tarde.",
+	},
+	Plurals: map[string]Plural{
+		"leaderboard.loved":          {One: "Usuario más querido:", Other: "Usuarios más queridos (top {count}):"},
+		"leaderboard.loved_topic":    {One: "Usuario más querido en este tema:", Other: "Usuarios más queridos en este tema (top {count}):"},
+		"leaderboard.hated":          {One: "La persona más odiada aquí:", Other: "Los más odiados de aquí (top {count}):"},
+		"leaderboard.hated_topic":    {One: "La persona más odiada en este tema:", Other: "Los más odiados en este tema (top {count}):"},
+		"leaderboard.generous":       {One: "El usuario más generoso:", Other: "Los usuarios más generosos (top {count}):"},
+		"leaderboard.generous_topic": {One: "El usuario más generoso en este tema:", Other: "Los usuarios más generosos en este tema (top {count}):"},
+		"leaderboard.critical":       {One: "El usuario más crítico:", Other: "Los usuarios más críticos (top {count}):"},
+		"leaderboard.critical_topic": {One: "El usuario más crítico en este tema:", Other: "Los usuarios más críticos en este tema (top {count}):"},
+		"events.header":              {One: "{count} evento activo:", Other: "{count} eventos activos:"},
+		"triggers.header":            {One: "{count} disparador de karma:", Other: "{count} disparadores de karma:"},
+		"rebuild.done":               {One: "Karma recontado. Cambiaron los totales de {count} usuario.", Other: "Karma recontado. Cambiaron los totales de {count} usuarios."},
+		"bans.header":                {One: "{count} usuario con restricciones de karma:", Other: "{count} usuarios con restricciones de karma:"},
+		"season.history_header":      {One: "Campeón de la última temporada:", Other: "Campeones de las últimas {count} temporadas:"},
+		"karma.too_new":              {One: "Tienes que llevar {count} hora en este grupo antes de dar karma.", Other: "Tienes que llevar {count} horas en este grupo antes de dar karma."},
+		"karma.daily_cap":            {One: "Aquí puedes dar karma {count} vez cada 24 horas. Inténtalo más tarde.", Other: "Aquí puedes dar karma {count} veces cada 24 horas. Inténtalo más tarde."},
+		"profile.rank_tied":          {One: "Puesto: #{rank} de {users}, empatado con {count} persona más", Other: "Puesto: #{rank} de {users}, empatado con {count} personas más"},
+	},
+}
@@ -4,7 +4,13 @@ import (
 	"bot/telegram/config"
 	"bot/telegram/errors"
 	"bot/telegram/services"
+	"context"
+	stdErrors "errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -24,17 +30,117 @@ func main() {
 		return
 	}
 
+	// Commands addressed to other bots are told apart by our username.
+	if bot, err := services.BotUser(); err != nil {
+		fmt.Printf("Failed to get the bot's own user: %s\n", err)
+	} else {
+		fmt.Printf("Running as @%s\n", bot.Username)
+	}
+
 	if err := services.RegisterBotCommands(); err != nil {
 		fmt.Printf("Failed to register bot commands: %s\n", err)
 	} else {
 		fmt.Println("Bot commands registered")
 	}
 
-	offset := 0
+	switch env.UpdateMode {
+	case config.UpdateModeWebhook:
+		runWebhook(env)
+	default:
+		runPolling(env)
+	}
+}
+
+func runPolling(env config.Env) {
+	// getUpdates is rejected while a webhook is set, so clear any webhook left
+	// over from a previous webhook deployment.
+	if err := services.DeleteWebhook(); err != nil {
+		fmt.Printf("Failed to delete webhook: %s\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	offset := loadUpdateOffset(env)
+	dispatcher := services.NewUpdateDispatcher(env.DBName, env.UpdateWorkers, offset)
 
 	// Main processing loop with connection recovery
+	for ctx.Err() == nil {
+		newOffset, err := services.ProcessTelegramMessages(ctx, dispatcher, offset)
+
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("Error processing Telegram messages: %s\n", err)
+
+			// Log the error to database if possible
+			recordPollingError(env, err)
+
+			// If it's a network-related error, wait longer before retrying
+			if errors.IsNetworkError(err) {
+				fmt.Println("Network error detected, waiting 10 seconds before retry...")
+				sleepContext(ctx, 10*time.Second)
+			} else {
+				sleepContext(ctx, 1*time.Second)
+			}
+			continue
+		}
+
+		if newOffset != offset {
+			saveUpdateOffset(env, newOffset)
+			offset = newOffset
+		}
+
+		sleepContext(ctx, 1*time.Second)
+	}
+
+	fmt.Println("Stopping, waiting for in-flight updates...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := dispatcher.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("Failed to stop update dispatcher cleanly: %s\n", err)
+	}
+	saveUpdateOffset(env, dispatcher.Watermark())
+}
+
+func recordPollingError(env config.Env, err error) {
+	conn, connErr := services.GlobalPoolManager.GetConnectionFromPool(env.DBName)
+	if connErr != nil {
+		fmt.Printf("Failed to log error to database: %s\n", connErr)
+		return
+	}
+	defer conn.Release()
+
+	if dbErr := errors.CreateErrorRecord(conn, errors.ErrorRecordInput{Error: err.Error()}); dbErr != nil {
+		fmt.Printf("Failed to log error to database: %s\n", dbErr)
+	}
+}
+
+func saveUpdateOffset(env config.Env, offset int) {
+	conn, err := services.GlobalPoolManager.GetConnectionFromPool(env.DBName)
+	if err != nil {
+		fmt.Printf("Failed to save update offset: %s\n", err)
+		return
+	}
+	defer conn.Release()
+
+	if err := services.SaveUpdateOffset(context.Background(), conn, offset); err != nil {
+		fmt.Printf("Failed to save update offset: %s\n", err)
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// loadUpdateOffset resumes polling where the last run stopped, waiting for the
+// database if it is not reachable yet.
+func loadUpdateOffset(env config.Env) int {
 	for {
-		// Get a connection from the pool
 		conn, err := services.GlobalPoolManager.GetConnectionFromPool(env.DBName)
 		if err != nil {
 			fmt.Printf("Failed to get database connection: %s. Retrying in 30 seconds...\n", err)
@@ -42,36 +148,69 @@ func main() {
 			continue
 		}
 
-		// Process Telegram messages
-		pgConn := conn.Conn()
-		newOffset, err := services.ProcessTelegramMessages(env.TelegramBaseURL, env.Token, offset, pgConn)
-
+		offset, err := services.LoadUpdateOffset(context.Background(), conn)
+		conn.Release()
 		if err != nil {
-			fmt.Printf("Error processing Telegram messages: %s\n", err)
+			fmt.Printf("Failed to load update offset: %s. Retrying in 30 seconds...\n", err)
+			time.Sleep(30 * time.Second)
+			continue
+		}
 
-			// Log the error to database if possible
-			errorInput := errors.ErrorRecordInput{
-				Error: err.Error(),
-			}
-			if dbErr := errors.CreateErrorRecord(pgConn, errorInput); dbErr != nil {
-				fmt.Printf("Failed to log error to database: %s\n", dbErr)
-			}
+		return offset
+	}
+}
+
+func runWebhook(env config.Env) {
+	dispatcher := services.NewUpdateDispatcher(env.DBName, env.UpdateWorkers, 0)
+
+	mux := http.NewServeMux()
+	mux.Handle(env.WebhookPath, services.NewWebhookHandler(env.WebhookSecretToken, dispatcher))
+
+	server := &http.Server{
+		Addr:              env.WebhookListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("Webhook server listening on %s%s\n", env.WebhookListenAddr, env.WebhookPath)
+		if err := server.ListenAndServe(); err != nil && !stdErrors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
+		close(serverErr)
+	}()
 
-		conn.Release()
+	if err := services.SetWebhook(env.WebhookURL, env.WebhookSecretToken); err != nil {
+		fmt.Printf("Failed to set webhook: %s\n", err)
+		_ = server.Close()
+		return
+	}
+	fmt.Println("Webhook registered")
 
-		if err != nil {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
-			// If it's a network-related error, wait longer before retrying
-			if errors.IsNetworkError(err) {
-				fmt.Println("Network error detected, waiting 10 seconds before retry...")
-				time.Sleep(10 * time.Second)
-			} else {
-				time.Sleep(1 * time.Second)
-			}
-		} else {
-			offset = newOffset
-			time.Sleep(1 * time.Second)
+	select {
+	case <-stop:
+	case err := <-serverErr:
+		if err != nil {
+			fmt.Printf("Webhook server failed: %s\n", err)
 		}
 	}
+
+	// Telegram queues updates while no webhook is set and hands them to the
+	// next setWebhook or getUpdates caller, so nothing is lost across restarts.
+	if err := services.DeleteWebhook(); err != nil {
+		fmt.Printf("Failed to delete webhook: %s\n", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("Failed to stop webhook server cleanly: %s\n", err)
+	}
+	if err := dispatcher.Shutdown(ctx); err != nil {
+		fmt.Printf("Failed to stop update dispatcher cleanly: %s\n", err)
+	}
 }
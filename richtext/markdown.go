@@ -0,0 +1,404 @@
+// Package richtext turns the Markdown subset chat models answer with into
+// Telegram's HTML or MarkdownV2, and splits long messages the way Telegram
+// measures them.
+package richtext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type nodeKind int
+
+const (
+	nodeText nodeKind = iota
+	nodeBold
+	nodeItalic
+	nodeStrike
+	nodeCode
+	nodeLink
+)
+
+type node struct {
+	kind     nodeKind
+	text     string
+	href     string
+	children []node
+}
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockQuote
+	blockBullet
+	blockNumbered
+	blockCode
+)
+
+type block struct {
+	kind blockKind
+	// lines holds the inline Markdown of each line, or the raw code lines.
+	lines []string
+	// marker is the number of a numbered list item.
+	marker   string
+	language string
+}
+
+var (
+	headingPattern  = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+	bulletPattern   = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	numberedPattern = regexp.MustCompile(`^\s*(\d{1,9})[.)]\s+(.*)$`)
+	quotePattern    = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	fencePattern    = regexp.MustCompile("^\\s*(```|~~~)\\s*([\\w+-]*)\\s*$")
+	rulePattern     = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+)
+
+// Link schemes Telegram accepts in <a href>. Anything else is shown as text.
+var allowedLinkSchemes = []string{"http://", "https://", "tg://", "mailto:"}
+
+// ToHTML renders Markdown as Telegram HTML: bold, italics, strikethrough,
+// inline and fenced code, links, headings, lists and quotes. Everything else
+// is escaped, so the result is always safe to send with parse_mode HTML.
+func ToHTML(markdown string) string {
+	var out []string
+	for _, b := range parseBlocks(markdown) {
+		switch b.kind {
+		case blockCode:
+			code := html.EscapeString(strings.Join(b.lines, "\n"))
+			if b.language != "" {
+				out = append(out, `<pre><code class="language-`+html.EscapeString(b.language)+`">`+code+"</code></pre>")
+			} else {
+				out = append(out, "<pre>"+code+"</pre>")
+			}
+		case blockHeading:
+			out = append(out, "<b>"+inlineHTML(parseInline(b.lines[0]))+"</b>")
+		case blockQuote:
+			lines := make([]string, len(b.lines))
+			for i, line := range b.lines {
+				lines[i] = inlineHTML(parseInline(line))
+			}
+			out = append(out, "<blockquote>"+strings.Join(lines, "\n")+"</blockquote>")
+		case blockBullet:
+			out = append(out, "• "+inlineHTML(parseInline(b.lines[0])))
+		case blockNumbered:
+			out = append(out, b.marker+". "+inlineHTML(parseInline(b.lines[0])))
+		default:
+			out = append(out, inlineHTML(parseInline(b.lines[0])))
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// ToMarkdownV2 renders the same subset as ToHTML for parse_mode MarkdownV2,
+// escaping every character Telegram reserves.
+func ToMarkdownV2(markdown string) string {
+	var out []string
+	for _, b := range parseBlocks(markdown) {
+		switch b.kind {
+		case blockCode:
+			out = append(out, "```"+b.language+"\n"+escapeMarkdownV2Code(strings.Join(b.lines, "\n"))+"\n```")
+		case blockHeading:
+			out = append(out, "*"+inlineMarkdownV2(parseInline(b.lines[0]))+"*")
+		case blockQuote:
+			lines := make([]string, len(b.lines))
+			for i, line := range b.lines {
+				lines[i] = ">" + inlineMarkdownV2(parseInline(line))
+			}
+			out = append(out, strings.Join(lines, "\n"))
+		case blockBullet:
+			out = append(out, "• "+inlineMarkdownV2(parseInline(b.lines[0])))
+		case blockNumbered:
+			out = append(out, b.marker+"\\. "+inlineMarkdownV2(parseInline(b.lines[0])))
+		default:
+			out = append(out, inlineMarkdownV2(parseInline(b.lines[0])))
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func parseBlocks(markdown string) []block {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	var blocks []block
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if fence := fencePattern.FindStringSubmatch(line); fence != nil {
+			code := block{kind: blockCode, language: fence[2]}
+			for i++; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) == fence[1] {
+					break
+				}
+				code.lines = append(code.lines, lines[i])
+			}
+			blocks = append(blocks, code)
+			continue
+		}
+
+		if quote := quotePattern.FindStringSubmatch(line); quote != nil {
+			if n := len(blocks); n > 0 && blocks[n-1].kind == blockQuote {
+				blocks[n-1].lines = append(blocks[n-1].lines, quote[1])
+			} else {
+				blocks = append(blocks, block{kind: blockQuote, lines: []string{quote[1]}})
+			}
+			continue
+		}
+
+		switch {
+		case rulePattern.MatchString(line):
+			blocks = append(blocks, block{kind: blockParagraph, lines: []string{"──────────"}})
+		case headingPattern.MatchString(line):
+			blocks = append(blocks, block{kind: blockHeading, lines: []string{headingPattern.FindStringSubmatch(line)[1]}})
+		case bulletPattern.MatchString(line):
+			blocks = append(blocks, block{kind: blockBullet, lines: []string{bulletPattern.FindStringSubmatch(line)[1]}})
+		case numberedPattern.MatchString(line):
+			match := numberedPattern.FindStringSubmatch(line)
+			blocks = append(blocks, block{kind: blockNumbered, marker: match[1], lines: []string{match[2]}})
+		default:
+			blocks = append(blocks, block{kind: blockParagraph, lines: []string{line}})
+		}
+	}
+
+	return blocks
+}
+
+// parseInline splits a line into formatted runs. Delimiters without a match
+// stay as literal text.
+func parseInline(s string) []node {
+	var nodes []node
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, node{kind: nodeText, text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+
+		if rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_~[]()#>-+.!|{}=", rune(rest[1])) {
+			text.WriteByte(rest[1])
+			i += 2
+			continue
+		}
+
+		if rest[0] == '`' {
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				flush()
+				nodes = append(nodes, node{kind: nodeCode, text: rest[1 : end+1]})
+				i += end + 2
+				continue
+			}
+		}
+
+		if strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__") || strings.HasPrefix(rest, "~~") {
+			delimiter := rest[:2]
+			if delimiter != "__" || !isWordBefore(s, i) {
+				if end := strings.Index(rest[2:], delimiter); end > 0 && !unicode.IsSpace(rune(rest[2])) {
+					kind := nodeBold
+					if delimiter == "~~" {
+						kind = nodeStrike
+					}
+					flush()
+					nodes = append(nodes, node{kind: kind, children: parseInline(rest[2 : end+2])})
+					i += end + 4
+					continue
+				}
+			}
+		}
+
+		if (rest[0] == '*' || rest[0] == '_') && len(rest) > 1 && !unicode.IsSpace(rune(rest[1])) {
+			delimiter := rest[0]
+			if delimiter != '_' || !isWordBefore(s, i) {
+				if end := closingSingleDelimiter(rest[1:], delimiter); end > 0 {
+					flush()
+					nodes = append(nodes, node{kind: nodeItalic, children: parseInline(rest[1 : end+1])})
+					i += end + 2
+					continue
+				}
+			}
+		}
+
+		if rest[0] == '[' {
+			if label, href, length, ok := parseLink(rest); ok {
+				flush()
+				if hasAllowedScheme(href) {
+					nodes = append(nodes, node{kind: nodeLink, href: href, children: parseInline(label)})
+				} else {
+					nodes = append(nodes, parseInline(label)...)
+				}
+				i += length
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		text.WriteString(rest[:size])
+		i += size
+	}
+
+	flush()
+	return nodes
+}
+
+// closingSingleDelimiter finds the * or _ that closes an italic run, skipping
+// doubled delimiters that belong to a nested bold run.
+func closingSingleDelimiter(s string, delimiter byte) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] != delimiter {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == delimiter {
+			i++
+			continue
+		}
+		if unicode.IsSpace(rune(s[i-1])) {
+			continue
+		}
+		if delimiter == '_' && isWordRune(s[i+1:]) {
+			continue
+		}
+		return i
+	}
+
+	return -1
+}
+
+func parseLink(s string) (label string, href string, length int, ok bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				if i+1 >= len(s) || s[i+1] != '(' {
+					return "", "", 0, false
+				}
+				end := closingParen(s[i+2:])
+				if end < 0 {
+					return "", "", 0, false
+				}
+				return s[1:i], strings.TrimSpace(s[i+2 : i+2+end]), i + 3 + end, true
+			}
+		}
+	}
+
+	return "", "", 0, false
+}
+
+// closingParen finds the ) that ends a link target, allowing balanced
+// parentheses inside URLs such as Wikipedia's.
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+
+	return -1
+}
+
+func hasAllowedScheme(href string) bool {
+	lower := strings.ToLower(href)
+	for _, scheme := range allowedLinkSchemes {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isWordBefore(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWordRune(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func inlineHTML(nodes []node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeBold:
+			b.WriteString("<b>" + inlineHTML(n.children) + "</b>")
+		case nodeItalic:
+			b.WriteString("<i>" + inlineHTML(n.children) + "</i>")
+		case nodeStrike:
+			b.WriteString("<s>" + inlineHTML(n.children) + "</s>")
+		case nodeCode:
+			b.WriteString("<code>" + html.EscapeString(n.text) + "</code>")
+		case nodeLink:
+			b.WriteString(`<a href="` + html.EscapeString(n.href) + `">` + inlineHTML(n.children) + "</a>")
+		default:
+			b.WriteString(html.EscapeString(n.text))
+		}
+	}
+
+	return b.String()
+}
+
+func inlineMarkdownV2(nodes []node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeBold:
+			b.WriteString("*" + inlineMarkdownV2(n.children) + "*")
+		case nodeItalic:
+			b.WriteString("_" + inlineMarkdownV2(n.children) + "_")
+		case nodeStrike:
+			b.WriteString("~" + inlineMarkdownV2(n.children) + "~")
+		case nodeCode:
+			b.WriteString("`" + escapeMarkdownV2Code(n.text) + "`")
+		case nodeLink:
+			href := strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(n.href)
+			b.WriteString("[" + inlineMarkdownV2(n.children) + "](" + href + ")")
+		default:
+			b.WriteString(EscapeMarkdownV2(n.text))
+		}
+	}
+
+	return b.String()
+}
+
+// EscapeMarkdownV2 escapes plain text for parse_mode MarkdownV2.
+func EscapeMarkdownV2(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("\\_*[]()~`>#+-=|{}.!", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func escapeMarkdownV2Code(code string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(code)
+}
@@ -0,0 +1,184 @@
+package richtext
+
+import (
+	"html"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MessageLimit is the longest message text Telegram accepts, in UTF-16 code
+// units of the text left after entities are parsed.
+const MessageLimit = 4096
+
+// UTF16Len returns the length of s as Telegram counts it.
+func UTF16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+
+	return n
+}
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenOpenTag
+	tokenCloseTag
+)
+
+// token is an indivisible piece of a message: a tag, an entity or one rune.
+// Splitting only ever happens between tokens.
+type token struct {
+	kind tokenKind
+	raw  string
+	// name is the tag name of open and close tags.
+	name string
+	// width is the visible length in UTF-16 code units.
+	width int
+}
+
+// SplitHTML cuts Telegram HTML into chunks of at most limit visible UTF-16
+// code units. It prefers to cut at line breaks, never cuts inside a tag, an
+// entity or a surrogate pair, and closes the tags open at a cut and reopens
+// them at the start of the next chunk.
+func SplitHTML(text string, limit int) []string {
+	return split(tokenizeHTML(text), limit)
+}
+
+// SplitText does the same for plain text.
+func SplitText(text string, limit int) []string {
+	tokens := make([]token, 0, len(text))
+	for _, r := range text {
+		tokens = append(tokens, token{kind: tokenText, raw: string(r), width: utf16.RuneLen(r)})
+	}
+
+	return split(tokens, limit)
+}
+
+func split(tokens []token, limit int) []string {
+	var chunks []string
+	var open []token
+
+	for start := 0; start < len(tokens); {
+		end, openAtEnd := chunkEnd(tokens, start, open, limit)
+
+		var b strings.Builder
+		visible := false
+		for _, tag := range open {
+			b.WriteString(tag.raw)
+		}
+		for _, t := range tokens[start:end] {
+			b.WriteString(t.raw)
+			if t.kind == tokenText && strings.TrimSpace(t.raw) != "" {
+				visible = true
+			}
+		}
+		for i := len(openAtEnd) - 1; i >= 0; i-- {
+			b.WriteString("</" + openAtEnd[i].name + ">")
+		}
+
+		if visible {
+			chunks = append(chunks, strings.TrimSpace(b.String()))
+		}
+
+		open = openAtEnd
+		start = end
+	}
+
+	return chunks
+}
+
+// chunkEnd finds where the chunk starting at start ends and which tags are
+// still open there. It cuts after the last line break, else after the last
+// space, as long as that keeps at least half the chunk; otherwise it cuts
+// right at the limit.
+func chunkEnd(tokens []token, start int, open []token, limit int) (int, []token) {
+	stack := append([]token(nil), open...)
+	width := 0
+	lineBreak := breakPoint{end: -1}
+	spaceBreak := breakPoint{end: -1}
+
+	for i := start; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.width > 0 && width+t.width > limit && i > start {
+			for _, candidate := range []breakPoint{lineBreak, spaceBreak} {
+				if candidate.end > start && candidate.width*2 >= limit {
+					return candidate.end, candidate.stack
+				}
+			}
+			return i, stack
+		}
+
+		switch t.kind {
+		case tokenOpenTag:
+			stack = append(stack, t)
+		case tokenCloseTag:
+			for j := len(stack) - 1; j >= 0; j-- {
+				if stack[j].name == t.name {
+					stack = append(stack[:j:j], stack[j+1:]...)
+					break
+				}
+			}
+		}
+		width += t.width
+
+		switch t.raw {
+		case "\n":
+			lineBreak = breakPoint{end: i + 1, width: width, stack: append([]token(nil), stack...)}
+		case " ":
+			spaceBreak = breakPoint{end: i + 1, width: width, stack: append([]token(nil), stack...)}
+		}
+	}
+
+	return len(tokens), stack
+}
+
+type breakPoint struct {
+	end   int
+	width int
+	stack []token
+}
+
+func tokenizeHTML(text string) []token {
+	var tokens []token
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		if rest[0] == '<' {
+			if end := strings.IndexByte(rest, '>'); end > 0 {
+				raw := rest[:end+1]
+				inner := strings.TrimSpace(raw[1:end])
+				kind := tokenOpenTag
+				if strings.HasPrefix(inner, "/") {
+					kind = tokenCloseTag
+					inner = strings.TrimSpace(inner[1:])
+				}
+				if fields := strings.Fields(inner); len(fields) > 0 {
+					tokens = append(tokens, token{kind: kind, raw: raw, name: strings.ToLower(fields[0])})
+					i += end + 1
+					continue
+				}
+			}
+		}
+
+		if rest[0] == '&' {
+			if end := strings.IndexByte(rest, ';'); end > 0 && end <= 10 {
+				raw := rest[:end+1]
+				if decoded := html.UnescapeString(raw); decoded != raw {
+					tokens = append(tokens, token{kind: tokenText, raw: raw, width: UTF16Len(decoded)})
+					i += end + 1
+					continue
+				}
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		tokens = append(tokens, token{kind: tokenText, raw: rest[:size], width: utf16.RuneLen(r)})
+		i += size
+	}
+
+	return tokens
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
 	"bot/telegram/structs"
 	"context"
 	"errors"
@@ -8,44 +10,42 @@ import (
 	"strings"
 	"time"
 
-	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
-const setBirthdayCommand = "/set_birthday"
 const birthdayReminderHourUTC = 13
 
-func isSetBirthdayCommand(text string) bool {
-	return isBotCommand(text, setBirthdayCommand)
-}
-
-func SetBirthdayFromCommand(conn *pgx.Conn, update structs.Update) error {
+func SetBirthdayFromCommand(conn shared.DBTX, update structs.Update, lang string) error {
 	message := update.Message
 	if message == nil {
 		return nil
 	}
 
 	chatID := message.Chat.ID
+	threadID := messageThreadID(message)
 	if message.From == nil {
-		return SendMessageWithReply(chatID, message.MessageID, "I need to know who is setting the birthday. Try again from a normal user account.")
+		return SendMessageWithReply(chatID, threadID, message.MessageID, i18n.T(lang, "birthday.no_sender", nil))
 	}
 
 	if message.ReplyToMessage == nil || message.ReplyToMessage.From == nil {
-		return SendMessageWithReply(chatID, message.MessageID, "Reply to someone's message with /set_birthday DD-MM-YYYY.")
+		return SendMessageWithReply(chatID, threadID, message.MessageID, i18n.T(lang, "birthday.no_reply", nil))
 	}
 
 	birthday, err := parseBirthdayCommandDate(message.Text)
 	if err != nil {
-		return SendMessageWithReply(chatID, message.MessageID, "Use /set_birthday DD-MM-YYYY. Example: /set_birthday 24-12-1990")
+		return SendMessageWithReply(chatID, threadID, message.MessageID, i18n.T(lang, "birthday.usage", nil))
 	}
 
 	targetUser := message.ReplyToMessage.From
 	targetName := telegramUserDisplayName(targetUser)
 	nextRunAt := nextBirthdayRunAt(birthday, time.Now().UTC())
-	title := fmt.Sprintf("Celebrate %s's birthday! \U0001F382\U0001F389", targetName)
-	description := fmt.Sprintf("Don't forget to wish %s a happy birthday!", targetName)
-	dayBeforeMessage := fmt.Sprintf("Tomorrow is %s's birthday \U0001F382", targetName)
-	dayOfMessage := fmt.Sprintf("Happy Birthday, %s!!! \U0001F382\U0001F389\U0001F382", targetName)
+	// The reminder texts are stored with the event, so they stay in the
+	// language the birthday was saved in.
+	nameArgs := i18n.Args{"name": targetName}
+	title := i18n.T(lang, "birthday.title", nameArgs)
+	description := i18n.T(lang, "birthday.description", nameArgs)
+	dayBeforeMessage := i18n.T(lang, "birthday.day_before", nameArgs)
+	dayOfMessage := i18n.T(lang, "birthday.day_of", nameArgs)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -60,6 +60,7 @@ func SetBirthdayFromCommand(conn *pgx.Conn, update structs.Update) error {
 	if err := tx.QueryRow(ctx, `
 		INSERT INTO events (
 			chat_id,
+			message_thread_id,
 			created_by_user_id,
 			target_user_id,
 			type,
@@ -69,10 +70,11 @@ func SetBirthdayFromCommand(conn *pgx.Conn, update structs.Update) error {
 			event_date,
 			timezone,
 			is_active
-		) VALUES ($1,$2,$3,'birthday',$4,$5,TRUE,$6,'UTC',TRUE)
+		) VALUES ($1,$2,$3,$4,'birthday',$5,$6,TRUE,$7,'UTC',TRUE)
 		RETURNING id
 	`,
 		chatID,
+		nullableThreadID(threadID),
 		message.From.ID,
 		targetUser.ID,
 		title,
@@ -82,8 +84,9 @@ func SetBirthdayFromCommand(conn *pgx.Conn, update structs.Update) error {
 		if isUniqueBirthdayConstraintError(err) {
 			return SendMessageWithReply(
 				chatID,
+				threadID,
 				message.MessageID,
-				fmt.Sprintf("A birthday is already saved for this chat on %s.", birthday.Format("02-01-2006")),
+				i18n.T(lang, "birthday.duplicate", i18n.Args{"date": birthday.Format("02-01-2006")}),
 			)
 		}
 
@@ -112,14 +115,14 @@ func SetBirthdayFromCommand(conn *pgx.Conn, update structs.Update) error {
 
 	return SendMessageWithReply(
 		chatID,
+		threadID,
 		message.MessageID,
-		fmt.Sprintf(
-			"Birthday event created \U00002705\nPerson: %s\nDate: %s\nReminder time: %02d:00 UTC\nEvent ID: %d\nI'll remind this chat every year.",
-			targetName,
-			birthday.Format("02-01-2006"),
-			birthdayReminderHourUTC,
-			eventID,
-		),
+		i18n.T(lang, "birthday.created", i18n.Args{
+			"name": targetName,
+			"date": birthday.Format("02-01-2006"),
+			"hour": fmt.Sprintf("%02d", birthdayReminderHourUTC),
+			"id":   eventID,
+		}),
 	)
 }
 
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bot/telegram/botapi"
+	"bot/telegram/config"
+	"bot/telegram/errors"
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	stdErrors "errors"
+	"fmt"
+	"strings"
+)
+
+// Telegram rejects buttons whose callback_data is longer than 64 bytes.
+const maxCallbackDataBytes = 64
+
+// Truncated HMAC length. Eight bytes is plenty to stop a client from forging
+// button payloads while leaving room for the action and its arguments.
+const callbackSignatureBytes = 8
+
+const callbackDataSeparator = "|"
+
+var errInvalidCallbackData = stdErrors.New("invalid callback data")
+
+// CallbackHandler handles presses of buttons created with one action name.
+type CallbackHandler func(c *CallbackContext) error
+
+// CallbackContext is what a callback handler gets to work with.
+type CallbackContext struct {
+	Conn   shared.DBTX
+	Query  *structs.CallbackQuery
+	Action string
+	Args   []string
+	// Lang is the language to answer the user who pressed the button in.
+	Lang     string
+	answered bool
+}
+
+func (c *CallbackContext) ChatID() int64 {
+	return c.Query.Message.Chat.ID
+}
+
+func (c *CallbackContext) MessageID() int {
+	return c.Query.Message.MessageID
+}
+
+func (c *CallbackContext) UserID() int64 {
+	return c.Query.From.ID
+}
+
+// Answer stops the button's loading spinner, optionally showing text to the
+// user who pressed it. Handlers that don't call it get an empty answer.
+func (c *CallbackContext) Answer(text string, showAlert bool) error {
+	c.answered = true
+	return TelegramClient().AnswerCallbackQuery(context.Background(), botapi.AnswerCallbackQueryParams{
+		CallbackQueryID: c.Query.ID,
+		Text:            text,
+		ShowAlert:       showAlert,
+	})
+}
+
+// EditText replaces the text of the message the button belongs to. A nil
+// keyboard removes the buttons.
+func (c *CallbackContext) EditText(text string, keyboard *structs.InlineKeyboardMarkup) error {
+	return TelegramClient().EditMessageText(context.Background(), botapi.EditMessageTextParams{
+		ChatID:      c.ChatID(),
+		MessageID:   c.MessageID(),
+		Text:        text,
+		ReplyMarkup: keyboard,
+	})
+}
+
+// EditKeyboard swaps the buttons of the message, e.g. to move between pages.
+func (c *CallbackContext) EditKeyboard(keyboard *structs.InlineKeyboardMarkup) error {
+	return TelegramClient().EditMessageReplyMarkup(context.Background(), botapi.EditMessageReplyMarkupParams{
+		ChatID:      c.ChatID(),
+		MessageID:   c.MessageID(),
+		ReplyMarkup: keyboard,
+	})
+}
+
+var callbackRegistry map[string]CallbackHandler
+
+func init() {
+	callbackRegistry = map[string]CallbackHandler{
+		deleteEventCallbackAction: handleDeleteEventCallback,
+	}
+}
+
+// CallbackButton builds a button whose payload is signed so it can't be forged.
+func CallbackButton(text string, action string, args ...string) (structs.InlineKeyboardButton, error) {
+	data, err := EncodeCallbackData(action, args...)
+	if err != nil {
+		return structs.InlineKeyboardButton{}, err
+	}
+
+	return structs.InlineKeyboardButton{Text: text, CallbackData: data}, nil
+}
+
+// EncodeCallbackData packs an action and its arguments as
+// "action|arg1|arg2|signature".
+func EncodeCallbackData(action string, args ...string) (string, error) {
+	parts := append([]string{action}, args...)
+	for _, part := range parts {
+		if strings.Contains(part, callbackDataSeparator) {
+			return "", fmt.Errorf("callback data part %q contains %q", part, callbackDataSeparator)
+		}
+	}
+
+	payload := strings.Join(parts, callbackDataSeparator)
+	data := payload + callbackDataSeparator + signCallbackPayload(payload)
+	if len(data) > maxCallbackDataBytes {
+		return "", fmt.Errorf("callback data for %s is %d bytes, limit is %d", action, len(data), maxCallbackDataBytes)
+	}
+
+	return data, nil
+}
+
+// DecodeCallbackData verifies the signature and splits the payload back into
+// its action and arguments.
+func DecodeCallbackData(data string) (string, []string, error) {
+	separator := strings.LastIndex(data, callbackDataSeparator)
+	if separator <= 0 {
+		return "", nil, errInvalidCallbackData
+	}
+
+	payload := data[:separator]
+	signature := data[separator+1:]
+	if !hmac.Equal([]byte(signature), []byte(signCallbackPayload(payload))) {
+		return "", nil, errInvalidCallbackData
+	}
+
+	parts := strings.Split(payload, callbackDataSeparator)
+	return parts[0], parts[1:], nil
+}
+
+func signCallbackPayload(payload string) string {
+	mac := hmac.New(sha256.New, []byte(config.Current.WebAppContextSecret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:callbackSignatureBytes])
+}
+
+func routeCallbackQuery(conn shared.DBTX, query *structs.CallbackQuery) {
+	c := &CallbackContext{Conn: conn, Query: query, Lang: i18n.DefaultLanguage}
+	if query.Message != nil {
+		c.Lang = chatLanguage(conn, query.Message.Chat.ID, query.From)
+	}
+
+	action, args, err := DecodeCallbackData(query.Data)
+	handler := callbackRegistry[action]
+	if err != nil || handler == nil || query.Message == nil || query.From == nil {
+		_ = c.Answer(i18n.T(c.Lang, "callback.expired", nil), false)
+		return
+	}
+
+	c.Action = action
+	c.Args = args
+	if err := handler(c); err != nil {
+		fmt.Printf("Failed to handle %s button: %s\n", action, err)
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID:  c.ChatID(),
+			SenderID: c.UserID(),
+			Error:    fmt.Sprintf("callback %s: %v", action, err),
+		})
+		if !c.answered {
+			_ = c.Answer(i18n.T(c.Lang, "callback.failed", nil), false)
+		}
+		return
+	}
+
+	if !c.answered {
+		_ = c.Answer("", false)
+	}
+}
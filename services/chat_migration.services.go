@@ -0,0 +1,260 @@
+package services
+
+import (
+	"bot/telegram/config"
+	"bot/telegram/errors"
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"context"
+	"fmt"
+	"time"
+)
+
+// MigrateChat moves everything stored under oldChatId to newChatId after a
+// group is upgraded to a supergroup. Rows that already exist under the new ID
+// are merged rather than duplicated. It is safe to run more than once, even at
+// the same time: Telegram announces the upgrade in both chats, which go to
+// different workers, and failed sends report it too. Runs for the same old
+// chat wait for each other, and only the first one moves anything.
+//
+// Every table keyed by chat ID must be handled here.
+func MigrateChat(conn shared.DBTX, oldChatId int64, newChatId int64) error {
+	if oldChatId == newChatId {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin chat migration: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, oldChatId); err != nil {
+		return fmt.Errorf("lock chat %d for migration: %w", oldChatId, err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO chat_migrations (old_chat_id, new_chat_id)
+		VALUES ($1, $2)
+		ON CONFLICT (old_chat_id) DO NOTHING
+	`, oldChatId, newChatId)
+	if err != nil {
+		return fmt.Errorf("record migration of chat %d: %w", oldChatId, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	steps := []struct {
+		name string
+		sql  string
+	}{
+		// A restriction in either chat carries over; when both have one, the
+		// old chat's end date wins.
+		{"merge users_ranking", `
+			INSERT INTO users_ranking (
+				user_id, group_id, first_name, last_name, username, karma, last_karma_given,
+				allowed_to_give_karma, allowed_to_receive_karma, karma_given, karma_taken,
+				give_karma_blocked_until, receive_karma_blocked_until, weighted_karma
+			)
+			SELECT
+				user_id, $2, first_name, last_name, username, karma, last_karma_given,
+				allowed_to_give_karma, allowed_to_receive_karma, karma_given, karma_taken,
+				give_karma_blocked_until, receive_karma_blocked_until, weighted_karma
+			FROM users_ranking
+			WHERE group_id = $1
+			ON CONFLICT (user_id, group_id)
+			DO UPDATE SET
+				karma = users_ranking.karma + EXCLUDED.karma,
+				weighted_karma = users_ranking.weighted_karma + EXCLUDED.weighted_karma,
+				karma_given = users_ranking.karma_given + EXCLUDED.karma_given,
+				karma_taken = users_ranking.karma_taken + EXCLUDED.karma_taken,
+				last_karma_given = GREATEST(users_ranking.last_karma_given, EXCLUDED.last_karma_given),
+				allowed_to_give_karma = users_ranking.allowed_to_give_karma AND EXCLUDED.allowed_to_give_karma,
+				allowed_to_receive_karma = users_ranking.allowed_to_receive_karma AND EXCLUDED.allowed_to_receive_karma,
+				give_karma_blocked_until = CASE
+					WHEN NOT EXCLUDED.allowed_to_give_karma THEN EXCLUDED.give_karma_blocked_until
+					ELSE users_ranking.give_karma_blocked_until
+				END,
+				receive_karma_blocked_until = CASE
+					WHEN NOT EXCLUDED.allowed_to_receive_karma THEN EXCLUDED.receive_karma_blocked_until
+					ELSE users_ranking.receive_karma_blocked_until
+				END
+		`},
+		{"delete old users_ranking", `DELETE FROM users_ranking WHERE group_id = $1`},
+		{"merge karma_opening_balances", `
+			INSERT INTO karma_opening_balances (chat_id, user_id, karma, karma_given, karma_taken, weighted_karma)
+			SELECT $2, user_id, karma, karma_given, karma_taken, weighted_karma
+			FROM karma_opening_balances
+			WHERE chat_id = $1
+			ON CONFLICT (chat_id, user_id)
+			DO UPDATE SET
+				karma = karma_opening_balances.karma + EXCLUDED.karma,
+				weighted_karma = karma_opening_balances.weighted_karma + EXCLUDED.weighted_karma,
+				karma_given = karma_opening_balances.karma_given + EXCLUDED.karma_given,
+				karma_taken = karma_opening_balances.karma_taken + EXCLUDED.karma_taken
+		`},
+		{"delete old karma_opening_balances", `DELETE FROM karma_opening_balances WHERE chat_id = $1`},
+		{"move karma_events", `UPDATE karma_events SET chat_id = $2 WHERE chat_id = $1`},
+		{"move karma_restriction_log", `UPDATE karma_restriction_log SET chat_id = $2 WHERE chat_id = $1`},
+		{"move karma_abuse_flags", `UPDATE karma_abuse_flags SET chat_id = $2 WHERE chat_id = $1`},
+		// The old group's seasons come first. A season the supergroup opened in
+		// the meantime gives way to the old group's open one, and the rest are
+		// numbered after the old group's (negated first so no number clashes
+		// on the way).
+		{"drop clashing open karma_seasons", `
+			DELETE FROM karma_seasons new
+			WHERE new.chat_id = $2
+				AND new.ended_at IS NULL
+				AND EXISTS (
+					SELECT 1
+					FROM karma_seasons old
+					WHERE old.chat_id = $1 AND old.ended_at IS NULL
+				)
+		`},
+		{"set aside karma_seasons numbers", `UPDATE karma_seasons SET number = -number WHERE chat_id = $2 AND EXISTS (SELECT 1 FROM karma_seasons WHERE chat_id = $1)`},
+		{"renumber karma_seasons", `
+			UPDATE karma_seasons
+			SET number = -number + (SELECT COALESCE(MAX(number), 0) FROM karma_seasons WHERE chat_id = $1)
+			WHERE chat_id = $2 AND number < 0
+		`},
+		{"move karma_seasons", `UPDATE karma_seasons SET chat_id = $2 WHERE chat_id = $1`},
+		// Only one birthday per chat and date is allowed; keep the one the new
+		// chat already has.
+		{"drop duplicate birthdays", `
+			DELETE FROM events old
+			WHERE old.chat_id = $1
+				AND old.type = 'birthday'
+				AND EXISTS (
+					SELECT 1
+					FROM events existing
+					WHERE existing.chat_id = $2
+						AND existing.type = 'birthday'
+						AND existing.event_date = old.event_date
+				)
+		`},
+		{"move events", `UPDATE events SET chat_id = $2, updated_at = CURRENT_TIMESTAMP WHERE chat_id = $1`},
+		{"move bot_errors", `UPDATE bot_errors SET group_id = $2, updated_at = CURRENT_TIMESTAMP WHERE group_id = $1`},
+		{"move processed_updates", `UPDATE processed_updates SET chat_id = $2 WHERE chat_id = $1`},
+		{"drop clashing command_invocations", `
+			DELETE FROM command_invocations old
+			WHERE old.chat_id = $1
+				AND EXISTS (
+					SELECT 1
+					FROM command_invocations existing
+					WHERE existing.chat_id = $2
+						AND existing.message_id = old.message_id
+				)
+		`},
+		{"move command_invocations", `UPDATE command_invocations SET chat_id = $2, updated_at = CURRENT_TIMESTAMP WHERE chat_id = $1`},
+		{"move message_edits", `UPDATE message_edits SET chat_id = $2 WHERE chat_id = $1`},
+		// Settings made in the supergroup win over the old group's.
+		{"copy chat_settings", `
+			INSERT INTO chat_settings (
+				chat_id, language, karma_cooldown_seconds, karma_receiver_cooldown_seconds,
+				karma_daily_cap, karma_allow_negative, karma_min_member_hours, karma_bots_can_receive,
+				season_mode, season_carry_percent, abuse_action, abuse_window_hours, abuse_pair_limit,
+				abuse_burst_givers, abuse_burst_minutes, abuse_reduce_percent, karma_weighting
+			)
+			SELECT
+				$2, language, karma_cooldown_seconds, karma_receiver_cooldown_seconds,
+				karma_daily_cap, karma_allow_negative, karma_min_member_hours, karma_bots_can_receive,
+				season_mode, season_carry_percent, abuse_action, abuse_window_hours, abuse_pair_limit,
+				abuse_burst_givers, abuse_burst_minutes, abuse_reduce_percent, karma_weighting
+			FROM chat_settings
+			WHERE chat_id = $1
+			ON CONFLICT (chat_id) DO NOTHING
+		`},
+		{"delete old chat_settings", `DELETE FROM chat_settings WHERE chat_id = $1`},
+		{"merge chat_members", `
+			INSERT INTO chat_members (chat_id, user_id, first_seen_at)
+			SELECT $2, user_id, first_seen_at
+			FROM chat_members
+			WHERE chat_id = $1
+			ON CONFLICT (chat_id, user_id)
+			DO UPDATE SET first_seen_at = LEAST(chat_members.first_seen_at, EXCLUDED.first_seen_at)
+		`},
+		{"delete old chat_members", `DELETE FROM chat_members WHERE chat_id = $1`},
+		// Basic groups have no topics, so there is nothing to merge here.
+		{"move topic_karma", `UPDATE topic_karma SET group_id = $2, updated_at = CURRENT_TIMESTAMP WHERE group_id = $1`},
+		{"drop clashing karma_triggers", `
+			DELETE FROM karma_triggers old
+			WHERE old.chat_id = $1
+				AND EXISTS (
+					SELECT 1
+					FROM karma_triggers existing
+					WHERE existing.chat_id = $2
+						AND existing.kind = old.kind
+						AND existing.position = old.position
+						AND existing.pattern = old.pattern
+				)
+		`},
+		{"move karma_triggers", `UPDATE karma_triggers SET chat_id = $2 WHERE chat_id = $1`},
+	}
+
+	for _, step := range steps {
+		if _, err := tx.Exec(ctx, step.sql, oldChatId, newChatId); err != nil {
+			return fmt.Errorf("migrate chat %d to %d: %s: %w", oldChatId, newChatId, step.name, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit chat migration: %w", err)
+	}
+
+	forgetChatAdministrators(oldChatId)
+	forgetChatAdministrators(newChatId)
+
+	fmt.Printf("Migrated chat %d to %d\n", oldChatId, newChatId)
+	return nil
+}
+
+// handleChatMigrationMessage reacts to the service messages Telegram posts in
+// both the old group and the new supergroup. It reports whether message was one.
+func handleChatMigrationMessage(conn shared.DBTX, message *structs.Message) bool {
+	var oldChatId, newChatId int64
+	switch {
+	case message.MigrateToChatID != nil:
+		oldChatId, newChatId = message.Chat.ID, *message.MigrateToChatID
+	case message.MigrateFromChatID != nil:
+		oldChatId, newChatId = *message.MigrateFromChatID, message.Chat.ID
+	default:
+		return false
+	}
+
+	if err := MigrateChat(conn, oldChatId, newChatId); err != nil {
+		fmt.Printf("Failed to migrate chat %d to %d: %s\n", oldChatId, newChatId, err)
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: oldChatId,
+			Error:   err.Error(),
+		})
+	}
+
+	return true
+}
+
+// migrateChatInBackground re-keys a chat discovered through a failed send. The
+// send may happen inside an update's transaction that holds locks on the old
+// chat's rows, so the migration runs on its own connection and simply waits for
+// that transaction instead of deadlocking with it.
+func migrateChatInBackground(oldChatId int64, newChatId int64) {
+	go func() {
+		conn, err := GlobalPoolManager.GetConnectionFromPool(config.Current.DBName)
+		if err != nil {
+			fmt.Printf("Failed to migrate chat %d to %d: %s\n", oldChatId, newChatId, err)
+			return
+		}
+		defer conn.Release()
+
+		if err := MigrateChat(conn, oldChatId, newChatId); err != nil {
+			fmt.Printf("Failed to migrate chat %d to %d: %s\n", oldChatId, newChatId, err)
+			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+				GroupID: oldChatId,
+				Error:   err.Error(),
+			})
+		}
+	}()
+}
@@ -1,44 +1,727 @@
 package services
 
-import "strings"
+import (
+	"bot/telegram/botapi"
+	"bot/telegram/errors"
+	"bot/telegram/i18n"
+	"bot/telegram/richtext"
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
-func isBotCommand(text string, command string) bool {
-	fields := strings.Fields(strings.TrimSpace(text))
-	if len(fields) == 0 {
-		return false
+const (
+	chatTypePrivate    = "private"
+	chatTypeGroup      = "group"
+	chatTypeSupergroup = "supergroup"
+)
+
+var groupChatTypes = []string{chatTypeGroup, chatTypeSupergroup}
+
+// Outcomes recorded in command_invocations.
+const (
+	commandStatusOK         = "ok"
+	commandStatusUsageError = "usage_error"
+	commandStatusRejected   = "rejected"
+	commandStatusFailed     = "failed"
+)
+
+const (
+	commandArgText = "text"
+	commandArgInt  = "int"
+	commandArgDate = "date"
+)
+
+// CommandArg describes one positional argument of a command.
+type CommandArg struct {
+	Name string
+	// Placeholder is the catalog key of the name shown in usage lines.
+	// Arguments with Choices show the choices instead.
+	Placeholder string
+	Kind        string
+	Optional    bool
+	// Choices, when set, lists the only values the argument accepts.
+	Choices []string
+	// Rest takes everything after the previous arguments, spaces included.
+	Rest bool
+}
+
+// BotCommandSpec declares a command once; routing, /command help and the
+// setMyCommands lists are all generated from it. The text fields hold message
+// catalog keys so every language gets its own help.
+type BotCommandSpec struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Help        string
+	Example     string
+	Args        []CommandArg
+	AdminOnly   bool
+	// ChatTypes limits where the command works. Empty means everywhere.
+	ChatTypes []string
+	// FailureReply is sent when the handler returns an error.
+	FailureReply string
+	Handler      func(c *CommandContext) error
+}
+
+// CommandContext is what a command handler gets to work with.
+type CommandContext struct {
+	Conn    shared.DBTX
+	Update  structs.Update
+	Message *structs.Message
+	Spec    *BotCommandSpec
+	Args    map[string]string
+	// Mentions are the users the command message mentions.
+	Mentions []Mention
+	// Lang is the language to reply in.
+	Lang string
+}
+
+func (c *CommandContext) ChatID() int64 {
+	return c.Message.Chat.ID
+}
+
+// ThreadID is the forum topic the command was sent in, 0 outside topics.
+func (c *CommandContext) ThreadID() int {
+	return messageThreadID(c.Message)
+}
+
+// Arg returns a parsed argument, or "" when an optional one was omitted.
+func (c *CommandContext) Arg(name string) string {
+	return c.Args[name]
+}
+
+// IntArg returns an int argument. The router has already validated it.
+func (c *CommandContext) IntArg(name string) int64 {
+	value, _ := strconv.ParseInt(c.Args[name], 10, 64)
+	return value
+}
+
+// DateArg returns a DD-MM-YYYY argument. The router has already validated it.
+func (c *CommandContext) DateArg(name string) time.Time {
+	value, _ := time.Parse("02-01-2006", c.Args[name])
+	return value
+}
+
+// targetUserArg is the @username or text mention of the user a command is
+// about. Text mentions can hold spaces, so it takes the rest of the text.
+var targetUserArg = CommandArg{
+	Name:        "user",
+	Placeholder: "arg.user",
+	Kind:        commandArgText,
+	Optional:    true,
+	Rest:        true,
+}
+
+var commandRegistry []*BotCommandSpec
+
+func init() {
+	commandRegistry = []*BotCommandSpec{
+		{
+			Name:        "command",
+			Aliases:     []string{"help"},
+			Description: "cmd.command.description",
+			Help:        "cmd.command.help",
+			Args:        []CommandArg{{Name: "command", Placeholder: "arg.command", Kind: commandArgText, Optional: true}},
+			Handler: func(c *CommandContext) error {
+				return SendCommandsHelp(c.ChatID(), c.ThreadID(), c.Lang, c.Arg("command"))
+			},
+		},
+		{
+			Name:        "new_event",
+			Description: "cmd.new_event.description",
+			Help:        "cmd.new_event.help",
+			Handler: func(c *CommandContext) error {
+				return NewEventFromCommand(c.Conn, c.ChatID(), c.ThreadID(), c.Lang, c.Message.From.ID)
+			},
+		},
+		{
+			Name:        "ask_catholic_church",
+			Description: "cmd.ask_catholic_church.description",
+			Help:        "cmd.ask_catholic_church.help",
+			Example:     "cmd.ask_catholic_church.example",
+			Args:        []CommandArg{{Name: "question", Placeholder: "arg.question", Kind: commandArgText, Rest: true}},
+			Handler: func(c *CommandContext) error {
+				return AskCatholicChurchFromCommand(c.Update, c.Arg("question"), c.Lang)
+			},
+		},
+		{
+			Name:         "set_birthday",
+			Description:  "cmd.set_birthday.description",
+			Help:         "cmd.set_birthday.help",
+			Example:      "cmd.set_birthday.example",
+			Args:         []CommandArg{{Name: "date", Placeholder: "arg.date", Kind: commandArgDate}},
+			ChatTypes:    groupChatTypes,
+			FailureReply: "cmd.set_birthday.failure",
+			Handler: func(c *CommandContext) error {
+				return SetBirthdayFromCommand(c.Conn, c.Update, c.Lang)
+			},
+		},
+		{
+			Name:        "show_events",
+			Description: "cmd.show_events.description",
+			Help:        "cmd.show_events.help",
+			ChatTypes:   groupChatTypes,
+			Handler: func(c *CommandContext) error {
+				ShowEvents(c.Conn, c.ChatID(), c.ThreadID(), c.Lang)
+				return nil
+			},
+		},
+		{
+			Name:        "delete_event",
+			Description: "cmd.delete_event.description",
+			Help:        "cmd.delete_event.help",
+			Example:     "cmd.delete_event.example",
+			Args:        []CommandArg{{Name: "id", Placeholder: "arg.id", Kind: commandArgInt}},
+			AdminOnly:   true,
+			ChatTypes:   groupChatTypes,
+			Handler: func(c *CommandContext) error {
+				DeleteEvent(c.Conn, c.ChatID(), c.ThreadID(), c.Lang, c.Message.From.ID, c.IntArg("id"))
+				return nil
+			},
+		},
+		{
+			Name:        "lovedusers",
+			Description: "cmd.lovedusers.description",
+			Help:        "cmd.lovedusers.help",
+			Example:     "cmd.lovedusers.example",
+			Args:        []CommandArg{weightedLeaderboardOptionsArg},
+			ChatTypes:   groupChatTypes,
+			Handler: func(c *CommandContext) error {
+				return ShowLeaderboard(c, lovedLeaderboard)
+			},
+		},
+		{
+			Name:        "hatedusers",
+			Description: "cmd.hatedusers.description",
+			Help:        "cmd.hatedusers.help",
+			Example:     "cmd.hatedusers.example",
+			Args:        []CommandArg{weightedLeaderboardOptionsArg},
+			ChatTypes:   groupChatTypes,
+			Handler: func(c *CommandContext) error {
+				return ShowLeaderboard(c, hatedLeaderboard)
+			},
+		},
+		{
+			Name:        "generoususers",
+			Description: "cmd.generoususers.description",
+			Help:        "cmd.generoususers.help",
+			Example:     "cmd.generoususers.example",
+			Args:        []CommandArg{leaderboardOptionsArg},
+			ChatTypes:   groupChatTypes,
+			Handler: func(c *CommandContext) error {
+				return ShowLeaderboard(c, generousLeaderboard)
+			},
+		},
+		{
+			Name:        "criticalusers",
+			Description: "cmd.criticalusers.description",
+			Help:        "cmd.criticalusers.help",
+			Example:     "cmd.criticalusers.example",
+			Args:        []CommandArg{leaderboardOptionsArg},
+			ChatTypes:   groupChatTypes,
+			Handler: func(c *CommandContext) error {
+				return ShowLeaderboard(c, criticalLeaderboard)
+			},
+		},
+		{
+			Name:        "karma",
+			Description: "cmd.karma.description",
+			Help:        "cmd.karma.help",
+			Example:     "cmd.karma.example",
+			Args:        []CommandArg{targetUserArg},
+			ChatTypes:   groupChatTypes,
+			Handler:     ShowKarmaProfile,
+		},
+		{
+			Name:        "karma_history",
+			Description: "cmd.karma_history.description",
+			Help:        "cmd.karma_history.help",
+			Example:     "cmd.karma_history.example",
+			Args:        []CommandArg{targetUserArg},
+			ChatTypes:   groupChatTypes,
+			Handler:     ShowKarmaHistory,
+		},
+		{
+			Name:         "undo_karma",
+			Description:  "cmd.undo_karma.description",
+			Help:         "cmd.undo_karma.help",
+			ChatTypes:    groupChatTypes,
+			FailureReply: "undo.failed",
+			Handler:      UndoKarmaFromCommand,
+		},
+		{
+			Name:         "rebuild_karma",
+			Description:  "cmd.rebuild_karma.description",
+			Help:         "cmd.rebuild_karma.help",
+			AdminOnly:    true,
+			ChatTypes:    groupChatTypes,
+			FailureReply: "rebuild.failed",
+			Handler:      RebuildKarmaFromCommand,
+		},
+		{
+			Name:        "karma_settings",
+			Description: "cmd.karma_settings.description",
+			Help:        "cmd.karma_settings.help",
+			Example:     "cmd.karma_settings.example",
+			Args: []CommandArg{
+				{Name: "setting", Kind: commandArgText, Optional: true, Choices: karmaSettingNames()},
+				{Name: "value", Placeholder: "arg.value", Kind: commandArgText, Optional: true},
+			},
+			ChatTypes:    groupChatTypes,
+			FailureReply: "settings.failed",
+			Handler:      KarmaSettingsFromCommand,
+		},
+		{
+			Name:         "season",
+			Description:  "cmd.season.description",
+			Help:         "cmd.season.help",
+			Example:      "cmd.season.example",
+			Args:         []CommandArg{{Name: "action", Kind: commandArgText, Optional: true, Choices: []string{karmaSeasonActionEnd}}},
+			ChatTypes:    groupChatTypes,
+			FailureReply: "season.failed",
+			Handler:      KarmaSeasonFromCommand,
+		},
+		{
+			Name:        "season_history",
+			Description: "cmd.season_history.description",
+			Help:        "cmd.season_history.help",
+			Example:     "cmd.season_history.example",
+			Args:        []CommandArg{{Name: "count", Placeholder: "arg.count", Kind: commandArgInt, Optional: true}},
+			ChatTypes:   groupChatTypes,
+			Handler:     ShowKarmaSeasonHistory,
+		},
+		{
+			Name:         "karma_ban",
+			Description:  "cmd.karma_ban.description",
+			Help:         "cmd.karma_ban.help",
+			Example:      "cmd.karma_ban.example",
+			Args:         restrictionArgs,
+			AdminOnly:    true,
+			ChatTypes:    groupChatTypes,
+			FailureReply: "bans.failed",
+			Handler:      KarmaBanFromCommand,
+		},
+		{
+			Name:         "karma_mute_receive",
+			Description:  "cmd.karma_mute_receive.description",
+			Help:         "cmd.karma_mute_receive.help",
+			Example:      "cmd.karma_mute_receive.example",
+			Args:         restrictionArgs,
+			AdminOnly:    true,
+			ChatTypes:    groupChatTypes,
+			FailureReply: "bans.failed",
+			Handler:      KarmaMuteReceiveFromCommand,
+		},
+		{
+			Name:         "karma_unban",
+			Description:  "cmd.karma_unban.description",
+			Help:         "cmd.karma_unban.help",
+			Example:      "cmd.karma_unban.example",
+			Args:         []CommandArg{targetUserArg},
+			AdminOnly:    true,
+			ChatTypes:    groupChatTypes,
+			FailureReply: "bans.failed",
+			Handler:      KarmaUnbanFromCommand,
+		},
+		{
+			Name:        "karma_bans",
+			Description: "cmd.karma_bans.description",
+			Help:        "cmd.karma_bans.help",
+			Example:     "cmd.karma_bans.example",
+			Args:        []CommandArg{{Name: "view", Kind: commandArgText, Optional: true, Choices: []string{karmaBansViewLog}}},
+			AdminOnly:   true,
+			ChatTypes:   groupChatTypes,
+			Handler:     ShowKarmaBans,
+		},
+		{
+			Name:        "karma_flags",
+			Description: "cmd.karma_flags.description",
+			Help:        "cmd.karma_flags.help",
+			AdminOnly:   true,
+			ChatTypes:   groupChatTypes,
+			Handler:     ShowKarmaFlags,
+		},
+		{
+			Name:         "karma_approve",
+			Description:  "cmd.karma_approve.description",
+			Help:         "cmd.karma_approve.help",
+			Example:      "cmd.karma_approve.example",
+			Args:         []CommandArg{{Name: "id", Placeholder: "arg.id", Kind: commandArgInt}},
+			AdminOnly:    true,
+			ChatTypes:    groupChatTypes,
+			FailureReply: "abuse.failed",
+			Handler:      ApproveKarmaFlag,
+		},
+		{
+			Name:         "karma_reject",
+			Description:  "cmd.karma_reject.description",
+			Help:         "cmd.karma_reject.help",
+			Example:      "cmd.karma_reject.example",
+			Args:         []CommandArg{{Name: "id", Placeholder: "arg.id", Kind: commandArgInt}},
+			AdminOnly:    true,
+			ChatTypes:    groupChatTypes,
+			FailureReply: "abuse.failed",
+			Handler:      RejectKarmaFlag,
+		},
+		{
+			Name:        "karma_triggers",
+			Description: "cmd.karma_triggers.description",
+			Help:        "cmd.karma_triggers.help",
+			ChatTypes:   groupChatTypes,
+			Handler:     ShowKarmaTriggers,
+		},
+		{
+			Name:        "add_karma_trigger",
+			Description: "cmd.add_karma_trigger.description",
+			Help:        "cmd.add_karma_trigger.help",
+			Example:     "cmd.add_karma_trigger.example",
+			Args: []CommandArg{
+				{Name: "kind", Kind: commandArgText, Choices: karmaTriggerKinds},
+				{Name: "position", Kind: commandArgText, Choices: karmaTriggerPositions},
+				{Name: "value", Placeholder: "arg.value", Kind: commandArgInt},
+				{Name: "pattern", Placeholder: "arg.pattern", Kind: commandArgText, Rest: true},
+			},
+			AdminOnly:    true,
+			ChatTypes:    groupChatTypes,
+			FailureReply: "triggers.failed",
+			Handler:      AddKarmaTriggerFromCommand,
+		},
+		{
+			Name:         "remove_karma_trigger",
+			Description:  "cmd.remove_karma_trigger.description",
+			Help:         "cmd.remove_karma_trigger.help",
+			Example:      "cmd.remove_karma_trigger.example",
+			Args:         []CommandArg{{Name: "id", Placeholder: "arg.id", Kind: commandArgInt}},
+			AdminOnly:    true,
+			ChatTypes:    groupChatTypes,
+			FailureReply: "triggers.failed",
+			Handler:      RemoveKarmaTrigger,
+		},
+		{
+			Name:         "language",
+			Description:  "cmd.language.description",
+			Help:         "cmd.language.help",
+			Example:      "cmd.language.example",
+			Args:         []CommandArg{{Name: "language", Kind: commandArgText, Optional: true, Choices: languageChoices()}},
+			FailureReply: "language.failed",
+			Handler:      ChangeLanguageFromCommand,
+		},
+	}
+}
+
+func findCommand(name string) *BotCommandSpec {
+	name = strings.ToLower(name)
+	for _, spec := range commandRegistry {
+		if spec.Name == name {
+			return spec
+		}
+		for _, alias := range spec.Aliases {
+			if alias == name {
+				return spec
+			}
+		}
+	}
+
+	return nil
+}
+
+// routeCommand runs the registered command the message starts with and
+// returns the invocation status, or "" when the message isn't a known command.
+func routeCommand(conn shared.DBTX, update structs.Update) string {
+	message := update.Message
+	parsed := ParseMessage(message)
+	if parsed.Command == nil {
+		return ""
+	}
+	spec := findCommand(parsed.Command.Name)
+	if spec == nil {
+		return ""
+	}
+
+	// In groups with several bots, /command@OtherBot is not ours to answer.
+	forUs, err := isCommandForUs(parsed.Command)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: message.Chat.ID,
+			Error:   fmt.Sprintf("check command address: %v", err),
+		})
+	}
+	if !forUs {
+		return ""
+	}
+
+	// Anonymous admins and channels post without a sender; commands need one.
+	if message.From == nil {
+		return commandStatusRejected
+	}
+
+	chatId := message.Chat.ID
+	threadId := messageThreadID(message)
+	lang := chatLanguage(conn, chatId, message.From)
+	if !spec.allowedIn(message.Chat.Type) {
+		key := "command.only_in_private"
+		if spec.allowedIn(chatTypeGroup) {
+			key = "command.only_in_groups"
+		}
+		_ = SendMessageWithReply(chatId, threadId, message.MessageID, i18n.T(lang, key, i18n.Args{"command": spec.Name}))
+		return commandStatusRejected
+	}
+
+	if spec.AdminOnly {
+		isAdmin, err := isUserAdmin(chatId, message.From.ID)
+		if err != nil {
+			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+				GroupID:  chatId,
+				SenderID: message.From.ID,
+				Error:    fmt.Sprintf("check admin: %v", err),
+			})
+			_ = SendMessageToThread(chatId, threadId, i18n.T(lang, "command.admin_check_failed", nil))
+			return commandStatusFailed
+		}
+
+		if !isAdmin {
+			_ = SendMessageToThread(chatId, threadId, i18n.T(lang, "command.admin_only", i18n.Args{"command": spec.Name}))
+			return commandStatusRejected
+		}
+	}
+
+	args, ok := spec.parseArgs(parsed.Command.Args)
+	if !ok {
+		_ = SendMessageWithReply(chatId, threadId, message.MessageID, spec.usageHelp(lang))
+		return commandStatusUsageError
+	}
+
+	err = spec.Handler(&CommandContext{
+		Conn:     conn,
+		Update:   update,
+		Message:  message,
+		Spec:     spec,
+		Args:     args,
+		Mentions: parsed.Mentions,
+		Lang:     lang,
+	})
+	if err != nil {
+		fmt.Printf("Failed to handle /%s: %s\n", spec.Name, err)
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: chatId,
+			Error:   err.Error(),
+		})
+		if spec.FailureReply != "" {
+			_ = SendMessageWithReply(chatId, threadId, message.MessageID, i18n.T(lang, spec.FailureReply, nil))
+		}
+		return commandStatusFailed
+	}
+
+	return commandStatusOK
+}
+
+func (spec *BotCommandSpec) allowedIn(chatType string) bool {
+	if len(spec.ChatTypes) == 0 {
+		return true
+	}
+
+	return containsString(spec.ChatTypes, chatType)
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseArgs splits the text after the command according to spec.Args and
+// validates each value's kind.
+func (spec *BotCommandSpec) parseArgs(remainder string) (map[string]string, bool) {
+	args := make(map[string]string, len(spec.Args))
+
+	for _, arg := range spec.Args {
+		var value string
+		if arg.Rest {
+			value = remainder
+			remainder = ""
+		} else {
+			fields := strings.Fields(remainder)
+			if len(fields) > 0 {
+				value = fields[0]
+				remainder = strings.TrimSpace(strings.TrimPrefix(remainder, fields[0]))
+			}
+		}
+
+		if value == "" {
+			if !arg.Optional {
+				return nil, false
+			}
+			continue
+		}
+
+		switch arg.Kind {
+		case commandArgInt:
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, false
+			}
+		case commandArgDate:
+			if _, err := time.Parse("02-01-2006", value); err != nil {
+				return nil, false
+			}
+		}
+
+		if len(arg.Choices) > 0 {
+			value = strings.ToLower(value)
+			if !containsString(arg.Choices, value) {
+				return nil, false
+			}
+		}
+
+		args[arg.Name] = value
 	}
 
-	messageCommand := strings.Split(fields[0], "@")[0]
-	return messageCommand == command
+	// Commands without arguments ignore trailing text; the rest reject it.
+	if len(spec.Args) > 0 && remainder != "" {
+		return nil, false
+	}
+
+	return args, true
 }
 
-func SendCommandsHelp(chatID int64) error {
-	return SendMessage(chatID, strings.TrimSpace(`Available bot commands:
+func (spec *BotCommandSpec) usage(lang string) string {
+	parts := []string{"/" + spec.Name}
+	for _, arg := range spec.Args {
+		placeholder := strings.Join(arg.Choices, "|")
+		if len(arg.Choices) == 0 {
+			placeholder = i18n.T(lang, arg.Placeholder, nil)
+		}
 
-/command
-Shows this help message with details for every command.
+		if arg.Optional {
+			parts = append(parts, "["+placeholder+"]")
+		} else {
+			parts = append(parts, "<"+placeholder+">")
+		}
+	}
 
-/new_event
-Opens the event Web App. Use it to create custom events, reminders, or birthdays with a form.
+	return strings.Join(parts, " ")
+}
 
-/ask_catholic_church <question>
-Asks Magisterium AI a question about Catholic teaching and replies with the answer.
-Example: /ask_catholic_church What does the Church teach about forgiveness?
+func (spec *BotCommandSpec) usageHelp(lang string) string {
+	text := i18n.T(lang, "command.usage", i18n.Args{"usage": spec.usage(lang)})
+	if spec.Example != "" {
+		text += "\n" + i18n.T(lang, "command.example", i18n.Args{"example": i18n.T(lang, spec.Example, nil)})
+	}
 
-/set_birthday DD-MM-YYYY
-Creates a yearly birthday event. Use it as a reply to the person's message so the bot knows whose birthday to save.
-Example: reply to Maria and send /set_birthday 24-12-1990
+	return text
+}
 
-/show_events
-Shows all active events in this group with their IDs, types, titles, and dates.
+func (spec *BotCommandSpec) helpEntry(lang string) string {
+	var b strings.Builder
+	b.WriteString(spec.usage(lang))
+	b.WriteString("\n")
+	b.WriteString(i18n.T(lang, spec.Help, nil))
+	if spec.AdminOnly {
+		b.WriteString(" ")
+		b.WriteString(i18n.T(lang, "command.admin_only_note", nil))
+	}
+	if spec.Example != "" {
+		b.WriteString("\n")
+		b.WriteString(i18n.T(lang, "command.example", i18n.Args{"example": i18n.T(lang, spec.Example, nil)}))
+	}
 
-/delete_event <id>
-Deletes an event by its ID. Only group admins can use this command.
-Example: /delete_event 42
+	return b.String()
+}
 
-/lovedusers
-Shows the users with the most positive karma in this chat.
+func SendCommandsHelp(chatID int64, threadID int, lang string, commandName string) error {
+	if commandName != "" {
+		spec := findCommand(strings.TrimPrefix(commandName, "/"))
+		if spec == nil {
+			return SendMessageToThread(chatID, threadID, i18n.T(lang, "command.unknown", i18n.Args{"command": commandName}))
+		}
+
+		return SendMessageToThread(chatID, threadID, spec.helpEntry(lang))
+	}
+
+	for _, message := range CommandsHelpMessages(lang) {
+		if err := SendMessageToThread(chatID, threadID, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CommandsHelpMessages renders the help for every command in as few messages
+// as Telegram's length limit allows. Messages break between entries; only an
+// entry too long for a message of its own is cut.
+func CommandsHelpMessages(lang string) []string {
+	var messages []string
+	current := i18n.T(lang, "command.help_header", nil)
+	for _, spec := range commandRegistry {
+		entry := spec.helpEntry(lang)
+		if richtext.UTF16Len(current)+richtext.UTF16Len("\n\n"+entry) <= richtext.MessageLimit {
+			current += "\n\n" + entry
+			continue
+		}
+
+		messages = append(messages, current)
+		current = entry
+		if richtext.UTF16Len(entry) > richtext.MessageLimit {
+			chunks := richtext.SplitText(entry, richtext.MessageLimit)
+			messages = append(messages, chunks[:len(chunks)-1]...)
+			current = chunks[len(chunks)-1]
+		}
+	}
+
+	return append(messages, current)
+}
+
+// botCommandScopes lists the setMyCommands registrations for one language:
+// members see the commands they can use where they are, and admins also see
+// admin-only ones. The default language is registered without a language code
+// so it also covers users whose language we don't support.
+func botCommandScopes(lang string) []botapi.SetMyCommandsParams {
+	isPublic := func(spec *BotCommandSpec) bool { return !spec.AdminOnly }
+	inPrivate := func(spec *BotCommandSpec) bool { return isPublic(spec) && spec.allowedIn(chatTypePrivate) }
+	inGroups := func(spec *BotCommandSpec) bool { return isPublic(spec) && spec.allowedIn(chatTypeGroup) }
+	forAdmins := func(spec *BotCommandSpec) bool { return spec.allowedIn(chatTypeGroup) }
+
+	languageCode := lang
+	if lang == i18n.DefaultLanguage {
+		languageCode = ""
+	}
+
+	return []botapi.SetMyCommandsParams{
+		{Scope: &structs.BotCommandScope{Type: structs.BotCommandScopeDefault}, LanguageCode: languageCode, Commands: botCommandList(lang, isPublic)},
+		{Scope: &structs.BotCommandScope{Type: structs.BotCommandScopeAllPrivateChats}, LanguageCode: languageCode, Commands: botCommandList(lang, inPrivate)},
+		{Scope: &structs.BotCommandScope{Type: structs.BotCommandScopeAllGroupChats}, LanguageCode: languageCode, Commands: botCommandList(lang, inGroups)},
+		{Scope: &structs.BotCommandScope{Type: structs.BotCommandScopeAllChatAdministrators}, LanguageCode: languageCode, Commands: botCommandList(lang, forAdmins)},
+	}
+}
+
+func botCommandList(lang string, include func(spec *BotCommandSpec) bool) []structs.BotCommand {
+	commands := make([]structs.BotCommand, 0, len(commandRegistry))
+	for _, spec := range commandRegistry {
+		if include(spec) {
+			commands = append(commands, structs.BotCommand{Command: spec.Name, Description: i18n.T(lang, spec.Description, nil)})
+		}
+	}
+
+	return commands
+}
+
+func RegisterBotCommands() error {
+	for _, lang := range i18n.Languages() {
+		for _, params := range botCommandScopes(lang) {
+			if err := TelegramClient().SetMyCommands(context.Background(), params); err != nil {
+				return fmt.Errorf("set %s commands for scope %s: %w", lang, params.Scope.Type, err)
+			}
+		}
+	}
 
-/hatedusers
-Shows the users with the most negative karma in this chat.`))
+	return nil
 }
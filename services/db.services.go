@@ -5,6 +5,7 @@ import (
 	"bot/telegram/shared"
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -17,10 +18,14 @@ var GlobalPoolManager = &PoolManager{
 }
 
 type PoolManager struct {
+	mu    sync.Mutex
 	pools map[string]*pgxpool.Pool
 }
 
 func (pm *PoolManager) GetPool(dbName string) (*pgxpool.Pool, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
 	if pool, exists := pm.pools[dbName]; exists {
 		// Check if pool is still healthy
 		if pool.Ping(context.Background()) == nil {
@@ -164,10 +169,13 @@ func createUsersRankingTable(conn *pgx.Conn) error {
 	return err
 }
 
-func UpsertUserKarma(conn *pgx.Conn, userID int64, groupID int64, firstName, lastName, username string, karmaValue int, karmaGivenIncrement int, karmaTakenIncrement int) (int, error) {
+// UpsertUserKarma adds karmaValue and its weighted value to the user's karma
+// and the increments to their karma given and taken. It returns the new
+// karma.
+func UpsertUserKarma(conn shared.DBTX, userID int64, groupID int64, firstName, lastName, username string, karmaValue int, weightedValue float64, karmaGivenIncrement int, karmaTakenIncrement int) (int, error) {
 	sql := `
-    INSERT INTO users_ranking (user_id, group_id, first_name, last_name, username, karma, last_karma_given, karma_given, karma_taken)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    INSERT INTO users_ranking (user_id, group_id, first_name, last_name, username, karma, last_karma_given, karma_given, karma_taken, weighted_karma)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
     ON CONFLICT (user_id, group_id)
     DO UPDATE SET
       first_name = EXCLUDED.first_name,
@@ -176,7 +184,8 @@ func UpsertUserKarma(conn *pgx.Conn, userID int64, groupID int64, firstName, las
       karma = users_ranking.karma + $6,
       last_karma_given = EXCLUDED.last_karma_given,
       karma_given = users_ranking.karma_given + $8,
-      karma_taken = users_ranking.karma_taken + $9
+      karma_taken = users_ranking.karma_taken + $9,
+      weighted_karma = users_ranking.weighted_karma + $10
     RETURNING karma
   `
 
@@ -193,6 +202,7 @@ func UpsertUserKarma(conn *pgx.Conn, userID int64, groupID int64, firstName, las
 		time.Now().UTC(),
 		karmaGivenIncrement,
 		karmaTakenIncrement,
+		weightedValue,
 	).Scan(&totalKarma)
 	if err != nil {
 		return 0, err
@@ -203,50 +213,260 @@ func UpsertUserKarma(conn *pgx.Conn, userID int64, groupID int64, firstName, las
 type UsersLovedHatedStruct struct {
 	Name  string
 	Karma int
+	// Weighted is set instead of Karma on weighted boards.
+	Weighted float64
 }
 
-func GetMostLovedUsers(conn *pgx.Conn, chatId int64) ([]UsersLovedHatedStruct, error) {
-	sql := `
-		SELECT TRIM(CONCAT(first_name, ' ', COALESCE(last_name,''))) as name, karma FROM users_ranking ur
+func GetMostLovedUsers(conn shared.DBTX, chatId int64, limit int) ([]UsersLovedHatedStruct, error) {
+	return getRankingLeaderboard(conn, chatId, "karma", "DESC", false, limit)
+}
+
+func GetMostHatedUsers(conn shared.DBTX, chatId int64, limit int) ([]UsersLovedHatedStruct, error) {
+	return getRankingLeaderboard(conn, chatId, "karma", "ASC", false, limit)
+}
+
+// GetMostLovedUsersWeighted ranks the chat's users by weighted karma.
+func GetMostLovedUsersWeighted(conn shared.DBTX, chatId int64, limit int) ([]UsersLovedHatedStruct, error) {
+	return getWeightedRankingLeaderboard(conn, chatId, "DESC", limit)
+}
+
+func GetMostHatedUsersWeighted(conn shared.DBTX, chatId int64, limit int) ([]UsersLovedHatedStruct, error) {
+	return getWeightedRankingLeaderboard(conn, chatId, "ASC", limit)
+}
+
+// GetMostGenerousUsers ranks who gave the most karma in the chat.
+func GetMostGenerousUsers(conn shared.DBTX, chatId int64, limit int) ([]UsersLovedHatedStruct, error) {
+	return getRankingLeaderboard(conn, chatId, "karma_given", "DESC", true, limit)
+}
+
+// GetMostCriticalUsers ranks who took the most karma in the chat.
+func GetMostCriticalUsers(conn shared.DBTX, chatId int64, limit int) ([]UsersLovedHatedStruct, error) {
+	return getRankingLeaderboard(conn, chatId, "karma_taken", "DESC", true, limit)
+}
+
+// getRankingLeaderboard ranks the chat's users_ranking rows by column. With
+// onlyPositive, users whose column is 0 are left off. column and order are
+// constants from the callers above, never user input.
+func getRankingLeaderboard(conn shared.DBTX, chatId int64, column string, order string, onlyPositive bool, limit int) ([]UsersLovedHatedStruct, error) {
+	sql := fmt.Sprintf(`
+		SELECT TRIM(CONCAT(first_name, ' ', COALESCE(last_name,''))) as name, COALESCE(%[1]s, 0) as value FROM users_ranking ur
 		WHERE
 			ur.group_id = $1
-		ORDER BY karma DESC, name ASC
-		LIMIT 10;
-	`
+			AND (NOT $3 OR %[1]s > 0)
+		ORDER BY value %[2]s, name ASC
+		LIMIT $2;
+	`, column, order)
 
-	rows, err := conn.Query(context.Background(), sql, chatId)
+	rows, err := conn.Query(context.Background(), sql, chatId, limit, onlyPositive)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var users []UsersLovedHatedStruct
-	for rows.Next() {
-		var user UsersLovedHatedStruct
-		if err := rows.Scan(&user.Name, &user.Karma); err != nil {
-			return nil, err
-		}
-		users = append(users, user)
+	return collectLeaderboard(rows)
+}
+
+// getWeightedRankingLeaderboard ranks the chat's users_ranking rows by
+// weighted_karma. order is a constant from the callers above, never user
+// input.
+func getWeightedRankingLeaderboard(conn shared.DBTX, chatId int64, order string, limit int) ([]UsersLovedHatedStruct, error) {
+	sql := fmt.Sprintf(`
+		SELECT TRIM(CONCAT(first_name, ' ', COALESCE(last_name,''))) as name, weighted_karma FROM users_ranking ur
+		WHERE ur.group_id = $1
+		ORDER BY weighted_karma %s, name ASC
+		LIMIT $2;
+	`, order)
+
+	rows, err := conn.Query(context.Background(), sql, chatId, limit)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check for any errors encountered during iteration
-	if err := rows.Err(); err != nil {
+	return collectWeightedLeaderboard(rows)
+}
+
+// KarmaLedgerQuery picks the karma_events a ledger leaderboard counts.
+type KarmaLedgerQuery struct {
+	ChatID int64
+	// ThreadID limits the board to one forum topic; 0 counts the whole chat.
+	ThreadID int
+	// Since counts only karma from then on; nil counts the whole ledger.
+	Since *time.Time
+	Limit int
+	// Weighted sums weighted values instead of raw ones. Only the boards of
+	// karma received have them.
+	Weighted bool
+}
+
+// receivedKarmaValue is the karma_events column the boards of karma received
+// sum for q.
+func receivedKarmaValue(q KarmaLedgerQuery) string {
+	if q.Weighted {
+		return "e.weighted_value"
+	}
+
+	return "e.karma_value"
+}
+
+// karmaGivenEvent and karmaTakenEvent count a karma event as one given or
+// taken, and its undo as one less, the same way RebuildKarmaFromLedger does.
+const (
+	karmaGivenEvent = `CASE
+		WHEN e.kind = 'karma' AND e.karma_value > 0 THEN 1
+		WHEN e.kind = 'undo' AND e.karma_value < 0 THEN -1
+		ELSE 0
+	END`
+	karmaTakenEvent = `CASE
+		WHEN e.kind = 'karma' AND e.karma_value < 0 THEN 1
+		WHEN e.kind = 'undo' AND e.karma_value > 0 THEN -1
+		ELSE 0
+	END`
+)
+
+// GetMostLovedUsersInLedger ranks the karma received in the query's window.
+func GetMostLovedUsersInLedger(conn shared.DBTX, q KarmaLedgerQuery) ([]UsersLovedHatedStruct, error) {
+	return getLedgerLeaderboard(conn, q, "receiver_user_id", receivedKarmaValue(q), "> 0", "DESC")
+}
+
+func GetMostHatedUsersInLedger(conn shared.DBTX, q KarmaLedgerQuery) ([]UsersLovedHatedStruct, error) {
+	return getLedgerLeaderboard(conn, q, "receiver_user_id", receivedKarmaValue(q), "< 0", "ASC")
+}
+
+func GetMostGenerousUsersInLedger(conn shared.DBTX, q KarmaLedgerQuery) ([]UsersLovedHatedStruct, error) {
+	return getLedgerLeaderboard(conn, q, "giver_user_id", karmaGivenEvent, "> 0", "DESC")
+}
+
+func GetMostCriticalUsersInLedger(conn shared.DBTX, q KarmaLedgerQuery) ([]UsersLovedHatedStruct, error) {
+	return getLedgerLeaderboard(conn, q, "giver_user_id", karmaTakenEvent, "> 0", "DESC")
+}
+
+// getLedgerLeaderboard ranks users by the sum of value over their
+// karma_events. Every fragment is a constant from the callers above, never
+// user input.
+func getLedgerLeaderboard(conn shared.DBTX, q KarmaLedgerQuery, userColumn string, value string, having string, order string) ([]UsersLovedHatedStruct, error) {
+	sql := fmt.Sprintf(`
+		SELECT TRIM(CONCAT(ur.first_name, ' ', COALESCE(ur.last_name,''))) as name, SUM(%[2]s) as karma
+		FROM karma_events e
+		LEFT JOIN users_ranking ur ON ur.user_id = e.%[1]s AND ur.group_id = e.chat_id
+		WHERE
+			e.chat_id = $1
+			AND ($2 = 0 OR e.message_thread_id = $2)
+			AND ($3::timestamptz IS NULL OR e.created_at >= $3)
+		GROUP BY e.%[1]s, name
+		HAVING SUM(%[2]s) %[3]s
+		ORDER BY karma %[4]s, name ASC
+		LIMIT $4;
+	`, userColumn, value, having, order)
+
+	rows, err := conn.Query(context.Background(), sql, q.ChatID, q.ThreadID, q.Since, q.Limit)
+	if err != nil {
 		return nil, err
 	}
 
-	return users, nil
+	if q.Weighted {
+		return collectWeightedLeaderboard(rows)
+	}
+	return collectLeaderboard(rows)
+}
+
+func collectLeaderboard(rows pgx.Rows) ([]UsersLovedHatedStruct, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (UsersLovedHatedStruct, error) {
+		var user UsersLovedHatedStruct
+		err := row.Scan(&user.Name, &user.Karma)
+		return user, err
+	})
 }
 
-func GetMostHatedUsers(conn *pgx.Conn, chatId int64) ([]UsersLovedHatedStruct, error) {
+func collectWeightedLeaderboard(rows pgx.Rows) ([]UsersLovedHatedStruct, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (UsersLovedHatedStruct, error) {
+		var user UsersLovedHatedStruct
+		err := row.Scan(&user.Name, &user.Weighted)
+		return user, err
+	})
+}
+
+// KarmaProfile is one user's standing in a chat.
+type KarmaProfile struct {
+	Karma      int
+	KarmaGiven int
+	KarmaTaken int
+	// Rank is 1 for the top karma. Users with the same karma share a rank,
+	// and Tied counts the others on it.
+	Rank  int
+	Tied  int
+	Users int
+	// LastKarma is when the user last received karma, nil if never since
+	// the ledger started.
+	LastKarma *time.Time
+}
+
+// GetUserKarmaProfile returns the user's karma and rank in the chat, or nil
+// when they have no users_ranking row there.
+func GetUserKarmaProfile(conn shared.DBTX, chatId int64, userId int64) (*KarmaProfile, error) {
 	sql := `
-		SELECT TRIM(CONCAT(first_name, ' ', COALESCE(last_name,''))) as name, karma FROM users_ranking ur
+		SELECT
+			ranked.karma,
+			ranked.karma_given,
+			ranked.karma_taken,
+			ranked.rank,
+			ranked.tied - 1,
+			ranked.users,
+			(
+				SELECT MAX(e.created_at)
+				FROM karma_events e
+				WHERE e.chat_id = $1 AND e.receiver_user_id = $2 AND e.kind = 'karma'
+			)
+		FROM (
+			SELECT
+				ur.user_id,
+				COALESCE(ur.karma, 0) AS karma,
+				ur.karma_given,
+				ur.karma_taken,
+				RANK() OVER (ORDER BY COALESCE(ur.karma, 0) DESC) AS rank,
+				COUNT(*) OVER (PARTITION BY COALESCE(ur.karma, 0)) AS tied,
+				COUNT(*) OVER () AS users
+			FROM users_ranking ur
+			WHERE ur.group_id = $1
+		) ranked
+		WHERE ranked.user_id = $2
+	`
+
+	var profile KarmaProfile
+	err := conn.QueryRow(context.Background(), sql, chatId, userId).Scan(
+		&profile.Karma,
+		&profile.KarmaGiven,
+		&profile.KarmaTaken,
+		&profile.Rank,
+		&profile.Tied,
+		&profile.Users,
+		&profile.LastKarma,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// GetTopKarmaGivers returns who gave the user the most karma in the chat,
+// counting what they gave minus what they took.
+func GetTopKarmaGivers(conn shared.DBTX, chatId int64, userId int64, limit int) ([]UsersLovedHatedStruct, error) {
+	sql := `
+		SELECT TRIM(CONCAT(ur.first_name, ' ', COALESCE(ur.last_name,''))) as name, SUM(e.karma_value) as karma
+		FROM karma_events e
+		LEFT JOIN users_ranking ur ON ur.user_id = e.giver_user_id AND ur.group_id = e.chat_id
 		WHERE
-			ur.group_id = $1
-		ORDER BY karma ASC, name ASC
-		LIMIT 10;
+			e.chat_id = $1
+			AND e.receiver_user_id = $2
+		GROUP BY e.giver_user_id, name
+		HAVING SUM(e.karma_value) > 0
+		ORDER BY karma DESC, name ASC
+		LIMIT $3;
 	`
 
-	rows, err := conn.Query(context.Background(), sql, chatId)
+	rows, err := conn.Query(context.Background(), sql, chatId, userId, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -261,7 +481,6 @@ func GetMostHatedUsers(conn *pgx.Conn, chatId int64) ([]UsersLovedHatedStruct, e
 		users = append(users, user)
 	}
 
-	// Check for any errors encountered during iteration
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
@@ -269,6 +488,49 @@ func GetMostHatedUsers(conn *pgx.Conn, chatId int64) ([]UsersLovedHatedStruct, e
 	return users, nil
 }
 
+// UpsertTopicKarma adds karmaValue to the user's score in one forum topic.
+func UpsertTopicKarma(conn shared.DBTX, groupID int64, threadID int, userID int64, karmaValue int) error {
+	_, err := conn.Exec(context.Background(), `
+		INSERT INTO topic_karma (group_id, message_thread_id, user_id, karma)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (group_id, message_thread_id, user_id)
+		DO UPDATE SET
+			karma = topic_karma.karma + EXCLUDED.karma,
+			updated_at = CURRENT_TIMESTAMP
+	`, groupID, threadID, userID, karmaValue)
+	return err
+}
+
+func GetMostLovedUsersInTopic(conn shared.DBTX, chatId int64, threadId int, limit int) ([]UsersLovedHatedStruct, error) {
+	return getTopicLeaderboard(conn, chatId, threadId, "DESC", limit)
+}
+
+func GetMostHatedUsersInTopic(conn shared.DBTX, chatId int64, threadId int, limit int) ([]UsersLovedHatedStruct, error) {
+	return getTopicLeaderboard(conn, chatId, threadId, "ASC", limit)
+}
+
+// getTopicLeaderboard ranks topic_karma, taking names from users_ranking. order
+// is a constant from the callers above, never user input.
+func getTopicLeaderboard(conn shared.DBTX, chatId int64, threadId int, order string, limit int) ([]UsersLovedHatedStruct, error) {
+	sql := fmt.Sprintf(`
+		SELECT TRIM(CONCAT(ur.first_name, ' ', COALESCE(ur.last_name,''))) as name, tk.karma
+		FROM topic_karma tk
+		LEFT JOIN users_ranking ur ON ur.user_id = tk.user_id AND ur.group_id = tk.group_id
+		WHERE
+			tk.group_id = $1
+			AND tk.message_thread_id = $2
+		ORDER BY tk.karma %s, name ASC
+		LIMIT $3;
+	`, order)
+
+	rows, err := conn.Query(context.Background(), sql, chatId, threadId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectLeaderboard(rows)
+}
+
 func createErrorsTable(conn *pgx.Conn) error {
 	sql := `
 		CREATE TABLE IF NOT EXISTS bot_errors (
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"bot/telegram/structs"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Backoff between attempts when an update can't be processed, usually because
+// the database is unreachable.
+const dispatchRetryDelay = 5 * time.Second
+
+type dispatchJob struct {
+	update structs.Update
+	done   chan error
+}
+
+// UpdateDispatcher fans updates out to a fixed pool of workers. Every update of
+// a chat goes to the same worker, so a chat's updates run in order while a slow
+// handler in one chat doesn't hold up the others. Each handler acquires its own
+// pooled connection.
+type UpdateDispatcher struct {
+	dbName string
+	queues []chan dispatchJob
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Held while sending to a queue so Shutdown never closes one mid-send.
+	sendMu sync.RWMutex
+
+	mu sync.Mutex
+	// Update IDs queued or running.
+	inFlight map[int]bool
+	// Update IDs finished while an older one is still in flight; once the
+	// watermark passes them they are forgotten.
+	finished map[int]bool
+	// Lowest update ID that may still need processing.
+	watermark int
+	// One past the highest update ID finished so far.
+	highest int
+	closed  bool
+}
+
+func NewUpdateDispatcher(dbName string, workers int, startOffset int) *UpdateDispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &UpdateDispatcher{
+		dbName:    dbName,
+		queues:    make([]chan dispatchJob, workers),
+		ctx:       ctx,
+		cancel:    cancel,
+		inFlight:  make(map[int]bool),
+		finished:  make(map[int]bool),
+		watermark: startOffset,
+	}
+
+	for i := range d.queues {
+		d.queues[i] = make(chan dispatchJob, 100)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+
+	return d
+}
+
+// Dispatch queues an update unless it is already queued, running or done. It
+// blocks when the chat's worker is backed up.
+func (d *UpdateDispatcher) Dispatch(update structs.Update) bool {
+	return d.enqueue(dispatchJob{update: update})
+}
+
+// DispatchAndWait queues an update and waits until it has been processed.
+func (d *UpdateDispatcher) DispatchAndWait(ctx context.Context, update structs.Update) error {
+	done := make(chan error, 1)
+	if !d.enqueue(dispatchJob{update: update, done: done}) {
+		return nil
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *UpdateDispatcher) enqueue(job dispatchJob) bool {
+	updateID := job.update.UpdateID
+
+	d.sendMu.RLock()
+	defer d.sendMu.RUnlock()
+
+	d.mu.Lock()
+	if d.closed || updateID < d.watermark || d.inFlight[updateID] || d.finished[updateID] {
+		d.mu.Unlock()
+		return false
+	}
+	d.inFlight[updateID] = true
+	d.mu.Unlock()
+
+	d.queues[d.queueIndex(updateChatID(job.update))] <- job
+	return true
+}
+
+// Watermark is the getUpdates offset that is safe to confirm: every update
+// below it has been processed.
+func (d *UpdateDispatcher) Watermark() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.watermark
+}
+
+// Shutdown stops accepting updates and waits for the queued ones to finish.
+func (d *UpdateDispatcher) Shutdown(ctx context.Context) error {
+	d.sendMu.Lock()
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	}
+	d.mu.Unlock()
+	d.sendMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		// Abandon retries; unfinished updates stay below the watermark and
+		// are fetched again on the next start.
+		d.cancel()
+		return fmt.Errorf("update dispatcher did not drain: %w", ctx.Err())
+	}
+}
+
+func (d *UpdateDispatcher) queueIndex(chatID int64) int {
+	index := chatID % int64(len(d.queues))
+	if index < 0 {
+		index = -index
+	}
+
+	return int(index)
+}
+
+func (d *UpdateDispatcher) work(queue chan dispatchJob) {
+	defer d.wg.Done()
+
+	for job := range queue {
+		err := d.process(job.update)
+		if err == nil {
+			d.complete(job.update.UpdateID)
+		}
+		if job.done != nil {
+			job.done <- err
+		}
+	}
+}
+
+// process retries until the update is handled, so one chat waits out a
+// database outage instead of skipping updates.
+func (d *UpdateDispatcher) process(update structs.Update) error {
+	for {
+		err := d.processOnce(update)
+		if err == nil {
+			return nil
+		}
+
+		fmt.Printf("Failed to process update %d: %s. Retrying in %v...\n", update.UpdateID, err, dispatchRetryDelay)
+		select {
+		case <-d.ctx.Done():
+			return err
+		case <-time.After(dispatchRetryDelay):
+		}
+	}
+}
+
+func (d *UpdateDispatcher) processOnce(update structs.Update) error {
+	conn, err := GlobalPoolManager.GetConnectionFromPool(d.dbName)
+	if err != nil {
+		return fmt.Errorf("get database connection: %w", err)
+	}
+	defer conn.Release()
+
+	return ProcessUpdate(d.ctx, conn, update)
+}
+
+func (d *UpdateDispatcher) complete(updateID int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inFlight, updateID)
+	d.finished[updateID] = true
+	if updateID >= d.highest {
+		d.highest = updateID + 1
+	}
+
+	// The watermark stops at the oldest update still in flight; once nothing
+	// is in flight it moves past everything seen so far.
+	next := d.highest
+	for inFlightID := range d.inFlight {
+		if inFlightID < next {
+			next = inFlightID
+		}
+	}
+	if next > d.watermark {
+		d.watermark = next
+	}
+
+	for finishedID := range d.finished {
+		if finishedID < d.watermark {
+			delete(d.finished, finishedID)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bot/telegram/errors"
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// What the bot did about an edited message, recorded in message_edits.
+const (
+	editActionCommandRerun = "command_rerun"
+	editActionKarmaIgnored = "karma_ignored"
+	editActionIgnored      = "ignored"
+)
+
+// handleEditedMessage applies the edit policy:
+//   - a command whose original invocation failed to parse runs again with the
+//     edited text, so fixing a typo in the arguments works;
+//   - every other edit, including one that turns a message into "+1", is
+//     ignored, so old messages can't be edited into karma and commands that
+//     already ran don't run twice.
+//
+// Each edit is recorded against the original message either way.
+func handleEditedMessage(conn shared.DBTX, update structs.Update) {
+	message := update.EditedMessage
+	action := decideEditAction(conn, message)
+
+	if action == editActionCommandRerun {
+		rerun := update
+		rerun.Message = message
+		rerun.EditedMessage = nil
+		if status := routeCommand(conn, rerun); status != "" {
+			recordCommandInvocation(conn, update.UpdateID, message, status)
+		} else {
+			action = editActionIgnored
+		}
+	}
+
+	recordMessageEdit(conn, update.UpdateID, message, action)
+}
+
+func decideEditAction(conn shared.DBTX, message *structs.Message) string {
+	status, err := commandInvocationStatus(conn, message.Chat.ID, message.MessageID)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: message.Chat.ID,
+			Error:   err.Error(),
+		})
+		return editActionIgnored
+	}
+
+	if status == commandStatusUsageError {
+		return editActionCommandRerun
+	}
+
+	if _, isKarma := matchKarmaMessage(conn, message); isKarma {
+		return editActionKarmaIgnored
+	}
+
+	return editActionIgnored
+}
+
+// commandInvocationStatus returns how the command in a message went, or "" if
+// the message never was a command.
+func commandInvocationStatus(conn shared.DBTX, chatId int64, messageId int) (string, error) {
+	var status string
+	err := conn.QueryRow(context.Background(), `
+		SELECT status
+		FROM command_invocations
+		WHERE chat_id = $1 AND message_id = $2
+	`, chatId, messageId).Scan(&status)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query command invocation: %w", err)
+	}
+
+	return status, nil
+}
+
+func recordCommandInvocation(conn shared.DBTX, updateId int, message *structs.Message, status string) {
+	var userId *int64
+	if message.From != nil {
+		userId = &message.From.ID
+	}
+
+	_, err := conn.Exec(context.Background(), `
+		INSERT INTO command_invocations (chat_id, message_id, update_id, user_id, command, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (chat_id, message_id)
+		DO UPDATE SET
+			update_id = EXCLUDED.update_id,
+			command = EXCLUDED.command,
+			status = EXCLUDED.status,
+			updated_at = CURRENT_TIMESTAMP
+	`, message.Chat.ID, message.MessageID, updateId, userId, commandName(message), status)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: message.Chat.ID,
+			Error:   fmt.Sprintf("record command invocation: %v", err),
+		})
+	}
+}
+
+func recordMessageEdit(conn shared.DBTX, updateId int, message *structs.Message, action string) {
+	var userId *int64
+	if message.From != nil {
+		userId = &message.From.ID
+	}
+
+	var editDate *time.Time
+	if message.EditDate != nil {
+		date := time.Unix(int64(*message.EditDate), 0).UTC()
+		editDate = &date
+	}
+
+	_, err := conn.Exec(context.Background(), `
+		INSERT INTO message_edits (chat_id, message_id, update_id, user_id, edit_date, text, action)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, message.Chat.ID, message.MessageID, updateId, userId, editDate, message.Text, action)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: message.Chat.ID,
+			Error:   fmt.Sprintf("record message edit: %v", err),
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"bot/telegram/botapi"
+	"bot/telegram/i18n"
 	"context"
 	"fmt"
 	"strings"
@@ -13,6 +15,7 @@ type DueEventReminder struct {
 	EventID         int64
 	ReminderID      int64
 	ChatID          int64
+	MessageThreadID *int
 	Title           string
 	Description     *string
 	MessageTemplate *string
@@ -26,8 +29,8 @@ func ProcessDueEventReminders(ctx context.Context, conn *pgx.Conn) error {
 	}
 
 	for _, reminder := range dueReminders {
-		message := buildReminderMessage(reminder)
-		if err := SendMessage(reminder.ChatID, message); err != nil {
+		message := buildReminderMessage(reminder, chatLanguage(conn, reminder.ChatID, nil))
+		if err := sendReminder(ctx, conn, reminder, message); err != nil {
 			if logErr := upsertEventDeliveryLog(ctx, conn, reminder, "failed", nil, err.Error()); logErr != nil {
 				return fmt.Errorf("send reminder: %w; log failure: %w", err, logErr)
 			}
@@ -51,12 +54,37 @@ func ProcessDueEventReminders(ctx context.Context, conn *pgx.Conn) error {
 	return nil
 }
 
+// sendReminder posts into the forum topic the event was created in. If that
+// topic has been deleted, the event falls back to the General topic for good.
+func sendReminder(ctx context.Context, conn *pgx.Conn, reminder DueEventReminder, message string) error {
+	if reminder.MessageThreadID == nil {
+		return SendMessage(reminder.ChatID, message)
+	}
+
+	err := SendMessageToThread(reminder.ChatID, *reminder.MessageThreadID, message)
+	if !botapi.IsThreadNotFound(err) {
+		return err
+	}
+
+	if _, err := conn.Exec(ctx, `
+		UPDATE events
+		SET message_thread_id = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, reminder.EventID); err != nil {
+		return fmt.Errorf("clear deleted topic of event %d: %w", reminder.EventID, err)
+	}
+
+	return SendMessage(reminder.ChatID, message)
+}
+
 func getDueEventReminders(ctx context.Context, conn *pgx.Conn) ([]DueEventReminder, error) {
 	rows, err := conn.Query(ctx, `
 		SELECT
 			e.id,
 			rem.id,
 			e.chat_id,
+			e.message_thread_id,
 			e.title,
 			e.description,
 			rem.message_template,
@@ -91,6 +119,7 @@ func getDueEventReminders(ctx context.Context, conn *pgx.Conn) ([]DueEventRemind
 			&reminder.EventID,
 			&reminder.ReminderID,
 			&reminder.ChatID,
+			&reminder.MessageThreadID,
 			&reminder.Title,
 			&reminder.Description,
 			&reminder.MessageTemplate,
@@ -241,14 +270,13 @@ func advanceCompletedRecurringOccurrences(ctx context.Context, conn *pgx.Conn) e
 	return nil
 }
 
-func buildReminderMessage(reminder DueEventReminder) string {
+func buildReminderMessage(reminder DueEventReminder, lang string) string {
 	if reminder.MessageTemplate != nil && strings.TrimSpace(*reminder.MessageTemplate) != "" {
 		return strings.TrimSpace(*reminder.MessageTemplate)
 	}
 
 	var b strings.Builder
-	b.WriteString("Reminder: ")
-	b.WriteString(strings.TrimSpace(reminder.Title))
+	b.WriteString(i18n.T(lang, "events.reminder", i18n.Args{"title": strings.TrimSpace(reminder.Title)}))
 
 	if reminder.Description != nil && strings.TrimSpace(*reminder.Description) != "" {
 		b.WriteString("\n")
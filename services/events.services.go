@@ -1,13 +1,12 @@
 package services
 
 import (
-	"bot/telegram/config"
 	"bot/telegram/errors"
+	"bot/telegram/i18n"
 	"bot/telegram/shared"
+	"bot/telegram/structs"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,23 +15,9 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-type chatMember struct {
-	User   chatMemberUser `json:"user"`
-	Status string         `json:"status"`
-}
-
-type chatMemberUser struct {
-	ID int64 `json:"id"`
-}
-
-type getChatAdministratorsResponse struct {
-	OK     bool         `json:"ok"`
-	Result []chatMember `json:"result"`
-}
-
 type adminCache struct {
 	timestamp time.Time
-	admins    map[int64]bool
+	admins    map[int64]structs.User
 }
 
 var (
@@ -42,7 +27,9 @@ var (
 
 const adminCacheTTL = 5 * time.Minute
 
-func getChatAdministrators(chatId int64) (map[int64]bool, error) {
+// getChatAdministrators returns the chat's creator and administrators, bots
+// included, by user ID.
+func getChatAdministrators(chatId int64) (map[int64]structs.User, error) {
 	adminCacheMu.RLock()
 	cached, found := adminCaches[chatId]
 	adminCacheMu.RUnlock()
@@ -51,33 +38,18 @@ func getChatAdministrators(chatId int64) (map[int64]bool, error) {
 		return cached.admins, nil
 	}
 
-	env := config.Current
-	url := fmt.Sprintf("%s%s/getChatAdministrators?chat_id=%d", env.TelegramBaseURL, env.Token, chatId)
-
-	resp, err := shared.CustomClient.Get(url)
+	members, err := TelegramClient().GetChatAdministrators(context.Background(), chatId)
 	if err != nil {
-		return nil, fmt.Errorf("getChatAdministrators request failed: %w", err)
+		return nil, fmt.Errorf("getChatAdministrators: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read getChatAdministrators response: %w", err)
-	}
-
-	var result getChatAdministratorsResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("parse getChatAdministrators response: %w", err)
-	}
-
-	if !result.OK {
-		return nil, fmt.Errorf("getChatAdministrators API returned not OK")
-	}
-
-	admins := make(map[int64]bool, len(result.Result))
-	for _, member := range result.Result {
+	admins := make(map[int64]structs.User, len(members))
+	for _, member := range members {
+		if member.User == nil {
+			continue
+		}
 		if member.Status == "creator" || member.Status == "administrator" {
-			admins[member.User.ID] = true
+			admins[member.User.ID] = *member.User
 		}
 	}
 
@@ -88,12 +60,19 @@ func getChatAdministrators(chatId int64) (map[int64]bool, error) {
 	return admins, nil
 }
 
+func forgetChatAdministrators(chatId int64) {
+	adminCacheMu.Lock()
+	delete(adminCaches, chatId)
+	adminCacheMu.Unlock()
+}
+
 func isUserAdmin(chatId int64, userId int64) (bool, error) {
 	admins, err := getChatAdministrators(chatId)
 	if err != nil {
 		return false, err
 	}
-	return admins[userId], nil
+	_, isAdmin := admins[userId]
+	return isAdmin, nil
 }
 
 type eventRow struct {
@@ -103,7 +82,7 @@ type eventRow struct {
 	EventAt *string
 }
 
-func ShowEvents(conn *pgx.Conn, chatId int64) {
+func ShowEvents(conn shared.DBTX, chatId int64, threadId int, lang string) {
 	ctx := context.Background()
 	rows, err := conn.Query(ctx, `
 		SELECT id, title, type,
@@ -120,7 +99,7 @@ func ShowEvents(conn *pgx.Conn, chatId int64) {
 			GroupID: chatId,
 			Error:   fmt.Sprintf("query events: %v", err),
 		})
-		_ = SendMessage(chatId, "Failed to retrieve events.")
+		_ = SendMessageToThread(chatId, threadId, i18n.T(lang, "events.query_failed", nil))
 		return
 	}
 	defer rows.Close()
@@ -133,19 +112,20 @@ func ShowEvents(conn *pgx.Conn, chatId int64) {
 				GroupID: chatId,
 				Error:   fmt.Sprintf("scan event row: %v", err),
 			})
-			_ = SendMessage(chatId, "Failed to read events.")
+			_ = SendMessageToThread(chatId, threadId, i18n.T(lang, "events.read_failed", nil))
 			return
 		}
 		events = append(events, e)
 	}
 
 	if len(events) == 0 {
-		_ = SendMessage(chatId, "No active events in this group.")
+		_ = SendMessageToThread(chatId, threadId, i18n.T(lang, "events.none", nil))
 		return
 	}
 
 	var b strings.Builder
-	b.WriteString("Active events:\n\n")
+	b.WriteString(i18n.N(lang, "events.header", len(events), nil))
+	b.WriteString("\n\n")
 	for _, e := range events {
 		eventAt := ""
 		if e.EventAt != nil {
@@ -158,51 +138,100 @@ func ShowEvents(conn *pgx.Conn, chatId int64) {
 		b.WriteString("\n")
 	}
 
-	_ = SendMessage(chatId, b.String())
+	_ = SendMessageToThread(chatId, threadId, b.String())
 }
 
-func DeleteEvent(conn *pgx.Conn, chatId int64, userId int64, eventIdStr string) {
-	isAdmin, err := isUserAdmin(chatId, userId)
+const deleteEventCallbackAction = "evdel"
+
+// DeleteEvent asks for confirmation with Delete/Cancel buttons; the event is
+// removed in handleDeleteEventCallback once an admin confirms.
+func DeleteEvent(conn shared.DBTX, chatId int64, threadId int, lang string, userId int64, eventId int64) {
+	ctx := context.Background()
+	var title string
+	err := conn.QueryRow(ctx, `
+		SELECT title
+		FROM events
+		WHERE id = $1 AND chat_id = $2
+	`, eventId, chatId).Scan(&title)
+	if err == pgx.ErrNoRows {
+		_ = SendMessageToThread(chatId, threadId, i18n.T(lang, "events.not_found", i18n.Args{"id": eventId}))
+		return
+	}
 	if err != nil {
 		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
 			GroupID:  chatId,
 			SenderID: userId,
-			Error:    fmt.Sprintf("check admin: %v", err),
+			Error:    fmt.Sprintf("query event %d: %v", eventId, err),
 		})
-		_ = SendMessage(chatId, "Failed to verify admin permissions.")
+		_ = SendMessageToThread(chatId, threadId, i18n.T(lang, "events.delete_failed", nil))
 		return
 	}
 
-	if !isAdmin {
-		_ = SendMessage(chatId, "Only group admins can delete events.")
+	eventIdArg := strconv.FormatInt(eventId, 10)
+	confirm, err := CallbackButton(i18n.T(lang, "events.delete_button", nil), deleteEventCallbackAction, eventIdArg, "yes")
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{GroupID: chatId, SenderID: userId, Error: err.Error()})
 		return
 	}
-
-	eventId, err := strconv.ParseInt(strings.TrimSpace(eventIdStr), 10, 64)
+	cancel, err := CallbackButton(i18n.T(lang, "events.cancel_button", nil), deleteEventCallbackAction, eventIdArg, "no")
 	if err != nil {
-		_ = SendMessage(chatId, "Invalid event ID. Usage: /delete_event <id>")
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{GroupID: chatId, SenderID: userId, Error: err.Error()})
 		return
 	}
 
-	ctx := context.Background()
-	tag, err := conn.Exec(ctx, `
+	keyboard := structs.InlineKeyboardMarkup{InlineKeyboard: [][]structs.InlineKeyboardButton{{confirm, cancel}}}
+	if err := SendMessageWithKeyboard(chatId, threadId, i18n.T(lang, "events.delete_confirm", i18n.Args{"id": eventId, "title": title}), keyboard); err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{GroupID: chatId, SenderID: userId, Error: err.Error()})
+	}
+}
+
+func handleDeleteEventCallback(c *CallbackContext) error {
+	if len(c.Args) != 2 {
+		return c.Answer(i18n.T(c.Lang, "callback.expired", nil), false)
+	}
+
+	eventId, err := strconv.ParseInt(c.Args[0], 10, 64)
+	if err != nil {
+		return c.Answer(i18n.T(c.Lang, "callback.expired", nil), false)
+	}
+
+	isAdmin, err := isUserAdmin(c.ChatID(), c.UserID())
+	if err != nil {
+		return fmt.Errorf("check admin: %w", err)
+	}
+	if !isAdmin {
+		return c.Answer(i18n.T(c.Lang, "events.delete_admin_only", nil), true)
+	}
+
+	if c.Args[1] != "yes" {
+		return c.EditText(i18n.T(c.Lang, "events.delete_cancelled", i18n.Args{"id": eventId}), nil)
+	}
+
+	tag, err := c.Conn.Exec(context.Background(), `
 		DELETE FROM events
 		WHERE id = $1 AND chat_id = $2
-	`, eventId, chatId)
+	`, eventId, c.ChatID())
 	if err != nil {
-		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-			GroupID:  chatId,
-			SenderID: userId,
-			Error:    fmt.Sprintf("delete event %d: %v", eventId, err),
-		})
-		_ = SendMessage(chatId, "Failed to delete event.")
-		return
+		_ = c.EditText(i18n.T(c.Lang, "events.delete_failed", nil), nil)
+		return fmt.Errorf("delete event %d: %w", eventId, err)
 	}
 
 	if tag.RowsAffected() == 0 {
-		_ = SendMessage(chatId, fmt.Sprintf("Event #%d not found in this group.", eventId))
-		return
+		return c.EditText(i18n.T(c.Lang, "events.not_found", i18n.Args{"id": eventId}), nil)
+	}
+
+	return c.EditText(i18n.T(c.Lang, "events.deleted", i18n.Args{"id": eventId}), nil)
+}
+
+func NewEventFromCommand(conn shared.DBTX, chatId int64, threadId int, lang string, userId int64) error {
+	if err := SendEventsWebAppMessage(chatId, threadId, lang, userId); err != nil {
+		fmt.Printf("Failed to send events WebApp message: %s\n", err)
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: chatId,
+			Error:   err.Error(),
+		})
+		return SendMessageToThread(chatId, threadId, i18n.T(lang, "events.webapp_link", i18n.Args{"url": BuildEventsWebAppURL(chatId, threadId, userId)}))
 	}
 
-	_ = SendMessage(chatId, fmt.Sprintf("Event #%d deleted.", eventId))
+	return nil
 }
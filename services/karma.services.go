@@ -1,331 +1,383 @@
 package services
 
 import (
+	"bot/telegram/botapi"
 	"bot/telegram/errors"
+	"bot/telegram/i18n"
 	"bot/telegram/shared"
 	"bot/telegram/structs"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
 	"time"
-
-	"github.com/jackc/pgx/v5"
 )
 
-func AddKarmaToUser(update structs.Update, karmaValue *int, conn *pgx.Conn) error {
-	chatId := update.Message.Chat.ID
-	replyToMessageId := update.Message.ReplyToMessage.MessageID
-	messageToGiveKarma := update.Message.ReplyToMessage.From
+// AddKarmaToUser moves karmaValue from the sender of update's message to
+// target, with its weighted value multiplied by scale. reason says what made
+// the message count as karma. It returns the target's new total and what the
+// karma was worth.
+func AddKarmaToUser(conn shared.DBTX, update structs.Update, target *structs.User, karmaValue int, scale float64, reason string) (karmaApplied, error) {
+	message := update.Message
+	return applyKarma(conn, karmaGrant{
+		ChatID:    message.Chat.ID,
+		ThreadID:  messageThreadID(message),
+		MessageID: message.MessageID,
+		UpdateID:  update.UpdateID,
+		Giver:     message.From,
+		Receiver:  target,
+		Value:     karmaValue,
+		Scale:     scale,
+		Reason:    reason,
+	})
+}
+
+// karmaGrant is one karma from Giver to Receiver and the message it came
+// from.
+type karmaGrant struct {
+	ChatID    int64
+	ThreadID  int
+	MessageID int
+	UpdateID  int
+	Giver     *structs.User
+	Receiver  *structs.User
+	Value     int
+	// Scale multiplies the weighted value; 1 counts it in full.
+	Scale  float64
+	Reason string
+}
+
+// karmaApplied is the receiver's new raw total after applyKarma and the
+// weighted value the karma counted as. Weighted is false when the chat
+// doesn't weigh karma, and WeightedValue is then the raw value.
+type karmaApplied struct {
+	Total         int
+	WeightedValue float64
+	Weighted      bool
+}
+
+// applyKarma moves the grant's value to the receiver, weighted by who gives
+// it when the chat weighs karma, counts it as given or taken by the giver and
+// writes both values to the karma_events ledger, all in one transaction.
+func applyKarma(conn shared.DBTX, grant karmaGrant) (karmaApplied, error) {
+	chatId := grant.ChatID
+	threadId := grant.ThreadID
+	target := grant.Receiver
+	karmaValue := grant.Value
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return karmaApplied{}, fmt.Errorf("begin karma transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	weight, weighted, err := karmaWeight(tx, chatId, grant.Giver.ID)
+	if err != nil {
+		return karmaApplied{}, fmt.Errorf("weigh karma: %w", err)
+	}
+	weightedValue := shared.WeighKarma(karmaValue, weight*grant.Scale)
 
 	totalKarma, err := UpsertUserKarma(
-		conn,
-		messageToGiveKarma.ID,
+		tx,
+		target.ID,
 		chatId,
-		messageToGiveKarma.FirstName,
-		messageToGiveKarma.LastName,
-		messageToGiveKarma.Username,
-		*karmaValue, // Dereference karmaValue here
-		0,           // karmaGivenIncrement for receiver
-		0,           // karmaTakenIncrement for receiver
+		target.FirstName,
+		target.LastName,
+		target.Username,
+		karmaValue,
+		weightedValue,
+		0, // karmaGivenIncrement for receiver
+		0, // karmaTakenIncrement for receiver
 	)
 	if err != nil {
-		return err
+		return karmaApplied{}, err
 	}
 
-	// Update karma_given or karma_taken for the sender
-	senderID := update.Message.From.ID
-	senderGroupID := update.Message.Chat.ID
-	senderFirstName := update.Message.From.FirstName
-	senderLastName := update.Message.From.LastName
-	senderUsername := update.Message.From.Username
-
-	senderKarmaGivenIncrement := 0
-	senderKarmaTakenIncrement := 0
-	karmaMessage := ""
-
-	if *karmaValue > 0 {
-		senderKarmaGivenIncrement = 1
-		karmaMessage = "given to"
-	} else if *karmaValue < 0 {
-		senderKarmaTakenIncrement = 1
-		karmaMessage = "taken from"
+	// Karma given inside a forum topic also counts towards that topic's board.
+	if threadId != 0 {
+		if err := UpsertTopicKarma(tx, chatId, threadId, target.ID, karmaValue); err != nil {
+			return karmaApplied{}, fmt.Errorf("error updating topic karma: %w", err)
+		}
 	}
 
+	// Update karma_given or karma_taken for the sender
+	sender := grant.Giver
+	senderKarmaGivenIncrement, senderKarmaTakenIncrement := karmaGivenTakenIncrements(karmaValue)
+
 	_, err = UpsertUserKarma(
-		conn,
-		senderID,
-		senderGroupID,
-		senderFirstName,
-		senderLastName,
-		senderUsername,
+		tx,
+		sender.ID,
+		chatId,
+		sender.FirstName,
+		sender.LastName,
+		sender.Username,
 		0, // karmaValue for sender (not changing sender's main karma score)
+		0, // weightedValue for sender
 		senderKarmaGivenIncrement,
 		senderKarmaTakenIncrement,
 	)
 	if err != nil {
-		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-			GroupID:    senderGroupID,
-			SenderID:   senderID,
-			ReceiverID: messageToGiveKarma.ID,
-			Error:      fmt.Sprintf("error updating karma_given/taken for sender: %v", err),
-		})
-		return fmt.Errorf("error updating karma_given/taken for sender: %w", err)
+		return karmaApplied{}, fmt.Errorf("error updating karma_given/taken for sender: %w", err)
 	}
 
-	successMessage := fmt.Sprintf("Karma %s %s. Total karma: %d", karmaMessage, messageToGiveKarma.FirstName, totalKarma)
-	if err := SendMessageWithReply(chatId, replyToMessageId, successMessage); err != nil {
-		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-			GroupID:    chatId,
-			SenderID:   update.Message.From.ID,
-			ReceiverID: messageToGiveKarma.ID,
-			Error:      err.Error(),
-		})
+	_, err = tx.Exec(ctx, `
+		INSERT INTO karma_events (
+			chat_id, giver_user_id, receiver_user_id, karma_value, weighted_value, kind, reason,
+			message_id, update_id, message_thread_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, chatId, sender.ID, target.ID, karmaValue, weightedValue, karmaEventKarma, grant.Reason, grant.MessageID, grant.UpdateID, threadId)
+	if err != nil {
+		return karmaApplied{}, fmt.Errorf("record karma event: %w", err)
 	}
 
-	// TODO: Add karma restrictrions per group
-
-	return nil
-}
-
-// makeTelegramAPIRequest performs HTTP request to Telegram API with retry logic
-func makeTelegramAPIRequest(url string) (*http.Response, error) {
-	var lastErr error
-
-	// Retry up to 3 times with exponential backoff
-	for attempt := 0; attempt < 3; attempt++ {
-		response, err := shared.CustomClient.Get(url)
-		if err != nil {
-			lastErr = err
-			if attempt < 2 { // Don't sleep on the last attempt
-				waitTime := time.Duration(attempt+1) * time.Second
-				fmt.Printf("Telegram API request failed (attempt %d/3): %s. Retrying in %v...\n", attempt+1, err, waitTime)
-				time.Sleep(waitTime)
-			}
-			continue
-		}
-
-		// Check if the response status is OK
-		if response.StatusCode != http.StatusOK {
-			if response.StatusCode == http.StatusConflict {
-				response.Body.Close()
-				return nil, fmt.Errorf("telegram getUpdates conflict (409): another bot instance is running")
-			}
-			response.Body.Close()
-			lastErr = fmt.Errorf("telegram API returned status %d", response.StatusCode)
-			if attempt < 2 {
-				waitTime := time.Duration(attempt+1) * time.Second
-				fmt.Printf("Telegram API returned error status %d (attempt %d/3). Retrying in %v...\n", response.StatusCode, attempt+1, waitTime)
-				time.Sleep(waitTime)
-			}
-			continue
-		}
-
-		return response, nil
+	if err := tx.Commit(ctx); err != nil {
+		return karmaApplied{}, fmt.Errorf("commit karma transaction: %w", err)
 	}
 
-	return nil, fmt.Errorf("telegram API request failed after 3 attempts: %w", lastErr)
+	return karmaApplied{Total: totalKarma, WeightedValue: weightedValue, Weighted: weighted}, nil
 }
 
-func ProcessTelegramMessages(telegramUrl string, token string, offset int, conn *pgx.Conn) (int, error) {
-	longPollTimeout := 25
-	completeUrl := fmt.Sprintf("%s%s/getUpdates?offset=%d&timeout=%d", telegramUrl, token, offset, longPollTimeout)
-
-	response, err := makeTelegramAPIRequest(completeUrl)
-	if err != nil {
-		return offset, fmt.Errorf("failed to get updates from Telegram API: %w", err)
-	}
-
-	defer response.Body.Close()
-
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		return offset, fmt.Errorf("failed to read response body: %w", err)
+// karmaGivenTakenIncrements is how one karma of karmaValue moves the giver's
+// karma_given and karma_taken counters.
+func karmaGivenTakenIncrements(karmaValue int) (int, int) {
+	if karmaValue > 0 {
+		return 1, 0
 	}
-
-	var result structs.UpdateResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return offset, fmt.Errorf("failed to parse JSON response: %w", err)
+	if karmaValue < 0 {
+		return 0, 1
 	}
 
-	newOffset := offset
-	for _, update := range result.Result {
-		newOffset = update.UpdateID + 1
-
-		if update.Message == nil {
-			continue
-		}
-
-		chatId := update.Message.Chat.ID
-		if isBotCommand(update.Message.Text, "/command") {
-			if err := SendCommandsHelp(chatId); err != nil {
-				fmt.Printf("Failed to send command help: %s\n", err)
-				_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-					GroupID: chatId,
-					Error:   err.Error(),
-				})
-			}
-			continue
-		}
+	return 0, 0
+}
 
-		if isAskCatholicChurchCommand(update.Message.Text) {
-			if err := AskCatholicChurchFromCommand(update); err != nil {
-				fmt.Printf("Failed to answer Catholic Church question: %s\n", err)
-				_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-					GroupID: chatId,
-					Error:   err.Error(),
-				})
-			}
-			continue
-		}
+// fetchUpdates calls getUpdates with retry logic
+func fetchUpdates(ctx context.Context, offset int) ([]structs.Update, error) {
+	longPollTimeout := 25
+	var lastErr error
 
-		if isSetBirthdayCommand(update.Message.Text) {
-			if err := SetBirthdayFromCommand(conn, update); err != nil {
-				fmt.Printf("Failed to set birthday: %s\n", err)
-				_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-					GroupID: chatId,
-					Error:   err.Error(),
-				})
-				_ = SendMessageWithReply(chatId, update.Message.MessageID, "Birthday event was not created. Please try again later.")
-			}
-			continue
+	// Retry up to 3 times with exponential backoff
+	for attempt := 0; attempt < 3; attempt++ {
+		updates, err := TelegramClient().GetUpdates(ctx, botapi.GetUpdatesParams{
+			Offset:  offset,
+			Timeout: longPollTimeout,
+		})
+		if err == nil {
+			return updates, nil
 		}
 
-		if strings.Contains(update.Message.Text, "/show_events") {
-			ShowEvents(conn, chatId)
-			continue
+		if botapi.IsConflict(err) {
+			return nil, fmt.Errorf("telegram getUpdates conflict (409): another bot instance is running or a webhook is set: %w", err)
 		}
 
-		if strings.Contains(update.Message.Text, "/delete_event") {
-			fields := strings.Fields(strings.TrimSpace(update.Message.Text))
-			eventId := ""
-			if len(fields) >= 2 {
-				eventId = fields[1]
-			}
-			DeleteEvent(conn, chatId, update.Message.From.ID, eventId)
-			continue
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
 
-		if strings.Contains(update.Message.Text, "/new_event") {
-			userId := update.Message.From.ID
-			if err := SendEventsWebAppMessage(chatId, userId); err != nil {
-				fmt.Printf("Failed to send events WebApp message: %s\n", err)
-				_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-					GroupID: chatId,
-					Error:   err.Error(),
-				})
-				_ = SendMessage(chatId, "Open the event form here: "+BuildEventsWebAppURL(chatId, userId))
+		lastErr = err
+		if attempt < 2 { // Don't sleep on the last attempt
+			waitTime := time.Duration(attempt+1) * time.Second
+			if apiErr, ok := botapi.AsAPIError(err); ok && apiErr.RetryAfter > 0 {
+				waitTime = apiErr.RetryAfterDuration()
 			}
-			continue
+			fmt.Printf("Telegram getUpdates failed (attempt %d/3): %s. Retrying in %v...\n", attempt+1, err, waitTime)
+			time.Sleep(waitTime)
 		}
+	}
 
-		// Handle /lovedusers command
-		if strings.Contains(update.Message.Text, "/lovedusers") {
-			MostLovedUsers(conn, chatId)
-			continue
-		}
+	return nil, fmt.Errorf("telegram API request failed after 3 attempts: %w", lastErr)
+}
 
-		// Handle /hatedusers command
-		if strings.Contains(update.Message.Text, "/hatedusers") {
-			MostHatedUsers(conn, chatId)
-			continue // Move to next update
-		}
+// ProcessTelegramMessages fetches the updates at offset and hands them to the
+// dispatcher. It returns the offset to poll next, which never moves past an
+// update that is still being processed, so a crash can't lose it.
+func ProcessTelegramMessages(ctx context.Context, dispatcher *UpdateDispatcher, offset int) (int, error) {
+	updates, err := fetchUpdates(ctx, offset)
+	if err != nil {
+		return offset, fmt.Errorf("failed to get updates from Telegram API: %w", err)
+	}
 
-		// Handle +1/-1 karma updates
-		isPlusMinusOne, karmaValue := shared.ParsePlusMinusOneFromMessage(update.Message.Text)
-		if isPlusMinusOne {
-			UpdateKarma(conn, update, karmaValue)
-		}
+	for _, update := range updates {
+		dispatcher.Dispatch(update)
 	}
 
-	return newOffset, nil
+	return dispatcher.Watermark(), nil
 }
 
-func MostLovedUsers(conn *pgx.Conn, chatId int64) {
-	lovedUsers, err := GetMostLovedUsers(conn, chatId)
-	if err != nil {
-		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{Error: err.Error()})
+// HandleUpdate routes a single Telegram update to its handler. Both the
+// getUpdates polling loop and the webhook server feed updates through here.
+func HandleUpdate(conn shared.DBTX, update structs.Update) {
+	if update.CallbackQuery != nil {
+		routeCallbackQuery(conn, update.CallbackQuery)
 		return
 	}
 
-	if len(lovedUsers) == 0 {
-		_ = SendMessage(chatId, "No users found for this group yet.")
+	if update.EditedMessage != nil {
+		handleEditedMessage(conn, update)
 		return
 	}
 
-	var b strings.Builder
-	b.WriteString("Most loved users (top 10):\n\n")
-	for i, u := range lovedUsers {
-		name := strings.TrimSpace(u.Name)
-		if name == "" {
-			name = "Unknown"
-		}
-		b.WriteString(fmt.Sprintf("%d) %s — %d\n", i+1, name, u.Karma))
+	if update.Message == nil {
+		return
 	}
-	_ = SendMessage(chatId, b.String())
-}
 
-func MostHatedUsers(conn *pgx.Conn, chatId int64) {
-	hatedUsers, err := GetMostHatedUsers(conn, chatId)
-	if err != nil {
-		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{Error: err.Error()})
+	if handleChatMigrationMessage(conn, update.Message) {
 		return
 	}
 
-	if len(hatedUsers) == 0 {
-		_ = SendMessage(chatId, "No users found for this group yet.")
+	RememberUsers(conn, update.Message)
+
+	if status := routeCommand(conn, update); status != "" {
+		recordCommandInvocation(conn, update.UpdateID, update.Message, status)
 		return
 	}
 
-	var b strings.Builder
-	b.WriteString("Most hated folks here (top 10):\n\n")
-	for i, u := range hatedUsers {
-		name := strings.TrimSpace(u.Name)
-		if name == "" {
-			name = "Unknown"
-		}
-		b.WriteString(fmt.Sprintf("%d) %s — %d\n", i+1, name, u.Karma))
+	// Handle "+1"/"-1" and the chat's own karma triggers
+	if trigger, ok := matchKarmaMessage(conn, update.Message); ok {
+		UpdateKarma(conn, update, trigger)
 	}
-	_ = SendMessage(chatId, b.String())
 }
 
-func UpdateKarma(conn *pgx.Conn, update structs.Update, karmaValue *int) {
+// UpdateKarma gives or takes karma for a "+1"/"-1" or trigger message. The targets are
+// the users the message mentions, or else the author of the message it
+// replies to.
+func UpdateKarma(conn shared.DBTX, update structs.Update, trigger structs.KarmaTrigger) {
 	message := update.Message
-	if update.Message == nil || message.From == nil || message.ReplyToMessage == nil || message.ReplyToMessage.From == nil {
+	if message == nil || message.From == nil {
 		return
 	}
 
 	chatId := message.Chat.ID
-	senderMessageId := message.MessageID
+	threadId := messageThreadID(message)
+	lang := chatLanguage(conn, chatId, message.From)
+
+	targets, replyTo, err := karmaTargets(conn, message, lang)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID:  chatId,
+			SenderID: message.From.ID,
+			Error:    err.Error(),
+		})
+		return
+	}
+	if len(targets) == 0 {
+		return
+	}
 
 	// Handle adding/removing karma
-	if err := KarmaValidations(update, conn); err != nil {
+	targets, err = KarmaValidations(conn, message, targets, replyTo, trigger.Value, lang)
+	if err != nil {
 		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-			GroupID:    chatId,
-			SenderID:   update.Message.From.ID,
-			ReceiverID: update.Message.ReplyToMessage.From.ID,
-			Error:      err.Error(),
+			GroupID:  chatId,
+			SenderID: message.From.ID,
+			Error:    err.Error(),
 		})
 		return
 	}
 
-	if err := AddKarmaToUser(update, karmaValue, conn); err != nil {
-		errorInput := errors.ErrorRecordInput{
-			SenderID:   update.Message.ReplyToMessage.From.ID,
-			ReceiverID: update.Message.From.ID,
-			GroupID:    chatId,
-			Error:      err.Error(),
+	karmaMessageKey := "karma.given"
+	if trigger.Value < 0 {
+		karmaMessageKey = "karma.taken"
+	}
+
+	lines := make([]string, 0, len(targets))
+	for _, target := range targets {
+		verdict := screenKarma(conn, update, target, trigger, lang)
+		if verdict.Held != "" {
+			lines = append(lines, verdict.Held)
+			continue
 		}
-		if err := SendMessageWithReply(chatId, senderMessageId, "Error adding karma"); err != nil {
+
+		applied, err := AddKarmaToUser(conn, update, target, verdict.Value, verdict.Scale, karmaReason(trigger))
+		if err != nil {
 			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
 				GroupID:    chatId,
-				SenderID:   update.Message.From.ID,
-				ReceiverID: update.Message.ReplyToMessage.From.ID,
+				SenderID:   message.From.ID,
+				ReceiverID: target.ID,
 				Error:      err.Error(),
 			})
-			return
+			if verdict.Flag != nil {
+				settleKarmaFlag(conn, message, *verdict.Flag, nil, lang)
+			}
+			lines = append(lines, i18n.T(lang, "karma.failed", nil))
+			continue
+		}
+		if verdict.Flag != nil {
+			settleKarmaFlag(conn, message, *verdict.Flag, &applied.WeightedValue, lang)
 		}
-		_ = errors.CreateErrorRecord(conn, errorInput)
+		line := i18n.T(lang, karmaMessageKey, i18n.Args{"name": target.FirstName, "total": applied.Total})
+		if applied.Weighted {
+			line += " " + i18n.T(lang, "karma.weighted", i18n.Args{"value": formatWeightedValue(applied.WeightedValue)})
+		}
+		if verdict.Reduced {
+			line = i18n.T(lang, "karma.abuse_reduced", i18n.Args{"name": target.FirstName, "value": formatWeightedValue(applied.WeightedValue), "total": applied.Total})
+		}
+		lines = append(lines, line)
 	}
+
+	if err := SendMessageWithReply(chatId, threadId, replyTo, strings.Join(lines, "\n")); err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID:  chatId,
+			SenderID: message.From.ID,
+			Error:    err.Error(),
+		})
+	}
+}
+
+// karmaTargets resolves who a karma message is for. Mentions win over the
+// replied-to message, so "+1 @maria" in reply to someone else goes to Maria.
+// Replies answer the karma message when targets were mentioned, and the
+// replied-to message otherwise. Usernames nobody we've seen holds are
+// reported to the chat and skipped.
+func karmaTargets(conn shared.DBTX, message *structs.Message, lang string) ([]*structs.User, int, error) {
+	mentions := ParseMessage(message).Mentions
+	if len(mentions) == 0 {
+		if message.ReplyToMessage == nil || message.ReplyToMessage.From == nil {
+			return nil, 0, nil
+		}
+
+		return []*structs.User{message.ReplyToMessage.From}, message.ReplyToMessage.MessageID, nil
+	}
+
+	var usernames []string
+	for _, mention := range mentions {
+		if mention.User == nil {
+			usernames = append(usernames, mention.Username)
+		}
+	}
+	known, err := findUsersByUsername(conn, usernames)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var targets []*structs.User
+	var unknown []string
+	seen := make(map[int64]bool, len(mentions))
+	for _, mention := range mentions {
+		target := mention.User
+		if target == nil {
+			target = known[strings.ToLower(mention.Username)]
+		}
+		if target == nil {
+			unknown = append(unknown, "@"+mention.Username)
+			continue
+		}
+		if !seen[target.ID] {
+			seen[target.ID] = true
+			targets = append(targets, target)
+		}
+	}
+
+	if len(unknown) > 0 {
+		text := i18n.T(lang, "karma.unknown_users", i18n.Args{"usernames": strings.Join(unknown, ", ")})
+		if err := SendMessageWithReply(message.Chat.ID, messageThreadID(message), message.MessageID, text); err != nil {
+			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+				GroupID:  message.Chat.ID,
+				SenderID: message.From.ID,
+				Error:    err.Error(),
+			})
+		}
+	}
+
+	return targets, message.MessageID, nil
 }
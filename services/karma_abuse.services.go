@@ -0,0 +1,479 @@
+package services
+
+import (
+	"bot/telegram/errors"
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// What a chat does with karma the abuse checks flag, stored in
+// chat_settings.abuse_action. Chats opt in; every action but off reports to
+// the admins.
+const (
+	karmaAbuseOff     = "off"
+	karmaAbuseReport  = "report"
+	karmaAbuseIgnore  = "ignore"
+	karmaAbuseReduce  = "reduce"
+	karmaAbuseApprove = "approve"
+)
+
+var karmaAbuseActions = []string{karmaAbuseOff, karmaAbuseReport, karmaAbuseIgnore, karmaAbuseReduce, karmaAbuseApprove}
+
+// Rules recorded in karma_abuse_flags.
+const (
+	karmaAbuseReciprocal = "reciprocal"
+	karmaAbuseRepeated   = "repeated"
+	karmaAbuseBurst      = "burst"
+)
+
+// Statuses recorded in karma_abuse_flags.
+const (
+	karmaFlagCounted  = "counted"
+	karmaFlagIgnored  = "ignored"
+	karmaFlagReduced  = "reduced"
+	karmaFlagPending  = "pending"
+	karmaFlagApproved = "approved"
+	karmaFlagRejected = "rejected"
+)
+
+const (
+	// karmaAbuseReciprocalMin is how many positive karma two people must each
+	// have given the other within the abuse window to be trading karma.
+	karmaAbuseReciprocalMin = 2
+	// karmaFlagsLimit is how many flags /karma_flags lists.
+	karmaFlagsLimit = 10
+)
+
+// karmaVerdict is what the abuse checks decided about one karma. Scale
+// multiplies its weighted value, below 1 when Reduced. Held is the reply line
+// when nothing is counted now, and Flag the flag to settle when karma that was
+// flagged still counts.
+type karmaVerdict struct {
+	Value   int
+	Scale   float64
+	Reduced bool
+	Held    string
+	Flag    *karmaFlag
+}
+
+// ReduceKarma is what karma of value is worth with the reduce action at
+// percent, as a share of its weighted value rather than of the whole karma.
+func ReduceKarma(value int, percent int) float64 {
+	return shared.WeighKarma(value, reduceScale(percent))
+}
+
+func reduceScale(percent int) float64 {
+	return float64(percent) / 100
+}
+
+// screenKarma runs the chat's abuse checks on karma from the message's sender
+// to target, records and reports a flag when one fires, and says what to
+// count. A failing check lets the karma through.
+func screenKarma(conn shared.DBTX, update structs.Update, target *structs.User, trigger structs.KarmaTrigger, lang string) karmaVerdict {
+	message := update.Message
+	chatId := message.Chat.ID
+	verdict := karmaVerdict{Value: trigger.Value, Scale: 1}
+
+	settings, err := getKarmaSettings(conn, chatId)
+	if err == nil && settings.AbuseAction != karmaAbuseOff {
+		var rule string
+		rule, err = detectKarmaAbuse(conn, chatId, message.From.ID, target.ID, trigger.Value, settings)
+		if err == nil && rule != "" {
+			verdict, err = flagKarma(conn, update, target, trigger, rule, settings, lang)
+		}
+	}
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID:    chatId,
+			SenderID:   message.From.ID,
+			ReceiverID: target.ID,
+			Error:      fmt.Sprintf("screen karma: %v", err),
+		})
+		return karmaVerdict{Value: trigger.Value, Scale: 1}
+	}
+
+	return verdict
+}
+
+// detectKarmaAbuse returns the first rule karma of value from giverId to
+// receiverId breaks, or "". It counts what KarmaAbuseRule needs; undone
+// karma doesn't count.
+func detectKarmaAbuse(conn shared.DBTX, chatId int64, giverId int64, receiverId int64, value int, settings KarmaSettings) (string, error) {
+	now := time.Now().UTC()
+	var counts KarmaAbuseCounts
+
+	if value < 0 && settings.AbuseBurstGivers > 0 {
+		err := conn.QueryRow(context.Background(), `
+			SELECT COUNT(DISTINCT e.giver_user_id)
+			FROM karma_events e
+			WHERE e.chat_id = $1
+				AND e.receiver_user_id = $2
+				AND e.giver_user_id <> $3
+				AND e.kind = 'karma'
+				AND e.karma_value < 0
+				AND e.created_at >= $4
+				AND NOT EXISTS (SELECT 1 FROM karma_events u WHERE u.reverts_event_id = e.id)
+		`, chatId, receiverId, giverId, now.Add(-settings.AbuseBurstWindow)).Scan(&counts.OtherBurstGivers)
+		if err != nil {
+			return "", fmt.Errorf("count negative karma burst: %w", err)
+		}
+		if rule := KarmaAbuseRule(value, settings, counts); rule != "" {
+			return rule, nil
+		}
+	}
+
+	if settings.AbusePairLimit == 0 {
+		return "", nil
+	}
+
+	since := now.Add(-settings.AbuseWindow)
+	var err error
+	counts.Given, err = countPairKarma(conn, chatId, giverId, receiverId, value, since)
+	if err != nil {
+		return "", err
+	}
+	if value > 0 && counts.Given+1 >= karmaAbuseReciprocalMin {
+		counts.GivenBack, err = countPairKarma(conn, chatId, receiverId, giverId, value, since)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return KarmaAbuseRule(value, settings, counts), nil
+}
+
+// KarmaAbuseCounts is the karma the abuse rules look at, all within the
+// chat's windows.
+type KarmaAbuseCounts struct {
+	// OtherBurstGivers is how many other people took karma from the
+	// receiver within the burst window.
+	OtherBurstGivers int
+	// Given is how much karma with the same sign the giver already gave the
+	// receiver, and GivenBack how much the receiver gave the giver.
+	Given     int
+	GivenBack int
+}
+
+// KarmaAbuseRule returns the first rule karma of value breaks given counts,
+// or "": a burst of negative karma on the receiver, the two trading positive
+// karma, or the giver giving the receiver karma too often.
+func KarmaAbuseRule(value int, settings KarmaSettings, counts KarmaAbuseCounts) string {
+	switch {
+	case value < 0 && settings.AbuseBurstGivers > 0 && counts.OtherBurstGivers+1 >= settings.AbuseBurstGivers:
+		return karmaAbuseBurst
+	case settings.AbusePairLimit == 0:
+		return ""
+	case value > 0 && counts.Given+1 >= karmaAbuseReciprocalMin && counts.GivenBack >= karmaAbuseReciprocalMin:
+		return karmaAbuseReciprocal
+	case counts.Given >= settings.AbusePairLimit:
+		return karmaAbuseRepeated
+	}
+
+	return ""
+}
+
+// countPairKarma counts the karma with the sign of value giverId gave
+// receiverId since then.
+func countPairKarma(conn shared.DBTX, chatId int64, giverId int64, receiverId int64, value int, since time.Time) (int, error) {
+	var count int
+	err := conn.QueryRow(context.Background(), `
+		SELECT COUNT(*)
+		FROM karma_events e
+		WHERE e.chat_id = $1
+			AND e.giver_user_id = $2
+			AND e.receiver_user_id = $3
+			AND e.kind = 'karma'
+			AND SIGN(e.karma_value) = SIGN($4::int)
+			AND e.created_at >= $5
+			AND NOT EXISTS (SELECT 1 FROM karma_events u WHERE u.reverts_event_id = e.id)
+	`, chatId, giverId, receiverId, value, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count karma from %d to %d: %w", giverId, receiverId, err)
+	}
+
+	return count, nil
+}
+
+// flagKarma applies the chat's abuse action to flagged karma and records the
+// flag. Held karma is reported to the admins at once; karma that still counts
+// comes back as the verdict's Flag, for settleKarmaFlag once it is applied.
+func flagKarma(conn shared.DBTX, update structs.Update, target *structs.User, trigger structs.KarmaTrigger, rule string, settings KarmaSettings, lang string) (karmaVerdict, error) {
+	message := update.Message
+	verdict := karmaVerdict{Value: trigger.Value, Scale: 1}
+	status := karmaFlagCounted
+
+	switch settings.AbuseAction {
+	case karmaAbuseIgnore:
+		status = karmaFlagIgnored
+	case karmaAbuseReduce:
+		verdict.Scale = reduceScale(settings.AbuseReducePercent)
+		verdict.Reduced = true
+		status = karmaFlagReduced
+		if ReduceKarma(trigger.Value, settings.AbuseReducePercent) == 0 {
+			status = karmaFlagIgnored
+		}
+	case karmaAbuseApprove:
+		status = karmaFlagPending
+	}
+
+	var flagId int64
+	err := conn.QueryRow(context.Background(), `
+		INSERT INTO karma_abuse_flags (
+			chat_id, giver_user_id, receiver_user_id, karma_value, rule, status, reason,
+			message_id, update_id, message_thread_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, message.Chat.ID, message.From.ID, target.ID, trigger.Value, rule, status, karmaReason(trigger),
+		message.MessageID, update.UpdateID, messageThreadID(message)).Scan(&flagId)
+	if err != nil {
+		return karmaVerdict{Value: trigger.Value, Scale: 1}, fmt.Errorf("record karma abuse flag: %w", err)
+	}
+
+	flag := karmaFlag{
+		ID:           flagId,
+		GiverName:    telegramUserDisplayName(message.From),
+		ReceiverName: telegramUserDisplayName(target),
+		Value:        trigger.Value,
+		Rule:         rule,
+		Status:       status,
+	}
+	name := target.FirstName
+	switch status {
+	case karmaFlagIgnored:
+		verdict.Held = i18n.T(lang, "karma.abuse_ignored", i18n.Args{"name": name})
+	case karmaFlagPending:
+		verdict.Held = i18n.T(lang, "karma.abuse_pending", i18n.Args{"name": name, "id": flagId})
+	default:
+		verdict.Flag = &flag
+		return verdict, nil
+	}
+
+	reportKarmaAbuse(conn, message.Chat, flag, message.From.ID, lang)
+
+	return verdict, nil
+}
+
+// settleKarmaFlag stores the weighted value flagged karma was counted with,
+// as karma_events has it, and reports the flag to the admins. applied is nil
+// when counting the karma failed.
+func settleKarmaFlag(conn shared.DBTX, message *structs.Message, flag karmaFlag, applied *float64, lang string) {
+	if applied != nil {
+		_, err := conn.Exec(context.Background(), `UPDATE karma_abuse_flags SET applied_value = $2 WHERE id = $1`, flag.ID, *applied)
+		if err != nil {
+			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+				GroupID: message.Chat.ID,
+				Error:   fmt.Sprintf("settle karma abuse flag %d: %v", flag.ID, err),
+			})
+		}
+		flag.AppliedValue = applied
+	}
+
+	reportKarmaAbuse(conn, message.Chat, flag, message.From.ID, lang)
+}
+
+// reportKarmaAbuse sends the flag privately to the chat's admins other than
+// the giver and bots. Admins who never started a chat with the bot can't be reached
+// and still find it in /karma_flags.
+func reportKarmaAbuse(conn shared.DBTX, chat structs.Chat, flag karmaFlag, giverId int64, lang string) {
+	admins, err := getChatAdministrators(chat.ID)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: chat.ID,
+			Error:   fmt.Sprintf("report karma abuse: %v", err),
+		})
+		return
+	}
+
+	text := i18n.T(lang, "abuse.report", i18n.Args{
+		"chat": chat.Title,
+		"flag": formatKarmaFlag(lang, flag),
+	})
+	for adminId, admin := range admins {
+		if adminId == giverId || admin.IsBot {
+			continue
+		}
+		_ = SendMessage(adminId, text)
+	}
+}
+
+type karmaFlag struct {
+	ID           int64
+	GiverName    string
+	ReceiverName string
+	Value        int
+	AppliedValue *float64
+	Rule         string
+	Status       string
+	ResolverName string
+	CreatedAt    time.Time
+}
+
+func formatKarmaFlag(lang string, flag karmaFlag) string {
+	var applied float64
+	if flag.AppliedValue != nil {
+		applied = *flag.AppliedValue
+	}
+
+	args := i18n.Args{
+		"id":       flag.ID,
+		"giver":    historyName(lang, flag.GiverName),
+		"receiver": historyName(lang, flag.ReceiverName),
+		"value":    fmt.Sprintf("%+d", flag.Value),
+		"applied":  formatWeightedValue(applied),
+		"admin":    historyName(lang, flag.ResolverName),
+	}
+
+	return fmt.Sprintf("#%d %s (%s): %s", flag.ID,
+		i18n.T(lang, "abuse.rule_"+flag.Rule, args),
+		args["value"],
+		i18n.T(lang, "abuse.status_"+flag.Status, args))
+}
+
+// getKarmaFlags returns the chat's latest flags, newest first. Names are ""
+// for users we never saw.
+func getKarmaFlags(conn shared.DBTX, chatId int64, limit int) ([]karmaFlag, error) {
+	rows, err := conn.Query(context.Background(), `
+		SELECT
+			f.id,
+			TRIM(CONCAT(giver.first_name, ' ', COALESCE(giver.last_name, ''))),
+			TRIM(CONCAT(receiver.first_name, ' ', COALESCE(receiver.last_name, ''))),
+			f.karma_value,
+			f.applied_value,
+			f.rule,
+			f.status,
+			TRIM(CONCAT(resolver.first_name, ' ', COALESCE(resolver.last_name, ''))),
+			f.created_at
+		FROM karma_abuse_flags f
+		LEFT JOIN telegram_users giver ON giver.user_id = f.giver_user_id
+		LEFT JOIN telegram_users receiver ON receiver.user_id = f.receiver_user_id
+		LEFT JOIN telegram_users resolver ON resolver.user_id = f.resolved_by_user_id
+		WHERE f.chat_id = $1
+		ORDER BY f.created_at DESC, f.id DESC
+		LIMIT $2
+	`, chatId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query karma abuse flags: %w", err)
+	}
+
+	flags, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (karmaFlag, error) {
+		var flag karmaFlag
+		err := row.Scan(&flag.ID, &flag.GiverName, &flag.ReceiverName, &flag.Value, &flag.AppliedValue,
+			&flag.Rule, &flag.Status, &flag.ResolverName, &flag.CreatedAt)
+		return flag, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read karma abuse flags: %w", err)
+	}
+
+	return flags, nil
+}
+
+// ShowKarmaFlags lists the chat's latest flagged karma for admins.
+func ShowKarmaFlags(c *CommandContext) error {
+	flags, err := getKarmaFlags(c.Conn, c.ChatID(), karmaFlagsLimit)
+	if err != nil {
+		return err
+	}
+	if len(flags) == 0 {
+		return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "abuse.none", nil))
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.T(c.Lang, "abuse.header", nil))
+	b.WriteString("\n\n")
+	for _, flag := range flags {
+		b.WriteString(flag.CreatedAt.UTC().Format("02-01-2006 15:04"))
+		b.WriteString(" ")
+		b.WriteString(formatKarmaFlag(c.Lang, flag))
+		b.WriteString("\n")
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), b.String())
+}
+
+// ApproveKarmaFlag counts karma that was held for approval.
+func ApproveKarmaFlag(c *CommandContext) error {
+	flagId := c.IntArg("id")
+	ctx := context.Background()
+	tx, err := c.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin karma approval: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var grant karmaGrant
+	var giverId, receiverId int64
+	err = tx.QueryRow(ctx, `
+		SELECT giver_user_id, receiver_user_id, karma_value, reason, COALESCE(message_id, 0), COALESCE(update_id, 0), message_thread_id
+		FROM karma_abuse_flags
+		WHERE id = $1 AND chat_id = $2 AND status = 'pending'
+		FOR UPDATE
+	`, flagId, c.ChatID()).Scan(&giverId, &receiverId, &grant.Value, &grant.Reason, &grant.MessageID, &grant.UpdateID, &grant.ThreadID)
+	if err == pgx.ErrNoRows {
+		return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "abuse.not_pending", i18n.Args{"id": flagId}))
+	}
+	if err != nil {
+		return fmt.Errorf("query karma abuse flag %d: %w", flagId, err)
+	}
+
+	grant.ChatID = c.ChatID()
+	grant.Scale = 1
+	if grant.Giver, err = getTelegramUser(tx, giverId); err != nil {
+		return err
+	}
+	if grant.Receiver, err = getTelegramUser(tx, receiverId); err != nil {
+		return err
+	}
+
+	applied, err := applyKarma(tx, grant)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE karma_abuse_flags
+		SET status = 'approved', applied_value = $3, resolved_by_user_id = $2, resolved_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, flagId, c.Message.From.ID, applied.WeightedValue)
+	if err != nil {
+		return fmt.Errorf("approve karma abuse flag %d: %w", flagId, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit karma approval: %w", err)
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "abuse.approved", i18n.Args{
+		"id":    flagId,
+		"name":  telegramUserDisplayName(grant.Receiver),
+		"total": applied.Total,
+	}))
+}
+
+// RejectKarmaFlag drops karma that was held for approval.
+func RejectKarmaFlag(c *CommandContext) error {
+	flagId := c.IntArg("id")
+	tag, err := c.Conn.Exec(context.Background(), `
+		UPDATE karma_abuse_flags
+		SET status = 'rejected', resolved_by_user_id = $3, resolved_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND chat_id = $2 AND status = 'pending'
+	`, flagId, c.ChatID(), c.Message.From.ID)
+	if err != nil {
+		return fmt.Errorf("reject karma abuse flag %d: %w", flagId, err)
+	}
+
+	key := "abuse.rejected"
+	if tag.RowsAffected() == 0 {
+		key = "abuse.not_pending"
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, key, i18n.Args{"id": flagId}))
+}
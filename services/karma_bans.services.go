@@ -0,0 +1,310 @@
+package services
+
+import (
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Actions recorded in karma_restriction_log.
+const (
+	karmaRestrictionBan         = "ban"
+	karmaRestrictionMuteReceive = "mute_receive"
+	karmaRestrictionUnban       = "unban"
+)
+
+const (
+	// maxKarmaRestriction is the longest restriction with an end date; for
+	// longer ones admins leave the duration out.
+	maxKarmaRestriction = 365 * 24 * time.Hour
+	// karmaRestrictionLogLimit is how many changes /karma_bans log shows.
+	karmaRestrictionLogLimit = 10
+	karmaBansViewLog         = "log"
+)
+
+// restrictionArgs takes an optional @user and an optional duration; a text
+// mention can hold spaces, so it reads the rest of the text.
+var restrictionArgs = []CommandArg{{
+	Name:        "args",
+	Placeholder: "arg.user_duration",
+	Kind:        commandArgText,
+	Optional:    true,
+	Rest:        true,
+}}
+
+// restrictionUpdates sets the users_ranking columns of each action. $6 is the
+// end date, NULL for none.
+var restrictionUpdates = map[string]string{
+	karmaRestrictionBan: `
+		INSERT INTO users_ranking (user_id, group_id, first_name, last_name, username, allowed_to_give_karma, give_karma_blocked_until)
+		VALUES ($1, $2, $3, $4, $5, FALSE, $6)
+		ON CONFLICT (user_id, group_id)
+		DO UPDATE SET
+			allowed_to_give_karma = FALSE,
+			give_karma_blocked_until = EXCLUDED.give_karma_blocked_until
+	`,
+	karmaRestrictionMuteReceive: `
+		INSERT INTO users_ranking (user_id, group_id, first_name, last_name, username, allowed_to_receive_karma, receive_karma_blocked_until)
+		VALUES ($1, $2, $3, $4, $5, FALSE, $6)
+		ON CONFLICT (user_id, group_id)
+		DO UPDATE SET
+			allowed_to_receive_karma = FALSE,
+			receive_karma_blocked_until = EXCLUDED.receive_karma_blocked_until
+	`,
+	karmaRestrictionUnban: `
+		INSERT INTO users_ranking (user_id, group_id, first_name, last_name, username)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, group_id)
+		DO UPDATE SET
+			allowed_to_give_karma = TRUE,
+			allowed_to_receive_karma = TRUE,
+			give_karma_blocked_until = $6,
+			receive_karma_blocked_until = $6
+	`,
+}
+
+func KarmaBanFromCommand(c *CommandContext) error {
+	return restrictKarmaFromCommand(c, karmaRestrictionBan)
+}
+
+func KarmaMuteReceiveFromCommand(c *CommandContext) error {
+	return restrictKarmaFromCommand(c, karmaRestrictionMuteReceive)
+}
+
+func KarmaUnbanFromCommand(c *CommandContext) error {
+	return restrictKarmaFromCommand(c, karmaRestrictionUnban)
+}
+
+// restrictKarmaFromCommand applies a restriction action to the user the
+// command mentions or replies to, and logs who did it.
+func restrictKarmaFromCommand(c *CommandContext, action string) error {
+	duration, ok := restrictionDuration(c)
+	if !ok || (action == karmaRestrictionUnban && duration != 0) {
+		return SendMessageWithReply(c.ChatID(), c.ThreadID(), c.Message.MessageID, c.Spec.usageHelp(c.Lang))
+	}
+
+	user, err := commandTarget(c, false)
+	if err != nil || user == nil {
+		return err
+	}
+
+	var expiresAt *time.Time
+	if duration > 0 {
+		until := time.Now().UTC().Add(duration)
+		expiresAt = &until
+	}
+
+	ctx := context.Background()
+	tx, err := c.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin karma restriction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, restrictionUpdates[action], user.ID, c.ChatID(), user.FirstName, user.LastName, user.Username, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s user %d: %w", action, user.ID, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO karma_restriction_log (chat_id, user_id, admin_user_id, action, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, c.ChatID(), user.ID, c.Message.From.ID, action, expiresAt)
+	if err != nil {
+		return fmt.Errorf("log karma restriction: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit karma restriction: %w", err)
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "bans."+action, i18n.Args{
+		"name":  telegramUserDisplayName(user),
+		"until": restrictionUntil(c.Lang, expiresAt),
+	}))
+}
+
+// restrictionDuration reads the duration left after taking the mentions out
+// of the arguments.
+func restrictionDuration(c *CommandContext) (time.Duration, bool) {
+	text := c.Arg("args")
+	for _, mention := range c.Mentions {
+		text = strings.Replace(text, mention.Text, "", 1)
+	}
+
+	return ParseRestrictionDuration(text)
+}
+
+// ParseRestrictionDuration reads an optional duration of up to
+// maxKarmaRestriction. No duration gives 0; anything else, including a longer
+// duration, is not ok.
+func ParseRestrictionDuration(text string) (time.Duration, bool) {
+	fields := strings.Fields(text)
+	switch len(fields) {
+	case 0:
+		return 0, true
+	case 1:
+		duration, err := shared.ParseDuration(fields[0])
+		if err != nil || duration > maxKarmaRestriction {
+			return 0, false
+		}
+		return duration, true
+	}
+
+	return 0, false
+}
+
+func restrictionUntil(lang string, expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return i18n.T(lang, "bans.no_end", nil)
+	}
+
+	return i18n.T(lang, "bans.until", i18n.Args{"date": expiresAt.UTC().Format("02-01-2006 15:04")})
+}
+
+type karmaRestriction struct {
+	Name         string
+	GiveBlocked  bool
+	GiveUntil    *time.Time
+	ReceiveBlock bool
+	ReceiveUntil *time.Time
+}
+
+// getKarmaRestrictions returns the chat's users with a restriction in force.
+func getKarmaRestrictions(conn shared.DBTX, chatId int64) ([]karmaRestriction, error) {
+	rows, err := conn.Query(context.Background(), `
+		SELECT name, give_blocked, give_karma_blocked_until, receive_blocked, receive_karma_blocked_until
+		FROM (
+			SELECT
+				TRIM(CONCAT(first_name, ' ', COALESCE(last_name, ''))) AS name,
+				NOT COALESCE(allowed_to_give_karma, TRUE)
+					AND COALESCE(give_karma_blocked_until > CURRENT_TIMESTAMP, TRUE) AS give_blocked,
+				give_karma_blocked_until,
+				NOT COALESCE(allowed_to_receive_karma, TRUE)
+					AND COALESCE(receive_karma_blocked_until > CURRENT_TIMESTAMP, TRUE) AS receive_blocked,
+				receive_karma_blocked_until
+			FROM users_ranking
+			WHERE group_id = $1
+		) restrictions
+		WHERE give_blocked OR receive_blocked
+		ORDER BY name ASC
+	`, chatId)
+	if err != nil {
+		return nil, fmt.Errorf("query karma restrictions: %w", err)
+	}
+
+	restrictions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (karmaRestriction, error) {
+		var r karmaRestriction
+		err := row.Scan(&r.Name, &r.GiveBlocked, &r.GiveUntil, &r.ReceiveBlock, &r.ReceiveUntil)
+		return r, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read karma restrictions: %w", err)
+	}
+
+	return restrictions, nil
+}
+
+type karmaRestrictionChange struct {
+	Action    string
+	Name      string
+	AdminName string
+	ExpiresAt *time.Time
+	CreatedAt time.Time
+}
+
+// getKarmaRestrictionLog returns the chat's latest restriction changes,
+// newest first. Names are "" for users we never saw.
+func getKarmaRestrictionLog(conn shared.DBTX, chatId int64, limit int) ([]karmaRestrictionChange, error) {
+	rows, err := conn.Query(context.Background(), `
+		SELECT
+			l.action,
+			TRIM(CONCAT(target.first_name, ' ', COALESCE(target.last_name, ''))),
+			TRIM(CONCAT(admin.first_name, ' ', COALESCE(admin.last_name, ''))),
+			l.expires_at,
+			l.created_at
+		FROM karma_restriction_log l
+		LEFT JOIN telegram_users target ON target.user_id = l.user_id
+		LEFT JOIN telegram_users admin ON admin.user_id = l.admin_user_id
+		WHERE l.chat_id = $1
+		ORDER BY l.created_at DESC, l.id DESC
+		LIMIT $2
+	`, chatId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query karma restriction log: %w", err)
+	}
+
+	changes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (karmaRestrictionChange, error) {
+		var change karmaRestrictionChange
+		err := row.Scan(&change.Action, &change.Name, &change.AdminName, &change.ExpiresAt, &change.CreatedAt)
+		return change, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read karma restriction log: %w", err)
+	}
+
+	return changes, nil
+}
+
+// ShowKarmaBans lists the restrictions in force, or with "log" the latest
+// changes and who made them.
+func ShowKarmaBans(c *CommandContext) error {
+	if c.Arg("view") == karmaBansViewLog {
+		return showKarmaRestrictionLog(c)
+	}
+
+	restrictions, err := getKarmaRestrictions(c.Conn, c.ChatID())
+	if err != nil {
+		return err
+	}
+	if len(restrictions) == 0 {
+		return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "bans.none", nil))
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.N(c.Lang, "bans.header", len(restrictions), nil))
+	b.WriteString("\n\n")
+	for _, r := range restrictions {
+		var parts []string
+		if r.GiveBlocked {
+			parts = append(parts, i18n.T(c.Lang, "bans.no_give", i18n.Args{"until": restrictionUntil(c.Lang, r.GiveUntil)}))
+		}
+		if r.ReceiveBlock {
+			parts = append(parts, i18n.T(c.Lang, "bans.no_receive", i18n.Args{"until": restrictionUntil(c.Lang, r.ReceiveUntil)}))
+		}
+		b.WriteString(fmt.Sprintf("%s: %s\n", historyName(c.Lang, r.Name), strings.Join(parts, "; ")))
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), b.String())
+}
+
+func showKarmaRestrictionLog(c *CommandContext) error {
+	changes, err := getKarmaRestrictionLog(c.Conn, c.ChatID(), karmaRestrictionLogLimit)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "bans.log_none", nil))
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.T(c.Lang, "bans.log_header", nil))
+	b.WriteString("\n\n")
+	for _, change := range changes {
+		b.WriteString(change.CreatedAt.UTC().Format("02-01-2006 15:04"))
+		b.WriteString(" ")
+		b.WriteString(i18n.T(c.Lang, "bans.log_"+change.Action, i18n.Args{
+			"admin": historyName(c.Lang, change.AdminName),
+			"name":  historyName(c.Lang, change.Name),
+			"until": restrictionUntil(c.Lang, change.ExpiresAt),
+		}))
+		b.WriteString("\n")
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), b.String())
+}
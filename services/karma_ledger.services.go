@@ -0,0 +1,357 @@
+package services
+
+import (
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Kinds of karma_events rows. An undo row reverts one karma row, with the
+// opposite value, so the ledger is never rewritten.
+const (
+	karmaEventKarma = "karma"
+	karmaEventUndo  = "undo"
+)
+
+const (
+	// karmaUndoWindow is how long the giver has to take karma back.
+	karmaUndoWindow = 5 * time.Minute
+	// karmaHistoryLimit is how many events /karma_history shows.
+	karmaHistoryLimit = 10
+)
+
+type karmaHistoryEntry struct {
+	Kind         string
+	Value        int
+	GiverName    string
+	ReceiverName string
+	CreatedAt    time.Time
+}
+
+// getKarmaHistory returns the latest karma a user gave or received in a chat,
+// newest first. Names come from users_ranking and are "" when unknown.
+func getKarmaHistory(conn shared.DBTX, chatId int64, userId int64, limit int) ([]karmaHistoryEntry, error) {
+	rows, err := conn.Query(context.Background(), `
+		SELECT
+			e.kind,
+			e.karma_value,
+			TRIM(CONCAT(giver.first_name, ' ', COALESCE(giver.last_name, ''))),
+			TRIM(CONCAT(receiver.first_name, ' ', COALESCE(receiver.last_name, ''))),
+			e.created_at
+		FROM karma_events e
+		LEFT JOIN users_ranking giver ON giver.group_id = e.chat_id AND giver.user_id = e.giver_user_id
+		LEFT JOIN users_ranking receiver ON receiver.group_id = e.chat_id AND receiver.user_id = e.receiver_user_id
+		WHERE e.chat_id = $1
+			AND (e.giver_user_id = $2 OR e.receiver_user_id = $2)
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT $3
+	`, chatId, userId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query karma history: %w", err)
+	}
+
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (karmaHistoryEntry, error) {
+		var entry karmaHistoryEntry
+		err := row.Scan(&entry.Kind, &entry.Value, &entry.GiverName, &entry.ReceiverName, &entry.CreatedAt)
+		return entry, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read karma history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// countKarmaGivenSince counts the karma a user gave in a chat since a time,
+// leaving out what they took back.
+func countKarmaGivenSince(conn shared.DBTX, chatId int64, giverId int64, since time.Time) (int, error) {
+	var count int
+	err := conn.QueryRow(context.Background(), `
+		SELECT COUNT(*)
+		FROM karma_events e
+		WHERE e.chat_id = $1
+			AND e.giver_user_id = $2
+			AND e.kind = 'karma'
+			AND e.created_at > $3
+			AND NOT EXISTS (SELECT 1 FROM karma_events undo WHERE undo.reverts_event_id = e.id)
+	`, chatId, giverId, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count karma given by %d: %w", giverId, err)
+	}
+
+	return count, nil
+}
+
+// gaveKarmaToSince tells whether a user gave karma to a receiver in a chat
+// since a time, leaving out what they took back.
+func gaveKarmaToSince(conn shared.DBTX, chatId int64, giverId int64, receiverId int64, since time.Time) (bool, error) {
+	var gave bool
+	err := conn.QueryRow(context.Background(), `
+		SELECT EXISTS (
+			SELECT 1
+			FROM karma_events e
+			WHERE e.chat_id = $1
+				AND e.giver_user_id = $2
+				AND e.receiver_user_id = $3
+				AND e.kind = 'karma'
+				AND e.created_at > $4
+				AND NOT EXISTS (SELECT 1 FROM karma_events undo WHERE undo.reverts_event_id = e.id)
+		)
+	`, chatId, giverId, receiverId, since).Scan(&gave)
+	if err != nil {
+		return false, fmt.Errorf("query karma given by %d to %d: %w", giverId, receiverId, err)
+	}
+
+	return gave, nil
+}
+
+// ShowKarmaHistory lists the latest karma given and received by the command's
+// target, or by the sender when there is none.
+func ShowKarmaHistory(c *CommandContext) error {
+	user, err := commandTarget(c, true)
+	if err != nil || user == nil {
+		return err
+	}
+
+	entries, err := getKarmaHistory(c.Conn, c.ChatID(), user.ID, karmaHistoryLimit)
+	if err != nil {
+		return err
+	}
+
+	name := telegramUserDisplayName(user)
+	if len(entries) == 0 {
+		return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "history.none", i18n.Args{"name": name}))
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.T(c.Lang, "history.header", i18n.Args{"name": name}))
+	b.WriteString("\n\n")
+	for _, entry := range entries {
+		b.WriteString(fmt.Sprintf(
+			"%s %s → %s: %+d",
+			entry.CreatedAt.UTC().Format("02-01-2006 15:04"),
+			historyName(c.Lang, entry.GiverName),
+			historyName(c.Lang, entry.ReceiverName),
+			entry.Value,
+		))
+		if entry.Kind == karmaEventUndo {
+			b.WriteString(" " + i18n.T(c.Lang, "history.undo", nil))
+		}
+		b.WriteString("\n")
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), b.String())
+}
+
+func historyName(lang string, name string) string {
+	if name == "" {
+		return i18n.T(lang, "leaderboard.unknown_user", nil)
+	}
+
+	return name
+}
+
+type revertibleKarmaEvent struct {
+	ID            int64
+	ReceiverID    int64
+	Value         int
+	WeightedValue float64
+	ThreadID      int
+}
+
+// UndoKarmaFromCommand takes back the karma the sender gave with their latest
+// karma message, if it was less than karmaUndoWindow ago. Every target of
+// that message loses what it got; the ledger gets an undo row for each.
+func UndoKarmaFromCommand(c *CommandContext) error {
+	ctx := context.Background()
+	chatId := c.ChatID()
+	giverId := c.Message.From.ID
+
+	tx, err := c.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin karma undo: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT e.id, e.receiver_user_id, e.karma_value, e.weighted_value, e.message_thread_id
+		FROM karma_events e
+		WHERE e.chat_id = $1
+			AND e.giver_user_id = $2
+			AND e.kind = 'karma'
+			AND e.message_id = (
+				SELECT latest.message_id
+				FROM karma_events latest
+				WHERE latest.chat_id = $1
+					AND latest.giver_user_id = $2
+					AND latest.kind = 'karma'
+					AND latest.created_at > $3
+					AND NOT EXISTS (SELECT 1 FROM karma_events undo WHERE undo.reverts_event_id = latest.id)
+				ORDER BY latest.created_at DESC, latest.id DESC
+				LIMIT 1
+			)
+			AND NOT EXISTS (SELECT 1 FROM karma_events undo WHERE undo.reverts_event_id = e.id)
+		ORDER BY e.id
+		FOR UPDATE OF e
+	`, chatId, giverId, time.Now().UTC().Add(-karmaUndoWindow))
+	if err != nil {
+		return fmt.Errorf("query karma to undo: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (revertibleKarmaEvent, error) {
+		var event revertibleKarmaEvent
+		err := row.Scan(&event.ID, &event.ReceiverID, &event.Value, &event.WeightedValue, &event.ThreadID)
+		return event, err
+	})
+	if err != nil {
+		return fmt.Errorf("read karma to undo: %w", err)
+	}
+
+	if len(events) == 0 {
+		return SendMessageWithReply(chatId, c.ThreadID(), c.Message.MessageID, i18n.T(c.Lang, "undo.nothing", i18n.Args{
+			"minutes": int(karmaUndoWindow.Minutes()),
+		}))
+	}
+
+	lines := make([]string, 0, len(events))
+	for _, event := range events {
+		name, total, err := revertKarmaEvent(tx, c, event)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, i18n.T(c.Lang, "undo.done", i18n.Args{
+			"name":  historyName(c.Lang, name),
+			"value": fmt.Sprintf("%+d", event.Value),
+			"total": total,
+		}))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit karma undo: %w", err)
+	}
+
+	return SendMessageWithReply(chatId, c.ThreadID(), c.Message.MessageID, strings.Join(lines, "\n"))
+}
+
+// revertKarmaEvent applies the opposite of one karma event to the counters
+// and records the undo. It returns the receiver's name and new total.
+func revertKarmaEvent(tx pgx.Tx, c *CommandContext, event revertibleKarmaEvent) (string, int, error) {
+	ctx := context.Background()
+	chatId := c.ChatID()
+	giverId := c.Message.From.ID
+
+	var name string
+	var total int
+	err := tx.QueryRow(ctx, `
+		UPDATE users_ranking
+		SET karma = karma - $3, weighted_karma = weighted_karma - $4
+		WHERE group_id = $1 AND user_id = $2
+		RETURNING TRIM(CONCAT(first_name, ' ', COALESCE(last_name, ''))), karma
+	`, chatId, event.ReceiverID, event.Value, event.WeightedValue).Scan(&name, &total)
+	if err != nil {
+		return "", 0, fmt.Errorf("undo karma of user %d: %w", event.ReceiverID, err)
+	}
+
+	if event.ThreadID != 0 {
+		if err := UpsertTopicKarma(tx, chatId, event.ThreadID, event.ReceiverID, -event.Value); err != nil {
+			return "", 0, fmt.Errorf("undo topic karma of user %d: %w", event.ReceiverID, err)
+		}
+	}
+
+	givenIncrement, takenIncrement := karmaGivenTakenIncrements(event.Value)
+	_, err = tx.Exec(ctx, `
+		UPDATE users_ranking
+		SET karma_given = karma_given - $3, karma_taken = karma_taken - $4
+		WHERE group_id = $1 AND user_id = $2
+	`, chatId, giverId, givenIncrement, takenIncrement)
+	if err != nil {
+		return "", 0, fmt.Errorf("undo karma_given/taken of user %d: %w", giverId, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO karma_events (
+			chat_id, giver_user_id, receiver_user_id, karma_value, weighted_value, kind, reason,
+			message_id, update_id, message_thread_id, reverts_event_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, chatId, giverId, event.ReceiverID, -event.Value, -event.WeightedValue, karmaEventUndo, "/"+c.Spec.Name,
+		c.Message.MessageID, c.Update.UpdateID, event.ThreadID, event.ID)
+	if err != nil {
+		return "", 0, fmt.Errorf("record karma undo of event %d: %w", event.ID, err)
+	}
+
+	return name, total, nil
+}
+
+// RebuildKarmaFromLedger recomputes karma, weighted_karma, karma_given and
+// karma_taken in users_ranking for a chat as the opening balances plus every karma event
+// since. It returns how many users' totals changed.
+func RebuildKarmaFromLedger(conn shared.DBTX, chatId int64) (int64, error) {
+	tag, err := conn.Exec(context.Background(), `
+		WITH parts AS (
+			SELECT user_id, karma, weighted_karma AS weighted, karma_given AS given, karma_taken AS taken
+			FROM karma_opening_balances
+			WHERE chat_id = $1
+			UNION ALL
+			SELECT receiver_user_id, karma_value, weighted_value, 0, 0
+			FROM karma_events
+			WHERE chat_id = $1
+			UNION ALL
+			-- An undo row has the opposite sign of the karma it reverts.
+			SELECT
+				giver_user_id,
+				0,
+				0,
+				CASE
+					WHEN kind = 'karma' AND karma_value > 0 THEN 1
+					WHEN kind = 'undo' AND karma_value < 0 THEN -1
+					ELSE 0
+				END,
+				CASE
+					WHEN kind = 'karma' AND karma_value < 0 THEN 1
+					WHEN kind = 'undo' AND karma_value > 0 THEN -1
+					ELSE 0
+				END
+			FROM karma_events
+			WHERE chat_id = $1
+		),
+		totals AS (
+			SELECT
+				ur.id,
+				COALESCE(SUM(p.karma), 0) AS karma,
+				COALESCE(SUM(p.weighted), 0) AS weighted,
+				COALESCE(SUM(p.given), 0) AS given,
+				COALESCE(SUM(p.taken), 0) AS taken
+			FROM users_ranking ur
+			LEFT JOIN parts p ON p.user_id = ur.user_id
+			WHERE ur.group_id = $1
+			GROUP BY ur.id
+		)
+		UPDATE users_ranking ur
+		SET karma = totals.karma, weighted_karma = totals.weighted, karma_given = totals.given, karma_taken = totals.taken
+		FROM totals
+		WHERE ur.id = totals.id
+			AND (ur.karma, ur.weighted_karma, ur.karma_given, ur.karma_taken)
+				IS DISTINCT FROM (totals.karma::int, totals.weighted, totals.given::int, totals.taken::int)
+	`, chatId)
+	if err != nil {
+		return 0, fmt.Errorf("rebuild karma from ledger: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// RebuildKarmaFromCommand lets admins repair a chat's counters from the
+// ledger.
+func RebuildKarmaFromCommand(c *CommandContext) error {
+	changed, err := RebuildKarmaFromLedger(c.Conn, c.ChatID())
+	if err != nil {
+		return err
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.N(c.Lang, "rebuild.done", int(changed), nil))
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"bot/telegram/i18n"
+	"fmt"
+	"strings"
+)
+
+// karmaProfileGivers is how many top givers /karma lists.
+const karmaProfileGivers = 3
+
+// ShowKarmaProfile answers /karma with the standing of the user the command
+// is about: the mentioned user, the author of the replied-to message, or the
+// sender.
+func ShowKarmaProfile(c *CommandContext) error {
+	user, err := commandTarget(c, true)
+	if err != nil || user == nil {
+		return err
+	}
+
+	name := telegramUserDisplayName(user)
+	profile, err := GetUserKarmaProfile(c.Conn, c.ChatID(), user.ID)
+	if err != nil {
+		return fmt.Errorf("query karma profile of user %d: %w", user.ID, err)
+	}
+	if profile == nil {
+		return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "profile.none", i18n.Args{"name": name}))
+	}
+
+	givers, err := GetTopKarmaGivers(c.Conn, c.ChatID(), user.ID, karmaProfileGivers)
+	if err != nil {
+		return fmt.Errorf("query top karma givers of user %d: %w", user.ID, err)
+	}
+
+	lines := []string{
+		i18n.T(c.Lang, "profile.header", i18n.Args{"name": name, "karma": profile.Karma}),
+	}
+
+	rankArgs := i18n.Args{"rank": profile.Rank, "users": profile.Users}
+	if profile.Tied > 0 {
+		lines = append(lines, i18n.N(c.Lang, "profile.rank_tied", profile.Tied, rankArgs))
+	} else {
+		lines = append(lines, i18n.T(c.Lang, "profile.rank", rankArgs))
+	}
+
+	lines = append(lines, i18n.T(c.Lang, "profile.given_taken", i18n.Args{"given": profile.KarmaGiven, "taken": profile.KarmaTaken}))
+
+	if len(givers) > 0 {
+		names := make([]string, len(givers))
+		for i, giver := range givers {
+			names[i] = fmt.Sprintf("%s (%+d)", historyName(c.Lang, strings.TrimSpace(giver.Name)), giver.Karma)
+		}
+		lines = append(lines, i18n.T(c.Lang, "profile.top_givers", i18n.Args{"givers": strings.Join(names, ", ")}))
+	}
+
+	if profile.LastKarma != nil {
+		lines = append(lines, i18n.T(c.Lang, "profile.last_karma", i18n.Args{"date": profile.LastKarma.UTC().Format("02-01-2006")}))
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,502 @@
+package services
+
+import (
+	"bot/telegram/errors"
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Ways a chat's karma seasons end, stored in chat_settings.season_mode.
+const (
+	karmaSeasonManual    = "manual"
+	karmaSeasonMonthly   = "monthly"
+	karmaSeasonQuarterly = "quarterly"
+)
+
+var karmaSeasonModes = []string{karmaSeasonManual, karmaSeasonMonthly, karmaSeasonQuarterly}
+
+// karmaSeasonMonths is how long scheduled seasons last. Manual seasons have
+// no entry.
+var karmaSeasonMonths = map[string]int{
+	karmaSeasonMonthly:   1,
+	karmaSeasonQuarterly: 3,
+}
+
+const (
+	karmaSeasonActionEnd = "end"
+	// karmaSeasonWinners is how many places the end of a season announces.
+	karmaSeasonWinners        = 3
+	karmaSeasonHistoryDefault = 5
+	karmaSeasonHistoryMax     = 20
+)
+
+type karmaSeason struct {
+	ID        int64
+	ChatID    int64
+	Number    int
+	StartedAt time.Time
+	EndedAt   *time.Time
+}
+
+type karmaSeasonStanding struct {
+	Name  string
+	Karma int
+	Rank  int
+}
+
+// seasonEndsAt returns when a season that started at startedAt ends under
+// mode. Manual seasons have no set end.
+func seasonEndsAt(mode string, startedAt time.Time) (time.Time, bool) {
+	months, ok := karmaSeasonMonths[mode]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return shared.NextPeriodStart(startedAt, months), true
+}
+
+// currentKarmaSeason returns the chat's open season, starting the chat's next
+// one when none is open.
+func currentKarmaSeason(conn shared.DBTX, chatId int64) (karmaSeason, error) {
+	ctx := context.Background()
+	_, err := conn.Exec(ctx, `
+		INSERT INTO karma_seasons (chat_id, number)
+		SELECT $1, COALESCE(MAX(number), 0) + 1
+		FROM karma_seasons
+		WHERE chat_id = $1
+		HAVING COUNT(*) FILTER (WHERE ended_at IS NULL) = 0
+		ON CONFLICT DO NOTHING
+	`, chatId)
+	if err != nil {
+		return karmaSeason{}, fmt.Errorf("start karma season: %w", err)
+	}
+
+	season := karmaSeason{ChatID: chatId}
+	err = conn.QueryRow(ctx, `
+		SELECT id, number, started_at
+		FROM karma_seasons
+		WHERE chat_id = $1 AND ended_at IS NULL
+	`, chatId).Scan(&season.ID, &season.Number, &season.StartedAt)
+	if err != nil {
+		return karmaSeason{}, fmt.Errorf("query current karma season: %w", err)
+	}
+
+	return season, nil
+}
+
+// endKarmaSeason archives the season's final leaderboard, keeps carryPercent
+// of everyone's raw and weighted karma and opens the next season at nextStartedAt. The opening
+// balances take the karma removed, so /rebuild_karma lands on the same totals.
+// It returns the winners, and false when the season was already ended.
+func endKarmaSeason(ctx context.Context, conn shared.DBTX, seasonId int64, endedAt time.Time, nextStartedAt time.Time, carryPercent int) ([]karmaSeasonStanding, bool, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("begin karma season end: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var chatId int64
+	var number int
+	err = tx.QueryRow(ctx, `
+		UPDATE karma_seasons
+		SET ended_at = $2
+		WHERE id = $1 AND ended_at IS NULL
+		RETURNING chat_id, number
+	`, seasonId, endedAt).Scan(&chatId, &number)
+	if err == pgx.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("close karma season %d: %w", seasonId, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO karma_season_standings (season_id, user_id, name, karma, rank)
+		SELECT $1, user_id, TRIM(CONCAT(first_name, ' ', COALESCE(last_name, ''))), karma, RANK() OVER (ORDER BY karma DESC)
+		FROM users_ranking
+		WHERE group_id = $2 AND COALESCE(karma, 0) <> 0
+	`, seasonId, chatId)
+	if err != nil {
+		return nil, false, fmt.Errorf("archive karma season %d: %w", seasonId, err)
+	}
+
+	if err := carryKarmaIntoNextSeason(ctx, tx, chatId, carryPercent); err != nil {
+		return nil, false, fmt.Errorf("carry karma out of season %d: %w", seasonId, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO karma_seasons (chat_id, number, started_at)
+		VALUES ($1, $2, $3)
+	`, chatId, number+1, nextStartedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("start karma season %d: %w", number+1, err)
+	}
+
+	winners, err := getKarmaSeasonWinners(ctx, tx, seasonId, karmaSeasonWinners)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("commit karma season end: %w", err)
+	}
+
+	return winners, true, nil
+}
+
+// carryKarmaIntoNextSeason cuts everyone's karma in the chat down to what
+// shared.CarryKarma keeps and moves the difference into their opening
+// balances.
+func carryKarmaIntoNextSeason(ctx context.Context, tx pgx.Tx, chatId int64, carryPercent int) error {
+	rows, err := tx.Query(ctx, `
+		SELECT user_id, COALESCE(karma, 0), COALESCE(weighted_karma, 0)::float8
+		FROM users_ranking
+		WHERE group_id = $1
+	`, chatId)
+	if err != nil {
+		return fmt.Errorf("query karma to carry: %w", err)
+	}
+
+	var userIds []int64
+	var karmaDeltas []int
+	var weightedDeltas []float64
+	for rows.Next() {
+		var userId int64
+		var karma int
+		var weighted float64
+		if err := rows.Scan(&userId, &karma, &weighted); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan karma to carry: %w", err)
+		}
+		carried, carriedWeighted := shared.CarryKarma(karma, weighted, carryPercent)
+		if carried == karma && carriedWeighted == weighted {
+			continue
+		}
+		userIds = append(userIds, userId)
+		karmaDeltas = append(karmaDeltas, carried-karma)
+		weightedDeltas = append(weightedDeltas, carriedWeighted-weighted)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read karma to carry: %w", err)
+	}
+	if len(userIds) == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO karma_opening_balances (chat_id, user_id, karma, weighted_karma)
+		SELECT $1, c.user_id, c.karma, ROUND(c.weighted_karma, 2)
+		FROM unnest($2::bigint[], $3::int[], $4::numeric[]) AS c(user_id, karma, weighted_karma)
+		ON CONFLICT (chat_id, user_id)
+		DO UPDATE SET
+			karma = karma_opening_balances.karma + EXCLUDED.karma,
+			weighted_karma = karma_opening_balances.weighted_karma + EXCLUDED.weighted_karma
+	`, chatId, userIds, karmaDeltas, weightedDeltas)
+	if err != nil {
+		return fmt.Errorf("adjust opening balances: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users_ranking u
+		SET karma = COALESCE(u.karma, 0) + c.karma, weighted_karma = COALESCE(u.weighted_karma, 0) + ROUND(c.weighted_karma, 2)
+		FROM unnest($2::bigint[], $3::int[], $4::numeric[]) AS c(user_id, karma, weighted_karma)
+		WHERE u.group_id = $1 AND u.user_id = c.user_id
+	`, chatId, userIds, karmaDeltas, weightedDeltas)
+	if err != nil {
+		return fmt.Errorf("cut karma: %w", err)
+	}
+
+	return nil
+}
+
+// getKarmaSeasonWinners returns the archived users placed up to maxRank with
+// positive karma, best first.
+func getKarmaSeasonWinners(ctx context.Context, conn shared.DBTX, seasonId int64, maxRank int) ([]karmaSeasonStanding, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT name, karma, rank
+		FROM karma_season_standings
+		WHERE season_id = $1 AND rank <= $2 AND karma > 0
+		ORDER BY rank ASC, name ASC
+	`, seasonId, maxRank)
+	if err != nil {
+		return nil, fmt.Errorf("query karma season winners: %w", err)
+	}
+
+	winners, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (karmaSeasonStanding, error) {
+		var standing karmaSeasonStanding
+		err := row.Scan(&standing.Name, &standing.Karma, &standing.Rank)
+		return standing, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read karma season winners: %w", err)
+	}
+
+	return winners, nil
+}
+
+// ProcessDueKarmaSeasons ends every scheduled season whose month or quarter is
+// over and announces the winners. Chats switched to a schedule without an open
+// season get one first. A failure in one chat doesn't stop the others.
+func ProcessDueKarmaSeasons(ctx context.Context, conn shared.DBTX) error {
+	_, err := conn.Exec(ctx, `
+		INSERT INTO karma_seasons (chat_id, number)
+		SELECT cs.chat_id, COALESCE((SELECT MAX(s.number) FROM karma_seasons s WHERE s.chat_id = cs.chat_id), 0) + 1
+		FROM chat_settings cs
+		WHERE cs.season_mode <> 'manual'
+			AND NOT EXISTS (
+				SELECT 1
+				FROM karma_seasons s
+				WHERE s.chat_id = cs.chat_id AND s.ended_at IS NULL
+			)
+		ON CONFLICT DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("start scheduled karma seasons: %w", err)
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT s.id, s.chat_id, s.number, s.started_at, cs.season_mode, cs.season_carry_percent
+		FROM karma_seasons s
+		JOIN chat_settings cs ON cs.chat_id = s.chat_id
+		WHERE s.ended_at IS NULL AND cs.season_mode <> 'manual'
+	`)
+	if err != nil {
+		return fmt.Errorf("query scheduled karma seasons: %w", err)
+	}
+
+	type scheduledSeason struct {
+		karmaSeason
+		Mode         string
+		CarryPercent int
+	}
+	seasons, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (scheduledSeason, error) {
+		var s scheduledSeason
+		err := row.Scan(&s.ID, &s.ChatID, &s.Number, &s.StartedAt, &s.Mode, &s.CarryPercent)
+		return s, err
+	})
+	if err != nil {
+		return fmt.Errorf("read scheduled karma seasons: %w", err)
+	}
+
+	now := time.Now().UTC()
+	var failures []error
+	for _, season := range seasons {
+		endsAt, _ := seasonEndsAt(season.Mode, season.StartedAt)
+		if endsAt.After(now) {
+			continue
+		}
+
+		// After downtime the next season still lines up with the calendar.
+		nextStartedAt := shared.PeriodStart(now, karmaSeasonMonths[season.Mode])
+		winners, ended, err := endKarmaSeason(ctx, conn, season.ID, endsAt, nextStartedAt, season.CarryPercent)
+		if err != nil {
+			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{GroupID: season.ChatID, Error: err.Error()})
+			failures = append(failures, err)
+			continue
+		}
+		if !ended {
+			continue
+		}
+
+		lang := chatLanguage(conn, season.ChatID, nil)
+		message := formatKarmaSeasonEnd(lang, season.Number, winners, season.CarryPercent)
+		if err := SendMessage(season.ChatID, message); err != nil {
+			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+				GroupID: season.ChatID,
+				Error:   fmt.Sprintf("announce end of karma season %d: %v", season.Number, err),
+			})
+		}
+	}
+
+	return stdErrors.Join(failures...)
+}
+
+func formatKarmaSeasonEnd(lang string, number int, winners []karmaSeasonStanding, carryPercent int) string {
+	var b strings.Builder
+	b.WriteString(i18n.T(lang, "season.ended", i18n.Args{"number": number}))
+	b.WriteString("\n\n")
+	if len(winners) == 0 {
+		b.WriteString(i18n.T(lang, "season.no_winners", nil))
+		b.WriteString("\n")
+	}
+	for _, winner := range winners {
+		b.WriteString(fmt.Sprintf("%s %s — %d\n", seasonMedal(winner.Rank), historyName(lang, winner.Name), winner.Karma))
+	}
+	b.WriteString("\n")
+
+	next := i18n.Args{"number": number + 1, "percent": carryPercent}
+	if carryPercent == 0 {
+		b.WriteString(i18n.T(lang, "season.reset", next))
+	} else {
+		b.WriteString(i18n.T(lang, "season.carried", next))
+	}
+
+	return b.String()
+}
+
+func seasonMedal(rank int) string {
+	switch rank {
+	case 1:
+		return "🥇"
+	case 2:
+		return "🥈"
+	case 3:
+		return "🥉"
+	}
+
+	return fmt.Sprintf("%d)", rank)
+}
+
+// KarmaSeasonFromCommand shows the current season's top 10, or with "end"
+// lets an admin close the season now.
+func KarmaSeasonFromCommand(c *CommandContext) error {
+	if c.Arg("action") == karmaSeasonActionEnd {
+		return endKarmaSeasonFromCommand(c)
+	}
+
+	settings, err := getKarmaSettings(c.Conn, c.ChatID())
+	if err != nil {
+		return err
+	}
+
+	season, err := currentKarmaSeason(c.Conn, c.ChatID())
+	if err != nil {
+		return err
+	}
+
+	standings, err := GetMostLovedUsers(c.Conn, c.ChatID(), leaderboardDefaultLimit)
+	if err != nil {
+		return fmt.Errorf("query karma season standings: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.T(c.Lang, "season.current", i18n.Args{
+		"number": season.Number,
+		"date":   season.StartedAt.UTC().Format("02-01-2006"),
+	}))
+	b.WriteString("\n")
+	if endsAt, ok := seasonEndsAt(settings.SeasonMode, season.StartedAt); ok {
+		b.WriteString(i18n.T(c.Lang, "season.ends", i18n.Args{"date": endsAt.Format("02-01-2006")}))
+	} else {
+		b.WriteString(i18n.T(c.Lang, "season.ends_manual", nil))
+	}
+	b.WriteString("\n\n")
+
+	for i, standing := range standings {
+		b.WriteString(fmt.Sprintf("%d) %s — %d\n", i+1, historyName(c.Lang, strings.TrimSpace(standing.Name)), standing.Karma))
+	}
+	if len(standings) == 0 {
+		b.WriteString(i18n.T(c.Lang, "season.no_standings", nil))
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), b.String())
+}
+
+func endKarmaSeasonFromCommand(c *CommandContext) error {
+	isAdmin, err := isUserAdmin(c.ChatID(), c.Message.From.ID)
+	if err != nil {
+		return fmt.Errorf("check admin: %w", err)
+	}
+	if !isAdmin {
+		return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "season.admin_only", nil))
+	}
+
+	settings, err := getKarmaSettings(c.Conn, c.ChatID())
+	if err != nil {
+		return err
+	}
+
+	season, err := currentKarmaSeason(c.Conn, c.ChatID())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	winners, ended, err := endKarmaSeason(context.Background(), c.Conn, season.ID, now, now, settings.SeasonCarryPercent)
+	if err != nil || !ended {
+		return err
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), formatKarmaSeasonEnd(c.Lang, season.Number, winners, settings.SeasonCarryPercent))
+}
+
+// getEndedKarmaSeasons returns the chat's latest ended seasons, newest first.
+func getEndedKarmaSeasons(conn shared.DBTX, chatId int64, limit int) ([]karmaSeason, error) {
+	rows, err := conn.Query(context.Background(), `
+		SELECT id, chat_id, number, started_at, ended_at
+		FROM karma_seasons
+		WHERE chat_id = $1 AND ended_at IS NOT NULL
+		ORDER BY number DESC
+		LIMIT $2
+	`, chatId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query ended karma seasons: %w", err)
+	}
+
+	seasons, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (karmaSeason, error) {
+		var season karmaSeason
+		err := row.Scan(&season.ID, &season.ChatID, &season.Number, &season.StartedAt, &season.EndedAt)
+		return season, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read ended karma seasons: %w", err)
+	}
+
+	return seasons, nil
+}
+
+// ShowKarmaSeasonHistory lists the champions of the chat's last seasons.
+func ShowKarmaSeasonHistory(c *CommandContext) error {
+	count := int(c.IntArg("count"))
+	if c.Arg("count") == "" {
+		count = karmaSeasonHistoryDefault
+	}
+	if count < 1 || count > karmaSeasonHistoryMax {
+		return SendMessageWithReply(c.ChatID(), c.ThreadID(), c.Message.MessageID, i18n.T(c.Lang, "season.bad_count", i18n.Args{"max": karmaSeasonHistoryMax}))
+	}
+
+	seasons, err := getEndedKarmaSeasons(c.Conn, c.ChatID(), count)
+	if err != nil {
+		return err
+	}
+	if len(seasons) == 0 {
+		return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "season.history_none", nil))
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.N(c.Lang, "season.history_header", len(seasons), nil))
+	b.WriteString("\n\n")
+	for _, season := range seasons {
+		champions, err := getKarmaSeasonWinners(context.Background(), c.Conn, season.ID, 1)
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, len(champions))
+		for i, champion := range champions {
+			names[i] = fmt.Sprintf("%s (%d)", historyName(c.Lang, champion.Name), champion.Karma)
+		}
+		championText := strings.Join(names, ", ")
+		if len(names) == 0 {
+			championText = i18n.T(c.Lang, "season.history_no_champion", nil)
+		}
+
+		b.WriteString(i18n.T(c.Lang, "season.history_line", i18n.Args{
+			"number":    season.Number,
+			"from":      season.StartedAt.UTC().Format("02-01-2006"),
+			"to":        season.EndedAt.UTC().Format("02-01-2006"),
+			"champions": championText,
+		}))
+		b.WriteString("\n")
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), b.String())
+}
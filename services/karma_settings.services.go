@@ -0,0 +1,396 @@
+package services
+
+import (
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// KarmaSettings is a chat's karma policy, stored in chat_settings.
+type KarmaSettings struct {
+	// Cooldown is the time between two karma messages from one person.
+	Cooldown time.Duration
+	// ReceiverCooldown is the time before one person can give karma to the
+	// same receiver again. 0 turns it off.
+	ReceiverCooldown time.Duration
+	// DailyCap is how many karma one person can give in 24 hours. 0 means
+	// no cap.
+	DailyCap      int
+	AllowNegative bool
+	// MinMemberAge is how long someone must have been seen in the chat
+	// before they can give karma.
+	MinMemberAge   time.Duration
+	BotsCanReceive bool
+	// SeasonMode is how karma seasons end: by an admin, or every month or
+	// quarter.
+	SeasonMode string
+	// SeasonCarryPercent is the share of karma kept into the next season.
+	SeasonCarryPercent int
+	// AbuseAction is what happens to karma the abuse checks flag.
+	AbuseAction string
+	// AbuseWindow is how far back the reciprocal and repeated checks look.
+	AbuseWindow time.Duration
+	// AbusePairLimit is how many karma one person can give another within
+	// AbuseWindow before it is flagged. 0 turns the pair checks off.
+	AbusePairLimit int
+	// AbuseBurstGivers is how many people taking karma from the same person
+	// within AbuseBurstWindow is flagged. 0 turns the check off.
+	AbuseBurstGivers   int
+	AbuseBurstWindow   time.Duration
+	AbuseReducePercent int
+	// Weighting makes each karma worth more or less depending on who gives
+	// it. Raw karma is counted either way.
+	Weighting bool
+}
+
+// defaultKarmaSettings apply to chats without a chat_settings row and match
+// the column defaults.
+var defaultKarmaSettings = KarmaSettings{
+	Cooldown:           60 * time.Second,
+	AllowNegative:      true,
+	BotsCanReceive:     true,
+	SeasonMode:         karmaSeasonManual,
+	AbuseAction:        karmaAbuseOff,
+	AbuseWindow:        24 * time.Hour,
+	AbusePairLimit:     3,
+	AbuseBurstGivers:   3,
+	AbuseBurstWindow:   10 * time.Minute,
+	AbuseReducePercent: 50,
+}
+
+// karmaSetting is one setting /karma_settings can change. Column is written
+// into SQL as is, so it must only ever come from karmaSettings below.
+type karmaSetting struct {
+	Name   string
+	Column string
+	// Switch settings take on/off, Choices settings one of the choices; the
+	// others take a number from Min to Max, or 0 as well when ZeroOff is set.
+	Switch  bool
+	Choices []string
+	Min     int
+	Max     int
+	ZeroOff bool
+	// Label is the catalog key describing the setting.
+	Label string
+	Get   func(settings KarmaSettings) string
+}
+
+const (
+	karmaSettingOn  = "on"
+	karmaSettingOff = "off"
+)
+
+var karmaSettings = []karmaSetting{
+	{
+		Name:   "cooldown",
+		Column: "karma_cooldown_seconds",
+		Max:    24 * 60 * 60,
+		Label:  "settings.cooldown",
+		Get:    func(s KarmaSettings) string { return strconv.Itoa(int(s.Cooldown.Seconds())) },
+	},
+	{
+		Name:   "receiver_cooldown",
+		Column: "karma_receiver_cooldown_seconds",
+		Max:    7 * 24 * 60 * 60,
+		Label:  "settings.receiver_cooldown",
+		Get:    func(s KarmaSettings) string { return strconv.Itoa(int(s.ReceiverCooldown.Seconds())) },
+	},
+	{
+		Name:   "daily_cap",
+		Column: "karma_daily_cap",
+		Max:    1000,
+		Label:  "settings.daily_cap",
+		Get:    func(s KarmaSettings) string { return strconv.Itoa(s.DailyCap) },
+	},
+	{
+		Name:   "negative",
+		Column: "karma_allow_negative",
+		Switch: true,
+		Label:  "settings.negative",
+		Get:    func(s KarmaSettings) string { return onOff(s.AllowNegative) },
+	},
+	{
+		Name:   "min_member_hours",
+		Column: "karma_min_member_hours",
+		Max:    365 * 24,
+		Label:  "settings.min_member_hours",
+		Get:    func(s KarmaSettings) string { return strconv.Itoa(int(s.MinMemberAge.Hours())) },
+	},
+	{
+		Name:   "bots",
+		Column: "karma_bots_can_receive",
+		Switch: true,
+		Label:  "settings.bots",
+		Get:    func(s KarmaSettings) string { return onOff(s.BotsCanReceive) },
+	},
+	{
+		Name:    "season",
+		Column:  "season_mode",
+		Choices: karmaSeasonModes,
+		Label:   "settings.season",
+		Get:     func(s KarmaSettings) string { return s.SeasonMode },
+	},
+	{
+		Name:   "season_carry",
+		Column: "season_carry_percent",
+		Max:    100,
+		Label:  "settings.season_carry",
+		Get:    func(s KarmaSettings) string { return strconv.Itoa(s.SeasonCarryPercent) },
+	},
+	{
+		Name:    "abuse",
+		Column:  "abuse_action",
+		Choices: karmaAbuseActions,
+		Label:   "settings.abuse",
+		Get:     func(s KarmaSettings) string { return s.AbuseAction },
+	},
+	{
+		Name:   "abuse_window_hours",
+		Column: "abuse_window_hours",
+		Min:    1,
+		Max:    30 * 24,
+		Label:  "settings.abuse_window_hours",
+		Get:    func(s KarmaSettings) string { return strconv.Itoa(int(s.AbuseWindow.Hours())) },
+	},
+	{
+		Name:   "abuse_pair_limit",
+		Column: "abuse_pair_limit",
+		Max:    100,
+		Label:  "settings.abuse_pair_limit",
+		Get:    func(s KarmaSettings) string { return strconv.Itoa(s.AbusePairLimit) },
+	},
+	{
+		Name:    "abuse_burst_givers",
+		Column:  "abuse_burst_givers",
+		Min:     2,
+		Max:     100,
+		ZeroOff: true,
+		Label:   "settings.abuse_burst_givers",
+		Get:     func(s KarmaSettings) string { return strconv.Itoa(s.AbuseBurstGivers) },
+	},
+	{
+		Name:   "abuse_burst_minutes",
+		Column: "abuse_burst_minutes",
+		Min:    1,
+		Max:    24 * 60,
+		Label:  "settings.abuse_burst_minutes",
+		Get:    func(s KarmaSettings) string { return strconv.Itoa(int(s.AbuseBurstWindow.Minutes())) },
+	},
+	{
+		Name:   "abuse_reduce_percent",
+		Column: "abuse_reduce_percent",
+		Max:    100,
+		Label:  "settings.abuse_reduce_percent",
+		Get:    func(s KarmaSettings) string { return strconv.Itoa(s.AbuseReducePercent) },
+	},
+	{
+		Name:   "weighting",
+		Column: "karma_weighting",
+		Switch: true,
+		Label:  "settings.weighting",
+		Get:    func(s KarmaSettings) string { return onOff(s.Weighting) },
+	},
+}
+
+func onOff(value bool) string {
+	if value {
+		return karmaSettingOn
+	}
+
+	return karmaSettingOff
+}
+
+func karmaSettingNames() []string {
+	names := make([]string, len(karmaSettings))
+	for i, setting := range karmaSettings {
+		names[i] = setting.Name
+	}
+
+	return names
+}
+
+func findKarmaSetting(name string) *karmaSetting {
+	for i := range karmaSettings {
+		if karmaSettings[i].Name == name {
+			return &karmaSettings[i]
+		}
+	}
+
+	return nil
+}
+
+// getKarmaSettings returns the chat's karma policy, or the defaults when
+// nothing was set.
+func getKarmaSettings(conn shared.DBTX, chatId int64) (KarmaSettings, error) {
+	var cooldown, receiverCooldown, minMemberHours, abuseWindowHours, abuseBurstMinutes int
+	settings := defaultKarmaSettings
+	err := conn.QueryRow(context.Background(), `
+		SELECT
+			karma_cooldown_seconds,
+			karma_receiver_cooldown_seconds,
+			karma_daily_cap,
+			karma_allow_negative,
+			karma_min_member_hours,
+			karma_bots_can_receive,
+			season_mode,
+			season_carry_percent,
+			abuse_action,
+			abuse_window_hours,
+			abuse_pair_limit,
+			abuse_burst_givers,
+			abuse_burst_minutes,
+			abuse_reduce_percent,
+			karma_weighting
+		FROM chat_settings
+		WHERE chat_id = $1
+	`, chatId).Scan(
+		&cooldown,
+		&receiverCooldown,
+		&settings.DailyCap,
+		&settings.AllowNegative,
+		&minMemberHours,
+		&settings.BotsCanReceive,
+		&settings.SeasonMode,
+		&settings.SeasonCarryPercent,
+		&settings.AbuseAction,
+		&abuseWindowHours,
+		&settings.AbusePairLimit,
+		&settings.AbuseBurstGivers,
+		&abuseBurstMinutes,
+		&settings.AbuseReducePercent,
+		&settings.Weighting,
+	)
+	if err == pgx.ErrNoRows {
+		return defaultKarmaSettings, nil
+	}
+	if err != nil {
+		return defaultKarmaSettings, fmt.Errorf("query karma settings: %w", err)
+	}
+
+	settings.Cooldown = time.Duration(cooldown) * time.Second
+	settings.ReceiverCooldown = time.Duration(receiverCooldown) * time.Second
+	settings.MinMemberAge = time.Duration(minMemberHours) * time.Hour
+	settings.AbuseWindow = time.Duration(abuseWindowHours) * time.Hour
+	settings.AbuseBurstWindow = time.Duration(abuseBurstMinutes) * time.Minute
+
+	return settings, nil
+}
+
+// setKarmaSetting stores one setting for the chat. value is a bool for switch
+// settings, a string for choice settings and an int for the rest.
+func setKarmaSetting(conn shared.DBTX, chatId int64, setting *karmaSetting, value any) error {
+	_, err := conn.Exec(context.Background(), fmt.Sprintf(`
+		INSERT INTO chat_settings (chat_id, %[1]s)
+		VALUES ($1, $2)
+		ON CONFLICT (chat_id)
+		DO UPDATE SET
+			%[1]s = EXCLUDED.%[1]s,
+			updated_at = CURRENT_TIMESTAMP
+	`, setting.Column), chatId, value)
+	if err != nil {
+		return fmt.Errorf("save karma setting %s: %w", setting.Name, err)
+	}
+
+	return nil
+}
+
+// KarmaSettingsFromCommand shows the chat's karma settings, or changes one
+// when a setting and a value are given. Only admins may change them.
+func KarmaSettingsFromCommand(c *CommandContext) error {
+	chatId := c.ChatID()
+	threadId := c.ThreadID()
+
+	if c.Arg("setting") == "" {
+		settings, err := getKarmaSettings(c.Conn, chatId)
+		if err != nil {
+			return err
+		}
+		return SendMessageToThread(chatId, threadId, formatKarmaSettings(c.Lang, settings))
+	}
+
+	setting := findKarmaSetting(c.Arg("setting"))
+	rawValue := strings.ToLower(c.Arg("value"))
+	if setting == nil || rawValue == "" {
+		return SendMessageWithReply(chatId, threadId, c.Message.MessageID, c.Spec.usageHelp(c.Lang))
+	}
+
+	isAdmin, err := isUserAdmin(chatId, c.Message.From.ID)
+	if err != nil {
+		return fmt.Errorf("check admin: %w", err)
+	}
+	if !isAdmin {
+		return SendMessageToThread(chatId, threadId, i18n.T(c.Lang, "settings.admin_only", nil))
+	}
+
+	var value any
+	if setting.Switch {
+		if rawValue != karmaSettingOn && rawValue != karmaSettingOff {
+			return SendMessageWithReply(chatId, threadId, c.Message.MessageID, i18n.T(c.Lang, "settings.bad_switch", i18n.Args{"setting": setting.Name}))
+		}
+		value = rawValue == karmaSettingOn
+	} else if len(setting.Choices) > 0 {
+		if !containsString(setting.Choices, rawValue) {
+			return SendMessageWithReply(chatId, threadId, c.Message.MessageID, i18n.T(c.Lang, "settings.bad_choice", i18n.Args{"setting": setting.Name, "choices": strings.Join(setting.Choices, ", ")}))
+		}
+		value = rawValue
+	} else {
+		number, err := strconv.Atoi(rawValue)
+		inRange := number >= setting.Min && number <= setting.Max || setting.ZeroOff && number == 0
+		if err != nil || !inRange {
+			key := "settings.bad_number"
+			if setting.ZeroOff {
+				key = "settings.bad_number_or_off"
+			}
+			return SendMessageWithReply(chatId, threadId, c.Message.MessageID, i18n.T(c.Lang, key, i18n.Args{"setting": setting.Name, "min": setting.Min, "max": setting.Max}))
+		}
+		value = number
+		rawValue = strconv.Itoa(number)
+	}
+
+	settings, err := getKarmaSettings(c.Conn, chatId)
+	if err != nil {
+		return err
+	}
+	if conflict := karmaSettingConflict(c.Lang, settings, setting, value); conflict != "" {
+		return SendMessageWithReply(chatId, threadId, c.Message.MessageID, conflict)
+	}
+
+	if err := setKarmaSetting(c.Conn, chatId, setting, value); err != nil {
+		return err
+	}
+
+	return SendMessageToThread(chatId, threadId, i18n.T(c.Lang, "settings.changed", i18n.Args{"setting": setting.Name, "value": rawValue}))
+}
+
+// karmaSettingConflict explains why setting can't take value alongside the
+// chat's other settings, or returns "". Reducing karma only changes its
+// weighted value, so the reduce action needs weighting on.
+func karmaSettingConflict(lang string, settings KarmaSettings, setting *karmaSetting, value any) string {
+	switch {
+	case setting.Column == "abuse_action" && value == karmaAbuseReduce && !settings.Weighting:
+		return i18n.T(lang, "settings.reduce_unweighted", nil)
+	case setting.Column == "karma_weighting" && value == false && settings.AbuseAction == karmaAbuseReduce:
+		return i18n.T(lang, "settings.weighting_reduces", nil)
+	}
+
+	return ""
+}
+
+func formatKarmaSettings(lang string, settings KarmaSettings) string {
+	var b strings.Builder
+	b.WriteString(i18n.T(lang, "settings.header", nil))
+	b.WriteString("\n\n")
+	for _, setting := range karmaSettings {
+		b.WriteString(fmt.Sprintf("%s = %s — %s\n", setting.Name, setting.Get(settings), i18n.T(lang, setting.Label, nil)))
+	}
+	b.WriteString("\n")
+	b.WriteString(i18n.T(lang, "settings.how_to_change", nil))
+
+	return b.String()
+}
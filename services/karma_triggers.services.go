@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bot/telegram/errors"
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	karmaTriggerKinds = []string{
+		structs.KarmaTriggerToken,
+		structs.KarmaTriggerEmoji,
+		structs.KarmaTriggerKeyword,
+		structs.KarmaTriggerRegex,
+	}
+	karmaTriggerPositions = []string{
+		structs.KarmaTriggerStart,
+		structs.KarmaTriggerAnywhere,
+		structs.KarmaTriggerWhole,
+	}
+)
+
+// matchKarmaMessage returns the trigger that makes a message give or take
+// karma, if any. The text is read, or the caption for photos and other media.
+// When the chat's triggers can't be loaded the built-in "+1"/"-1" rule still
+// applies.
+func matchKarmaMessage(conn shared.DBTX, message *structs.Message) (structs.KarmaTrigger, bool) {
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+	if strings.TrimSpace(text) == "" {
+		return structs.KarmaTrigger{}, false
+	}
+
+	triggers, err := getKarmaTriggers(conn, message.Chat.ID)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: message.Chat.ID,
+			Error:   err.Error(),
+		})
+	}
+
+	return shared.MatchKarmaTrigger(text, triggers)
+}
+
+// karmaReason is what the ledger records as the reason for karma given by
+// trigger: the token for the built-in rule, the trigger's ID otherwise.
+func karmaReason(trigger structs.KarmaTrigger) string {
+	if trigger.ID == 0 {
+		return trigger.Pattern
+	}
+
+	return fmt.Sprintf("trigger #%d", trigger.ID)
+}
+
+// getKarmaTriggers returns the chat's triggers in the order they were added,
+// which is the order they are tried in.
+func getKarmaTriggers(conn shared.DBTX, chatId int64) ([]structs.KarmaTrigger, error) {
+	rows, err := conn.Query(context.Background(), `
+		SELECT id, kind, pattern, position, karma_value
+		FROM karma_triggers
+		WHERE chat_id = $1
+		ORDER BY id
+	`, chatId)
+	if err != nil {
+		return nil, fmt.Errorf("query karma triggers: %w", err)
+	}
+
+	triggers, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (structs.KarmaTrigger, error) {
+		var trigger structs.KarmaTrigger
+		err := row.Scan(&trigger.ID, &trigger.Kind, &trigger.Pattern, &trigger.Position, &trigger.Value)
+		return trigger, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read karma triggers: %w", err)
+	}
+
+	return triggers, nil
+}
+
+// ShowKarmaTriggers lists the chat's triggers with the IDs
+// /remove_karma_trigger takes.
+func ShowKarmaTriggers(c *CommandContext) error {
+	triggers, err := getKarmaTriggers(c.Conn, c.ChatID())
+	if err != nil {
+		return err
+	}
+
+	if len(triggers) == 0 {
+		return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, "triggers.none", nil))
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.N(c.Lang, "triggers.header", len(triggers), nil))
+	b.WriteString("\n\n")
+	for _, trigger := range triggers {
+		b.WriteString(fmt.Sprintf("#%d | %s | %s | %+d | %s\n", trigger.ID, trigger.Kind, trigger.Position, trigger.Value, trigger.Pattern))
+	}
+	b.WriteString("\n")
+	b.WriteString(i18n.T(c.Lang, "triggers.builtin", nil))
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), b.String())
+}
+
+// AddKarmaTriggerFromCommand saves a trigger after checking it the same way
+// it will be matched, so a broken regular expression is refused up front.
+func AddKarmaTriggerFromCommand(c *CommandContext) error {
+	trigger := structs.KarmaTrigger{
+		Kind:     c.Arg("kind"),
+		Position: c.Arg("position"),
+		Value:    int(c.IntArg("value")),
+		Pattern:  strings.TrimSpace(c.Arg("pattern")),
+	}
+
+	if err := shared.ValidateKarmaTrigger(trigger); err != nil {
+		reply, ok := karmaTriggerProblem(c.Lang, err)
+		if !ok {
+			return err
+		}
+		return SendMessageWithReply(c.ChatID(), c.ThreadID(), c.Message.MessageID, reply)
+	}
+
+	// A duplicate fails the INSERT, so it runs in a savepoint that is rolled
+	// back without taking the rest of the update with it.
+	ctx := context.Background()
+	tx, err := c.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin karma trigger transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO karma_triggers (chat_id, kind, pattern, position, karma_value, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, c.ChatID(), trigger.Kind, trigger.Pattern, trigger.Position, trigger.Value, c.Message.From.ID).Scan(&trigger.ID)
+	if isUniqueKarmaTriggerError(err) {
+		return SendMessageWithReply(c.ChatID(), c.ThreadID(), c.Message.MessageID, i18n.T(c.Lang, "triggers.duplicate", nil))
+	}
+	if err != nil {
+		return fmt.Errorf("save karma trigger: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit karma trigger: %w", err)
+	}
+
+	return SendMessageWithReply(c.ChatID(), c.ThreadID(), c.Message.MessageID, i18n.T(c.Lang, "triggers.added", i18n.Args{
+		"id":    trigger.ID,
+		"value": fmt.Sprintf("%+d", trigger.Value),
+	}))
+}
+
+// karmaTriggerProblem explains why ValidateKarmaTrigger refused a trigger.
+// Kinds and positions never get here; the command's choices reject them.
+func karmaTriggerProblem(lang string, err error) (string, bool) {
+	switch {
+	case stdErrors.Is(err, shared.ErrKarmaTriggerValue):
+		return i18n.T(lang, "triggers.bad_value", i18n.Args{"max": shared.MaxKarmaTriggerValue}), true
+	case stdErrors.Is(err, shared.ErrKarmaTriggerPattern):
+		return i18n.T(lang, "triggers.bad_pattern", i18n.Args{"max": shared.MaxKarmaTriggerPattern}), true
+	case stdErrors.Is(err, shared.ErrKarmaTriggerTokenSpaces):
+		return i18n.T(lang, "triggers.token_spaces", nil), true
+	case stdErrors.Is(err, shared.ErrKarmaTriggerRegex):
+		return i18n.T(lang, "triggers.bad_regex", i18n.Args{"error": err.Error()}), true
+	}
+
+	return "", false
+}
+
+func isUniqueKarmaTriggerError(err error) bool {
+	var pgErr *pgconn.PgError
+	return stdErrors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "uq_karma_triggers_chat_rule"
+}
+
+// RemoveKarmaTrigger deletes one of the chat's triggers by ID.
+func RemoveKarmaTrigger(c *CommandContext) error {
+	triggerId := c.IntArg("id")
+	tag, err := c.Conn.Exec(context.Background(), `
+		DELETE FROM karma_triggers
+		WHERE id = $1 AND chat_id = $2
+	`, triggerId, c.ChatID())
+	if err != nil {
+		return fmt.Errorf("delete karma trigger %d: %w", triggerId, err)
+	}
+
+	key := "triggers.removed"
+	if tag.RowsAffected() == 0 {
+		key = "triggers.not_found"
+	}
+
+	return SendMessageToThread(c.ChatID(), c.ThreadID(), i18n.T(c.Lang, key, i18n.Args{"id": triggerId}))
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bot/telegram/shared"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// karmaWeight returns what one karma from giverId is worth in the chat: 1
+// when the chat doesn't weigh karma, otherwise shared.KarmaWeight of the
+// giver's raw karma, time since they were first seen and karma given lately.
+func karmaWeight(conn shared.DBTX, chatId int64, giverId int64) (float64, bool, error) {
+	settings, err := getKarmaSettings(conn, chatId)
+	if err != nil {
+		return 1, false, err
+	}
+	if !settings.Weighting {
+		return 1, false, nil
+	}
+
+	var giverKarma int
+	err = conn.QueryRow(context.Background(), `
+		SELECT COALESCE(karma, 0)
+		FROM users_ranking
+		WHERE group_id = $1 AND user_id = $2
+	`, chatId, giverId).Scan(&giverKarma)
+	if err != nil && err != pgx.ErrNoRows {
+		return 1, false, fmt.Errorf("query karma of giver %d: %w", giverId, err)
+	}
+
+	now := time.Now().UTC()
+	var tenure time.Duration
+	firstSeen, err := chatMemberSince(conn, chatId, giverId)
+	if err != nil {
+		return 1, false, err
+	}
+	if firstSeen != nil {
+		tenure = now.Sub(*firstSeen)
+	}
+
+	recentGiven, err := countKarmaGivenSince(conn, chatId, giverId, now.Add(-shared.KarmaWeightRecentWindow))
+	if err != nil {
+		return 1, false, err
+	}
+
+	return shared.KarmaWeight(giverKarma, tenure, recentGiven), true, nil
+}
+
+// formatWeightedKarma writes weighted karma without trailing zeros.
+func formatWeightedKarma(karma float64) string {
+	return strconv.FormatFloat(karma, 'f', -1, 64)
+}
+
+// formatWeightedValue is formatWeightedKarma with a sign, for single karma.
+func formatWeightedValue(value float64) string {
+	if value > 0 {
+		return "+" + formatWeightedKarma(value)
+	}
+
+	return formatWeightedKarma(value)
+}
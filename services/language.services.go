@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bot/telegram/errors"
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Clears the chat's language so replies follow each sender's Telegram language.
+const languageAuto = "auto"
+
+// chatLanguage picks the language to answer in: the one set for the chat with
+// /language, else the user's Telegram language, else the default. user may be
+// nil, e.g. for scheduled reminders.
+func chatLanguage(conn shared.DBTX, chatId int64, user *structs.User) string {
+	language, err := getChatLanguage(conn, chatId)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID: chatId,
+			Error:   err.Error(),
+		})
+	}
+	if language != "" {
+		return language
+	}
+
+	if user != nil {
+		if language := i18n.Normalize(user.LanguageCode); language != "" {
+			return language
+		}
+	}
+
+	return i18n.DefaultLanguage
+}
+
+// getChatLanguage returns the language set for the chat, or "" when none is.
+func getChatLanguage(conn shared.DBTX, chatId int64) (string, error) {
+	var language *string
+	err := conn.QueryRow(context.Background(), `
+		SELECT language
+		FROM chat_settings
+		WHERE chat_id = $1
+	`, chatId).Scan(&language)
+	if err == pgx.ErrNoRows || (err == nil && language == nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query chat language: %w", err)
+	}
+
+	return i18n.Normalize(*language), nil
+}
+
+// setChatLanguage stores the chat's language. "" clears it.
+func setChatLanguage(conn shared.DBTX, chatId int64, language string) error {
+	var normalizedLanguage *string
+	if language != "" {
+		normalizedLanguage = &language
+	}
+
+	_, err := conn.Exec(context.Background(), `
+		INSERT INTO chat_settings (chat_id, language)
+		VALUES ($1, $2)
+		ON CONFLICT (chat_id)
+		DO UPDATE SET
+			language = EXCLUDED.language,
+			updated_at = CURRENT_TIMESTAMP
+	`, chatId, normalizedLanguage)
+	if err != nil {
+		return fmt.Errorf("save chat language: %w", err)
+	}
+
+	return nil
+}
+
+func languageChoices() []string {
+	return append(i18n.Languages(), languageAuto)
+}
+
+// ChangeLanguageFromCommand shows the chat's language, or changes it when a
+// language is given. In groups only admins may change it.
+func ChangeLanguageFromCommand(c *CommandContext) error {
+	chatId := c.ChatID()
+	threadId := c.ThreadID()
+	requested := c.Arg("language")
+
+	if requested == "" {
+		current, err := getChatLanguage(c.Conn, chatId)
+		if err != nil {
+			return err
+		}
+		if current == "" {
+			return SendMessageToThread(chatId, threadId, i18n.T(c.Lang, "language.current_auto", i18n.Args{"language": languageName(c.Lang)}))
+		}
+
+		return SendMessageToThread(chatId, threadId, i18n.T(c.Lang, "language.current", i18n.Args{"language": languageName(current)}))
+	}
+
+	if c.Message.Chat.Type != chatTypePrivate {
+		isAdmin, err := isUserAdmin(chatId, c.Message.From.ID)
+		if err != nil {
+			return fmt.Errorf("check admin: %w", err)
+		}
+		if !isAdmin {
+			return SendMessageToThread(chatId, threadId, i18n.T(c.Lang, "language.admin_only", nil))
+		}
+	}
+
+	if requested == languageAuto {
+		if err := setChatLanguage(c.Conn, chatId, ""); err != nil {
+			return err
+		}
+
+		language := chatLanguage(c.Conn, chatId, c.Message.From)
+		return SendMessageToThread(chatId, threadId, i18n.T(language, "language.reset", nil))
+	}
+
+	if err := setChatLanguage(c.Conn, chatId, requested); err != nil {
+		return err
+	}
+
+	return SendMessageToThread(chatId, threadId, i18n.T(requested, "language.set", i18n.Args{"language": languageName(requested)}))
+}
+
+// languageName is a language's name written in that language.
+func languageName(language string) string {
+	return i18n.T(language, "language.name", nil)
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Windows a leaderboard can cover. Windows start at midnight UTC, weeks on
+// Monday.
+const (
+	leaderboardWindowToday = "today"
+	leaderboardWindowWeek  = "week"
+	leaderboardWindowMonth = "month"
+	leaderboardWindowYear  = "year"
+	leaderboardWindowAll   = "all"
+)
+
+var leaderboardWindows = []string{
+	leaderboardWindowToday,
+	leaderboardWindowWeek,
+	leaderboardWindowMonth,
+	leaderboardWindowYear,
+	leaderboardWindowAll,
+}
+
+const (
+	leaderboardScopeTopic    = "topic"
+	leaderboardValueWeighted = "weighted"
+	leaderboardDefaultLimit  = 10
+	leaderboardMaxLimit      = 50
+)
+
+// leaderboardOptionsArg takes a window, a limit and "topic" in any order.
+var leaderboardOptionsArg = CommandArg{
+	Name:        "options",
+	Placeholder: "arg.leaderboard_options",
+	Kind:        commandArgText,
+	Optional:    true,
+	Rest:        true,
+}
+
+// weightedLeaderboardOptionsArg also takes "weighted", for the boards of
+// karma received.
+var weightedLeaderboardOptionsArg = CommandArg{
+	Name:        "options",
+	Placeholder: "arg.weighted_options",
+	Kind:        commandArgText,
+	Optional:    true,
+	Rest:        true,
+}
+
+// LeaderboardOptions is what a leaderboard command asked for.
+type LeaderboardOptions struct {
+	Window    string
+	Limit     int
+	TopicOnly bool
+	Weighted  bool
+}
+
+// ParseLeaderboardOptions reads the words after a leaderboard command. Each
+// option may be given once.
+func ParseLeaderboardOptions(text string) (LeaderboardOptions, bool) {
+	options := LeaderboardOptions{Window: leaderboardWindowAll, Limit: leaderboardDefaultLimit}
+	var seenWindow, seenLimit bool
+	for _, field := range strings.Fields(strings.ToLower(text)) {
+		switch {
+		case field == leaderboardScopeTopic && !options.TopicOnly:
+			options.TopicOnly = true
+		case field == leaderboardValueWeighted && !options.Weighted:
+			options.Weighted = true
+		case containsString(leaderboardWindows, field) && !seenWindow:
+			options.Window = field
+			seenWindow = true
+		case !seenLimit:
+			limit, err := strconv.Atoi(field)
+			if err != nil || limit < 1 || limit > leaderboardMaxLimit {
+				return LeaderboardOptions{}, false
+			}
+			options.Limit = limit
+			seenLimit = true
+		default:
+			return LeaderboardOptions{}, false
+		}
+	}
+
+	return options, true
+}
+
+// LeaderboardWindowStart returns when window began at now, or nil for all
+// time.
+func LeaderboardWindowStart(window string, now time.Time) *time.Time {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var start time.Time
+	switch window {
+	case leaderboardWindowToday:
+		start = today
+	case leaderboardWindowWeek:
+		start = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	case leaderboardWindowMonth:
+		start = shared.PeriodStart(now, 1)
+	case leaderboardWindowYear:
+		start = shared.PeriodStart(now, 12)
+	default:
+		return nil
+	}
+
+	return &start
+}
+
+// leaderboard is one of the karma boards. All-time boards read the running
+// totals where there are any; windows and topics without totals read the
+// karma_events ledger.
+type leaderboard struct {
+	Header      string
+	TopicHeader string
+	AllTime     func(conn shared.DBTX, chatId int64, limit int) ([]UsersLovedHatedStruct, error)
+	// TopicAllTime is nil when the board has no per-topic totals.
+	TopicAllTime func(conn shared.DBTX, chatId int64, threadId int, limit int) ([]UsersLovedHatedStruct, error)
+	// WeightedAllTime is nil when the board can't be weighted. Weighted
+	// topic boards read the ledger.
+	WeightedAllTime func(conn shared.DBTX, chatId int64, limit int) ([]UsersLovedHatedStruct, error)
+	Ledger          func(conn shared.DBTX, q KarmaLedgerQuery) ([]UsersLovedHatedStruct, error)
+}
+
+var (
+	lovedLeaderboard = leaderboard{
+		Header:          "leaderboard.loved",
+		TopicHeader:     "leaderboard.loved_topic",
+		AllTime:         GetMostLovedUsers,
+		TopicAllTime:    GetMostLovedUsersInTopic,
+		WeightedAllTime: GetMostLovedUsersWeighted,
+		Ledger:          GetMostLovedUsersInLedger,
+	}
+	hatedLeaderboard = leaderboard{
+		Header:          "leaderboard.hated",
+		TopicHeader:     "leaderboard.hated_topic",
+		AllTime:         GetMostHatedUsers,
+		TopicAllTime:    GetMostHatedUsersInTopic,
+		WeightedAllTime: GetMostHatedUsersWeighted,
+		Ledger:          GetMostHatedUsersInLedger,
+	}
+	generousLeaderboard = leaderboard{
+		Header:      "leaderboard.generous",
+		TopicHeader: "leaderboard.generous_topic",
+		AllTime:     GetMostGenerousUsers,
+		Ledger:      GetMostGenerousUsersInLedger,
+	}
+	criticalLeaderboard = leaderboard{
+		Header:      "leaderboard.critical",
+		TopicHeader: "leaderboard.critical_topic",
+		AllTime:     GetMostCriticalUsers,
+		Ledger:      GetMostCriticalUsersInLedger,
+	}
+)
+
+// ShowLeaderboard posts board for the window, limit and scope given after
+// the command. With "topic" it ranks only karma given inside the forum topic
+// the command came from, and with "weighted" it ranks weighted karma.
+func ShowLeaderboard(c *CommandContext, board leaderboard) error {
+	chatId := c.ChatID()
+	threadId := c.ThreadID()
+
+	options, ok := ParseLeaderboardOptions(c.Arg("options"))
+	if !ok {
+		return SendMessageWithReply(chatId, threadId, c.Message.MessageID, c.Spec.usageHelp(c.Lang))
+	}
+	if options.TopicOnly && threadId == 0 {
+		return SendMessageToThread(chatId, threadId, i18n.T(c.Lang, "leaderboard.topic_only", nil))
+	}
+	if options.Weighted && board.WeightedAllTime == nil {
+		return SendMessageToThread(chatId, threadId, i18n.T(c.Lang, "leaderboard.no_weighting", nil))
+	}
+
+	since := LeaderboardWindowStart(options.Window, time.Now())
+	query := KarmaLedgerQuery{ChatID: chatId, Since: since, Limit: options.Limit, Weighted: options.Weighted}
+	if options.TopicOnly {
+		query.ThreadID = threadId
+	}
+
+	allTime, topicAllTime := board.AllTime, board.TopicAllTime
+	if options.Weighted {
+		allTime, topicAllTime = board.WeightedAllTime, nil
+	}
+
+	var users []UsersLovedHatedStruct
+	var err error
+	switch {
+	case since == nil && !options.TopicOnly:
+		users, err = allTime(c.Conn, chatId, options.Limit)
+	case since == nil && topicAllTime != nil:
+		users, err = topicAllTime(c.Conn, chatId, threadId, options.Limit)
+	default:
+		users, err = board.Ledger(c.Conn, query)
+	}
+	if err != nil {
+		return fmt.Errorf("query leaderboard: %w", err)
+	}
+
+	if len(users) == 0 {
+		emptyKey := "leaderboard.empty"
+		switch {
+		case since != nil:
+			emptyKey = "leaderboard.empty_window"
+		case options.TopicOnly:
+			emptyKey = "leaderboard.empty_topic"
+		}
+		return SendMessageToThread(chatId, threadId, i18n.T(c.Lang, emptyKey, nil))
+	}
+
+	headerKey := board.Header
+	if options.TopicOnly {
+		headerKey = board.TopicHeader
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.N(c.Lang, headerKey, len(users), nil))
+	if since != nil {
+		b.WriteString("\n")
+		b.WriteString(i18n.T(c.Lang, "leaderboard.window_"+options.Window, nil))
+	}
+	if options.Weighted {
+		b.WriteString("\n")
+		b.WriteString(i18n.T(c.Lang, "leaderboard.weighted", nil))
+	}
+	b.WriteString("\n\n")
+	for i, u := range users {
+		value := strconv.Itoa(u.Karma)
+		if options.Weighted {
+			value = formatWeightedKarma(u.Weighted)
+		}
+		b.WriteString(fmt.Sprintf("%d) %s — %s\n", i+1, historyName(c.Lang, strings.TrimSpace(u.Name)), value))
+	}
+
+	return SendMessageToThread(chatId, threadId, b.String())
+}
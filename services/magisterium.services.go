@@ -2,6 +2,7 @@ package services
 
 import (
 	"bot/telegram/config"
+	"bot/telegram/i18n"
 	"bot/telegram/shared"
 	"bot/telegram/structs"
 	"bytes"
@@ -14,8 +15,6 @@ import (
 	"time"
 )
 
-const askCatholicChurchCommand = "/ask_catholic_church"
-
 type magisteriumChatRequest struct {
 	Model    string               `json:"model"`
 	Messages []magisteriumMessage `json:"messages"`
@@ -41,35 +40,31 @@ type magisteriumCitation struct {
 	SourceURL         string `json:"source_url"`
 }
 
-func isAskCatholicChurchCommand(text string) bool {
-	return isBotCommand(text, askCatholicChurchCommand)
-}
-
-func AskCatholicChurchFromCommand(update structs.Update) error {
+func AskCatholicChurchFromCommand(update structs.Update, question string, lang string) error {
 	message := update.Message
 	if message == nil {
 		return nil
 	}
 
 	chatID := message.Chat.ID
-	question := commandArgument(message.Text)
+	threadID := messageThreadID(message)
 	if question == "" {
-		return SendMessageWithReply(chatID, message.MessageID, "Use /ask_catholic_church followed by your question. Example: /ask_catholic_church What does the Church teach about forgiveness?")
+		return SendMessageWithReply(chatID, threadID, message.MessageID, i18n.T(lang, "magisterium.usage", nil))
 	}
 
-	answer, err := askMagisterium(question)
+	answer, err := askMagisterium(question, lang)
 	if err != nil {
-		if replyErr := SendMessageWithReply(chatID, message.MessageID, "I couldn't get an answer from Magisterium AI right now. Please try again later."); replyErr != nil {
+		if replyErr := SendMessageWithReply(chatID, threadID, message.MessageID, i18n.T(lang, "magisterium.unavailable", nil)); replyErr != nil {
 			return fmt.Errorf("ask magisterium: %w; send error reply: %w", err, replyErr)
 		}
 
 		return fmt.Errorf("ask magisterium: %w", err)
 	}
 
-	return SendLongHTMLMessageWithReply(chatID, message.MessageID, answer)
+	return SendLongHTMLMessageWithReply(chatID, threadID, message.MessageID, answer)
 }
 
-func askMagisterium(question string) (string, error) {
+func askMagisterium(question string, lang string) (string, error) {
 	env := config.Current
 	if env.MagisteriumAPIKey == "" {
 		return "", fmt.Errorf("missing MAGISTERIUM_API_KEY")
@@ -123,10 +118,10 @@ func askMagisterium(question string) (string, error) {
 		return "", fmt.Errorf("magisterium API returned no answer")
 	}
 
-	return formatMagisteriumAnswer(chatResponse), nil
+	return formatMagisteriumAnswer(chatResponse, lang), nil
 }
 
-func formatMagisteriumAnswer(response magisteriumChatResponse) string {
+func formatMagisteriumAnswer(response magisteriumChatResponse, lang string) string {
 	answer := strings.TrimSpace(response.Choices[0].Message.Content)
 	if len(response.Citations) == 0 {
 		return answer
@@ -134,7 +129,8 @@ func formatMagisteriumAnswer(response magisteriumChatResponse) string {
 
 	var b strings.Builder
 	b.WriteString(answer)
-	b.WriteString("\n\nSources:")
+	b.WriteString("\n\n")
+	b.WriteString(i18n.T(lang, "magisterium.sources", nil))
 
 	for i, citation := range response.Citations {
 		if i >= 3 {
@@ -143,7 +139,7 @@ func formatMagisteriumAnswer(response magisteriumChatResponse) string {
 
 		title := strings.TrimSpace(citation.DocumentTitle)
 		if title == "" {
-			title = "Catholic source"
+			title = i18n.T(lang, "magisterium.unknown_source", nil)
 		}
 
 		b.WriteString(fmt.Sprintf("\n%d. %s", i+1, title))
@@ -159,12 +155,3 @@ func formatMagisteriumAnswer(response magisteriumChatResponse) string {
 
 	return b.String()
 }
-
-func commandArgument(text string) string {
-	fields := strings.Fields(strings.TrimSpace(text))
-	if len(fields) < 2 {
-		return ""
-	}
-
-	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), fields[0]))
-}
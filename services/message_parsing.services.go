@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bot/telegram/structs"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// Entity types Telegram marks up in message text.
+const (
+	entityBotCommand  = "bot_command"
+	entityMention     = "mention"
+	entityTextMention = "text_mention"
+)
+
+// ParsedCommand is the bot command a message starts with.
+type ParsedCommand struct {
+	// Name is the command without the slash or @bot suffix, lowercased.
+	Name string
+	// Bot is the username the command was addressed to, as in
+	// /show_events@SomeBot, or "" when it wasn't addressed.
+	Bot string
+	// Args is the text after the command, trimmed.
+	Args string
+}
+
+// Mention is a user mentioned in a message, either by @username or by a text
+// mention of a user without a username.
+type Mention struct {
+	// Username is set for @username mentions, without the @.
+	Username string
+	// User is set for text mentions, which carry the whole user.
+	User *structs.User
+	// Text is the mention as it appears in the message.
+	Text string
+}
+
+// ParsedMessage is what the entities of a message say about it.
+type ParsedMessage struct {
+	Command  *ParsedCommand
+	Mentions []Mention
+}
+
+// ParseMessage reads the command and mentions of a message from its entities.
+// Entity offsets count UTF-16 code units, so text is sliced in those units
+// rather than in bytes. Only a bot_command entity at the very start makes the
+// message a command. Captions are read for mentions when there is no text.
+func ParseMessage(message *structs.Message) ParsedMessage {
+	var parsed ParsedMessage
+	if message == nil {
+		return parsed
+	}
+
+	text, entities := message.Text, message.Entities
+	if text == "" {
+		text, entities = message.Caption, message.CaptionEntities
+	}
+	units := utf16.Encode([]rune(text))
+
+	for _, entity := range entities {
+		value, ok := entityText(units, entity)
+		if !ok {
+			continue
+		}
+
+		switch entity.Type {
+		case entityBotCommand:
+			if entity.Offset != 0 || parsed.Command != nil || message.Text == "" {
+				continue
+			}
+			name, bot, _ := strings.Cut(strings.TrimPrefix(value, "/"), "@")
+			parsed.Command = &ParsedCommand{
+				Name: strings.ToLower(name),
+				Bot:  bot,
+				Args: strings.TrimSpace(string(utf16.Decode(units[entity.Offset+entity.Length:]))),
+			}
+		case entityMention:
+			parsed.Mentions = append(parsed.Mentions, Mention{Username: strings.TrimPrefix(value, "@"), Text: value})
+		case entityTextMention:
+			if entity.User != nil {
+				parsed.Mentions = append(parsed.Mentions, Mention{User: entity.User, Text: value})
+			}
+		}
+	}
+
+	return parsed
+}
+
+func entityText(units []uint16, entity structs.MessageEntity) (string, bool) {
+	end := entity.Offset + entity.Length
+	if entity.Offset < 0 || entity.Length <= 0 || end > len(units) {
+		return "", false
+	}
+
+	return string(utf16.Decode(units[entity.Offset:end])), true
+}
+
+// commandName returns the name of the command a message starts with, or ""
+// when it isn't a command.
+func commandName(message *structs.Message) string {
+	if command := ParseMessage(message).Command; command != nil {
+		return command.Name
+	}
+
+	return ""
+}
+
+var (
+	botUserMu sync.Mutex
+	botUser   *structs.User
+)
+
+// BotUser returns the bot's own account from getMe. It is fetched once and
+// kept until the Telegram client changes.
+func BotUser() (*structs.User, error) {
+	botUserMu.Lock()
+	defer botUserMu.Unlock()
+
+	if botUser != nil {
+		return botUser, nil
+	}
+
+	user, err := TelegramClient().GetMe(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("getMe: %w", err)
+	}
+	botUser = user
+
+	return botUser, nil
+}
+
+func forgetBotUser() {
+	botUserMu.Lock()
+	botUser = nil
+	botUserMu.Unlock()
+}
+
+// isCommandForUs tells whether a command is meant for this bot: either it
+// isn't addressed, or it is addressed to our username. When getMe fails we
+// can't tell, so addressed commands are left to whichever bot they name.
+func isCommandForUs(command *ParsedCommand) (bool, error) {
+	if command.Bot == "" {
+		return true, nil
+	}
+
+	user, err := BotUser()
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(command.Bot, user.Username), nil
+}
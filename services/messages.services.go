@@ -1,230 +1,166 @@
 package services
 
 import (
+	"bot/telegram/botapi"
 	"bot/telegram/config"
+	"bot/telegram/i18n"
+	"bot/telegram/richtext"
 	"bot/telegram/shared"
-	"bytes"
+	"bot/telegram/structs"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"html"
-	"io"
-	"net/http"
 	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-var markdownBoldPattern = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+var (
+	telegramClientMu sync.Mutex
+	telegramClient   *botapi.Client
+)
 
-type sendMessageRequest struct {
-	ChatID      int64                `json:"chat_id"`
-	Text        string               `json:"text"`
-	ReplyMarkup inlineKeyboardMarkup `json:"reply_markup"`
-}
+// TelegramClient returns the Bot API client shared by every send path. It is
+// built from config.Current on first use.
+func TelegramClient() *botapi.Client {
+	telegramClientMu.Lock()
+	defer telegramClientMu.Unlock()
 
-type inlineKeyboardMarkup struct {
-	InlineKeyboard [][]inlineKeyboardButton `json:"inline_keyboard"`
-}
+	if telegramClient == nil {
+		env := config.Current
+		telegramClient = botapi.NewClient(env.TelegramBaseURL, env.Token, shared.CustomClient)
+		telegramClient.Limiter = botapi.NewRateLimiter()
+	}
 
-type inlineKeyboardButton struct {
-	Text string `json:"text"`
-	URL  string `json:"url"`
+	return telegramClient
 }
 
-type botCommand struct {
-	Command     string `json:"command"`
-	Description string `json:"description"`
-}
+// SetTelegramClient replaces the shared Bot API client, e.g. to point the bot
+// at a fake server in tests. The cached getMe answer goes with the old client.
+func SetTelegramClient(client *botapi.Client) {
+	telegramClientMu.Lock()
+	telegramClient = client
+	telegramClientMu.Unlock()
 
-type setMyCommandsRequest struct {
-	Commands []botCommand `json:"commands"`
+	forgetBotUser()
 }
 
-func RegisterBotCommands() error {
-	env := config.Current
-	baseUrl := env.TelegramBaseURL + env.Token + "/setMyCommands"
-	payload := setMyCommandsRequest{
-		Commands: []botCommand{
-			{Command: "command", Description: "Show command help"},
-			{Command: "ask_catholic_church", Description: "Ask a Catholic teaching question"},
-			{Command: "new_event", Description: "Open the event form"},
-			{Command: "set_birthday", Description: "Reply with DD-MM-YYYY to save a birthday"},
-			{Command: "show_events", Description: "Show all active events in this group"},
-			{Command: "delete_event", Description: "Delete an event by ID (admins only)"},
-			{Command: "lovedusers", Description: "Show users with the most positive karma"},
-			{Command: "hatedusers", Description: "Show users with the most negative karma"},
-		},
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
-
-	resp, err := shared.CustomClient.Post(baseUrl, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("telegram API returned status %d for setMyCommands: %s", resp.StatusCode, string(body))
+// messageThreadID returns the forum topic a message was posted in, or 0 for
+// the General topic and for chats without topics. Replies pass it on so they
+// stay in the topic they answer.
+func messageThreadID(message *structs.Message) int {
+	if message == nil || message.MessageThreadID == nil || message.IsTopicMessage == nil || !*message.IsTopicMessage {
+		return 0
 	}
 
-	return nil
+	return *message.MessageThreadID
 }
 
-func SendMessage(chatId int64, message string) error {
-	// Define the base URL
-	env := config.Current
-	token := env.Token
-	telegramUrl := env.TelegramBaseURL
-	baseUrl := telegramUrl + token + "/sendMessage"
-
-	// Create the data for the API request
-	data := url.Values{}
-	data.Add("chat_id", strconv.FormatInt(chatId, 10))
-	data.Add("text", message)
-
-	// Append the data to the URL
-	completeUrl := baseUrl + "?" + data.Encode()
-
-	// Send the HTTP request with retry logic
-	resp, err := shared.CustomClient.Get(completeUrl)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram API returned status %d for sendMessage", resp.StatusCode)
+// nullableThreadID stores "no topic" as NULL.
+func nullableThreadID(threadId int) *int {
+	if threadId == 0 {
+		return nil
 	}
 
-	return nil
+	return &threadId
 }
 
-func SendMessageWithReply[T ~int | ~int64](chatId int64, replyToMessageId T, message string) error {
-	// Define the base URL
-	env := config.Current
-	token := env.Token
-	telegramUrl := env.TelegramBaseURL
-	baseUrl := telegramUrl + token + "/sendMessage"
-
-	// Create the data for the API request
-	data := url.Values{}
-	data.Add("chat_id", strconv.FormatInt(chatId, 10))
-	data.Add("text", message)
-	data.Add("reply_to_message_id", strconv.FormatInt(int64(replyToMessageId), 10))
-
-	// Append the data to the URL
-	completeUrl := baseUrl + "?" + data.Encode()
-
-	// Send the HTTP request
-	resp, err := shared.CustomClient.Get(completeUrl)
-	if err != nil {
-		return err
+// sendMessage sends through the shared client. When the group turned into a
+// supergroup, it moves the chat's data over and resends to the new chat.
+func sendMessage(params botapi.SendMessageParams) (*structs.Message, error) {
+	message, err := TelegramClient().SendMessage(context.Background(), params)
+	newChatId, migrated := botapi.IsChatMigrated(err)
+	if !migrated {
+		return message, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram API returned status %d for sendMessage with reply", resp.StatusCode)
-	}
+	migrateChatInBackground(params.ChatID, newChatId)
 
-	return nil
+	// Message and topic IDs from the old group don't exist in the supergroup.
+	params.ChatID = newChatId
+	params.MessageThreadID = 0
+	params.ReplyToMessageID = 0
+	return TelegramClient().SendMessage(context.Background(), params)
 }
 
-func SendMessageWithReplyParseMode[T ~int | ~int64](chatId int64, replyToMessageId T, message string, parseMode string) error {
-	env := config.Current
-	token := env.Token
-	telegramUrl := env.TelegramBaseURL
-	baseUrl := telegramUrl + token + "/sendMessage"
-
-	data := url.Values{}
-	data.Add("chat_id", strconv.FormatInt(chatId, 10))
-	data.Add("text", message)
-	data.Add("reply_to_message_id", strconv.FormatInt(int64(replyToMessageId), 10))
-	data.Add("parse_mode", parseMode)
-	data.Add("disable_web_page_preview", "true")
-
-	completeUrl := baseUrl + "?" + data.Encode()
-	resp, err := shared.CustomClient.Get(completeUrl)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+func SendMessage(chatId int64, message string) error {
+	return SendMessageToThread(chatId, 0, message)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram API returned status %d for sendMessage with parse mode", resp.StatusCode)
-	}
+// SendMessageToThread posts into a forum topic. threadId 0 means the General
+// topic, or just the chat when it has no topics.
+func SendMessageToThread(chatId int64, threadId int, message string) error {
+	_, err := sendMessage(botapi.SendMessageParams{
+		ChatID:          chatId,
+		MessageThreadID: threadId,
+		Text:            message,
+	})
+	return err
+}
 
-	return nil
+func SendMessageWithReply[T ~int | ~int64](chatId int64, threadId int, replyToMessageId T, message string) error {
+	_, err := sendMessage(botapi.SendMessageParams{
+		ChatID:           chatId,
+		MessageThreadID:  threadId,
+		Text:             message,
+		ReplyToMessageID: int64(replyToMessageId),
+	})
+	return err
 }
 
-func SendLongMessageWithReply[T ~int | ~int64](chatId int64, replyToMessageId T, message string) error {
-	const telegramMessageLimit = 4096
-	trimmedMessage := strings.TrimSpace(message)
-	if trimmedMessage == "" {
-		return nil
-	}
+func SendMessageWithReplyParseMode[T ~int | ~int64](chatId int64, threadId int, replyToMessageId T, message string, parseMode string) error {
+	_, err := sendMessage(botapi.SendMessageParams{
+		ChatID:                chatId,
+		MessageThreadID:       threadId,
+		Text:                  message,
+		ParseMode:             parseMode,
+		ReplyToMessageID:      int64(replyToMessageId),
+		DisableWebPagePreview: true,
+	})
+	return err
+}
 
-	for len(trimmedMessage) > 0 {
-		chunk := trimmedMessage
-		if len(chunk) > telegramMessageLimit {
-			chunk = trimmedMessage[:telegramMessageLimit]
-			if lastNewline := strings.LastIndex(chunk, "\n"); lastNewline > 0 {
-				chunk = chunk[:lastNewline]
-			}
-		}
+func SendMessageWithKeyboard(chatId int64, threadId int, message string, keyboard structs.InlineKeyboardMarkup) error {
+	_, err := sendMessage(botapi.SendMessageParams{
+		ChatID:          chatId,
+		MessageThreadID: threadId,
+		Text:            message,
+		ReplyMarkup:     &keyboard,
+	})
+	return err
+}
 
-		if err := SendMessageWithReply(chatId, replyToMessageId, chunk); err != nil {
+func SendLongMessageWithReply[T ~int | ~int64](chatId int64, threadId int, replyToMessageId T, message string) error {
+	for _, chunk := range richtext.SplitText(strings.TrimSpace(message), richtext.MessageLimit) {
+		if err := SendMessageWithReply(chatId, threadId, replyToMessageId, chunk); err != nil {
 			return err
 		}
-
-		trimmedMessage = strings.TrimSpace(strings.TrimPrefix(trimmedMessage, chunk))
 	}
 
 	return nil
 }
 
-func SendLongHTMLMessageWithReply[T ~int | ~int64](chatId int64, replyToMessageId T, message string) error {
-	const telegramMessageLimit = 3500
-	trimmedMessage := strings.TrimSpace(message)
-	if trimmedMessage == "" {
-		return nil
-	}
-
-	for len(trimmedMessage) > 0 {
-		chunk := trimmedMessage
-		if len(chunk) > telegramMessageLimit {
-			chunk = trimmedMessage[:telegramMessageLimit]
-			if lastNewline := strings.LastIndex(chunk, "\n"); lastNewline > 0 {
-				chunk = chunk[:lastNewline]
-			}
-		}
-
-		if err := SendMessageWithReplyParseMode(chatId, replyToMessageId, telegramHTMLFromMarkdown(chunk), "HTML"); err != nil {
+// SendLongHTMLMessageWithReply renders Markdown as Telegram HTML and sends it
+// in as many messages as it takes.
+func SendLongHTMLMessageWithReply[T ~int | ~int64](chatId int64, threadId int, replyToMessageId T, message string) error {
+	rendered := richtext.ToHTML(strings.TrimSpace(message))
+	for _, chunk := range richtext.SplitHTML(rendered, richtext.MessageLimit) {
+		if err := SendMessageWithReplyParseMode(chatId, threadId, replyToMessageId, chunk, "HTML"); err != nil {
 			return err
 		}
-
-		trimmedMessage = strings.TrimSpace(strings.TrimPrefix(trimmedMessage, chunk))
 	}
 
 	return nil
 }
 
-func telegramHTMLFromMarkdown(message string) string {
-	escaped := html.EscapeString(message)
-	return markdownBoldPattern.ReplaceAllString(escaped, "<b>$1</b>")
-}
-
-func BuildEventsWebAppURL(chatId int64, userId int64) string {
+// BuildEventsWebAppURL links to the event form. In forum groups it also passes
+// the topic, so the event's reminders are posted where it was created.
+func BuildEventsWebAppURL(chatId int64, threadId int, userId int64) string {
 	env := config.Current
 	parsedURL, err := url.Parse(env.TelegramWebAppURL)
 	if err != nil {
@@ -233,41 +169,25 @@ func BuildEventsWebAppURL(chatId int64, userId int64) string {
 
 	query := parsedURL.Query()
 	query.Set("ctx", createSignedWebAppContext(chatId, userId))
+	if threadId != 0 {
+		query.Set("thread", strconv.Itoa(threadId))
+	}
 	parsedURL.RawQuery = query.Encode()
 
 	return parsedURL.String()
 }
 
-func SendEventsWebAppMessage(chatId int64, userId int64) error {
-	env := config.Current
-	baseUrl := env.TelegramBaseURL + env.Token + "/sendMessage"
-	webAppURL := BuildEventsWebAppURL(chatId, userId)
-	payload := sendMessageRequest{
-		ChatID: chatId,
-		Text:   "Create a new event from the Telegram Web App.",
-		ReplyMarkup: inlineKeyboardMarkup{InlineKeyboard: [][]inlineKeyboardButton{{{
-			Text: "Create event",
-			URL:  webAppURL,
+func SendEventsWebAppMessage(chatId int64, threadId int, lang string, userId int64) error {
+	_, err := sendMessage(botapi.SendMessageParams{
+		ChatID:          chatId,
+		MessageThreadID: threadId,
+		Text:            i18n.T(lang, "events.webapp_prompt", nil),
+		ReplyMarkup: &structs.InlineKeyboardMarkup{InlineKeyboard: [][]structs.InlineKeyboardButton{{{
+			Text: i18n.T(lang, "events.webapp_button", nil),
+			URL:  BuildEventsWebAppURL(chatId, threadId, userId),
 		}}}},
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
-
-	resp, err := shared.CustomClient.Post(baseUrl, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("telegram API returned status %d for sendMessage with web app: %s", resp.StatusCode, string(body))
-	}
-
-	return nil
+	})
+	return err
 }
 
 func createSignedWebAppContext(chatId int64, userId int64) string {
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bot/telegram/errors"
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Telegram keeps undelivered updates for 24 hours, so ledger rows older than
+// this can never be redelivered.
+const processedUpdatesRetention = 7 * 24 * time.Hour
+
+// LoadUpdateOffset returns the getUpdates offset saved by the last run.
+func LoadUpdateOffset(ctx context.Context, conn shared.DBTX) (int, error) {
+	var offset int
+	err := conn.QueryRow(ctx, "SELECT next_offset FROM bot_update_offset WHERE id = 1").Scan(&offset)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("load update offset: %w", err)
+	}
+
+	return offset, nil
+}
+
+// ProcessUpdate runs HandleUpdate exactly once per update_id. The ledger row
+// and every database write the handler makes share one transaction, so a crash
+// mid-update leaves no trace and the update is retried, while a redelivered
+// update that already committed is skipped. Statements a handler expects may
+// fail belong in a savepoint (conn.Begin inside the handler); any other failed
+// statement aborts the update and is recorded as the reason.
+func ProcessUpdate(ctx context.Context, conn shared.DBTX, update structs.Update) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin update %d transaction: %w", update.UpdateID, err)
+	}
+	defer tx.Rollback(ctx)
+
+	claimed, err := claimUpdate(ctx, tx, update, "processed", "")
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	handlerTx := &updateTx{Tx: tx}
+	HandleUpdate(handlerTx, update)
+
+	if err := tx.Commit(ctx); err != nil {
+		// A failed statement inside a handler aborts the transaction. Its
+		// writes are gone, but replaying would resend its replies, so the
+		// update is marked failed, with the statement that failed, and not
+		// retried.
+		if handlerTx.failed != nil {
+			err = fmt.Errorf("%w (commit: %v)", handlerTx.failed, err)
+		}
+		return recordFailedUpdate(ctx, conn, update, err)
+	}
+
+	return nil
+}
+
+// updateTx is the transaction handlers run in. It remembers the first
+// statement that failed, which is what aborted the transaction when the
+// commit fails. Statements inside savepoints run on the savepoint and are not
+// seen here.
+type updateTx struct {
+	pgx.Tx
+	failed error
+}
+
+func (t *updateTx) note(err error) error {
+	if err != nil && t.failed == nil && !stdErrors.Is(err, pgx.ErrNoRows) {
+		t.failed = err
+	}
+	return err
+}
+
+func (t *updateTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	tag, err := t.Tx.Exec(ctx, sql, arguments...)
+	return tag, t.note(err)
+}
+
+func (t *updateTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := t.Tx.Query(ctx, sql, args...)
+	if err != nil {
+		return rows, t.note(err)
+	}
+	return &updateRows{Rows: rows, tx: t}, nil
+}
+
+func (t *updateTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := t.Query(ctx, sql, args...)
+	return updateRow{rows: rows, err: err}
+}
+
+type updateRows struct {
+	pgx.Rows
+	tx *updateTx
+}
+
+func (r *updateRows) Err() error {
+	return r.tx.note(r.Rows.Err())
+}
+
+// updateRow is pgx's QueryRow on top of updateTx.Query, so that a failure
+// that only shows up in Scan is noted too.
+type updateRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r updateRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+
+	return r.rows.Err()
+}
+
+func claimUpdate(ctx context.Context, conn shared.DBTX, update structs.Update, status string, errorMessage string) (bool, error) {
+	var normalizedError *string
+	if errorMessage != "" {
+		normalizedError = &errorMessage
+	}
+
+	tag, err := conn.Exec(ctx, `
+		INSERT INTO processed_updates (update_id, chat_id, status, error)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (update_id) DO NOTHING
+	`, update.UpdateID, updateChatID(update), status, normalizedError)
+	if err != nil {
+		return false, fmt.Errorf("claim update %d: %w", update.UpdateID, err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// SaveUpdateOffset stores the offset polling resumes from. It only moves
+// forward; updates above it that already ran are skipped by the ledger.
+func SaveUpdateOffset(ctx context.Context, conn shared.DBTX, nextOffset int) error {
+	_, err := conn.Exec(ctx, `
+		INSERT INTO bot_update_offset (id, next_offset)
+		VALUES (1, $1)
+		ON CONFLICT (id)
+		DO UPDATE SET
+			next_offset = GREATEST(bot_update_offset.next_offset, EXCLUDED.next_offset),
+			updated_at = CURRENT_TIMESTAMP
+	`, nextOffset)
+	if err != nil {
+		return fmt.Errorf("save update offset: %w", err)
+	}
+
+	return nil
+}
+
+func recordFailedUpdate(ctx context.Context, conn shared.DBTX, update structs.Update, cause error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("commit update %d: %w; begin failure record: %w", update.UpdateID, cause, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := claimUpdate(ctx, tx, update, "failed", cause.Error()); err != nil {
+		return err
+	}
+
+	_ = errors.CreateErrorRecord(tx, errors.ErrorRecordInput{
+		GroupID: updateChatID(update),
+		Error:   fmt.Sprintf("update %d rolled back: %v", update.UpdateID, cause),
+	})
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit update %d: %w; record failure: %w", update.UpdateID, cause, err)
+	}
+
+	return nil
+}
+
+// PruneProcessedUpdates drops ledger rows Telegram can no longer redeliver.
+func PruneProcessedUpdates(ctx context.Context, conn shared.DBTX) error {
+	_, err := conn.Exec(ctx, "DELETE FROM processed_updates WHERE processed_at < $1", time.Now().UTC().Add(-processedUpdatesRetention))
+	if err != nil {
+		return fmt.Errorf("prune processed updates: %w", err)
+	}
+
+	return nil
+}
+
+func updateChatID(update structs.Update) int64 {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	case update.EditedMessage != nil:
+		return update.EditedMessage.Chat.ID
+	case update.ChannelPost != nil:
+		return update.ChannelPost.Chat.ID
+	case update.EditedChannelPost != nil:
+		return update.EditedChannelPost.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bot/telegram/errors"
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RememberUsers stores the users a message shows us, so @username mentions can
+// later be mapped to user IDs. Telegram only tells bots who owns a username
+// when that user appears in an update.
+func RememberUsers(conn shared.DBTX, message *structs.Message) {
+	if message == nil {
+		return
+	}
+
+	users := []*structs.User{message.From}
+	if message.ReplyToMessage != nil {
+		users = append(users, message.ReplyToMessage.From)
+	}
+	for _, mention := range ParseMessage(message).Mentions {
+		users = append(users, mention.User)
+	}
+	users = append(users, pointersTo(message.NewChatMembers)...)
+
+	for _, user := range users {
+		if user == nil {
+			continue
+		}
+		if err := upsertTelegramUser(conn, user); err != nil {
+			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+				GroupID:  message.Chat.ID,
+				SenderID: user.ID,
+				Error:    err.Error(),
+			})
+		}
+	}
+
+	if message.Chat.Type == chatTypePrivate {
+		return
+	}
+	members := pointersTo(message.NewChatMembers)
+	if message.From != nil {
+		members = append(members, message.From)
+	}
+	for _, member := range members {
+		if err := rememberChatMember(conn, message.Chat.ID, member.ID); err != nil {
+			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+				GroupID:  message.Chat.ID,
+				SenderID: member.ID,
+				Error:    err.Error(),
+			})
+		}
+	}
+}
+
+// rememberChatMember notes when a user was first seen in a group. Later
+// sightings leave it alone.
+func rememberChatMember(conn shared.DBTX, chatId int64, userId int64) error {
+	_, err := conn.Exec(context.Background(), `
+		INSERT INTO chat_members (chat_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (chat_id, user_id) DO NOTHING
+	`, chatId, userId)
+	if err != nil {
+		return fmt.Errorf("remember member %d of chat %d: %w", userId, chatId, err)
+	}
+
+	return nil
+}
+
+// chatMemberSince returns when a user was first seen in a group, or nil if
+// they never were.
+func chatMemberSince(conn shared.DBTX, chatId int64, userId int64) (*time.Time, error) {
+	var firstSeen time.Time
+	err := conn.QueryRow(context.Background(), `
+		SELECT first_seen_at
+		FROM chat_members
+		WHERE chat_id = $1 AND user_id = $2
+	`, chatId, userId).Scan(&firstSeen)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query first sighting of member %d: %w", userId, err)
+	}
+
+	return &firstSeen, nil
+}
+
+func pointersTo(users []structs.User) []*structs.User {
+	pointers := make([]*structs.User, len(users))
+	for i := range users {
+		pointers[i] = &users[i]
+	}
+
+	return pointers
+}
+
+// upsertTelegramUser saves a user. Usernames can change hands, so whoever held
+// this username before loses it.
+func upsertTelegramUser(conn shared.DBTX, user *structs.User) error {
+	var username *string
+	if trimmed := strings.TrimSpace(user.Username); trimmed != "" {
+		username = &trimmed
+
+		_, err := conn.Exec(context.Background(), `
+			UPDATE telegram_users
+			SET username = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE lower(username) = lower($2) AND user_id <> $1
+		`, user.ID, trimmed)
+		if err != nil {
+			return fmt.Errorf("release username @%s: %w", trimmed, err)
+		}
+	}
+
+	_, err := conn.Exec(context.Background(), `
+		INSERT INTO telegram_users (user_id, username, first_name, last_name, is_bot)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			username = EXCLUDED.username,
+			first_name = EXCLUDED.first_name,
+			last_name = EXCLUDED.last_name,
+			is_bot = EXCLUDED.is_bot,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE (telegram_users.username, telegram_users.first_name, telegram_users.last_name, telegram_users.is_bot)
+			IS DISTINCT FROM (EXCLUDED.username, EXCLUDED.first_name, EXCLUDED.last_name, EXCLUDED.is_bot)
+	`, user.ID, username, user.FirstName, user.LastName, user.IsBot)
+	if err != nil {
+		return fmt.Errorf("save user %d: %w", user.ID, err)
+	}
+
+	return nil
+}
+
+// findUsersByUsername looks up users by username, ignoring case. Usernames
+// nobody we've seen holds are left out of the result.
+func findUsersByUsername(conn shared.DBTX, usernames []string) (map[string]*structs.User, error) {
+	found := make(map[string]*structs.User, len(usernames))
+	if len(usernames) == 0 {
+		return found, nil
+	}
+
+	lowered := make([]string, len(usernames))
+	for i, username := range usernames {
+		lowered[i] = strings.ToLower(username)
+	}
+
+	rows, err := conn.Query(context.Background(), `
+		SELECT user_id, username, COALESCE(first_name, ''), COALESCE(last_name, ''), is_bot
+		FROM telegram_users
+		WHERE lower(username) = ANY($1)
+	`, lowered)
+	if err != nil {
+		return nil, fmt.Errorf("query users by username: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user structs.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.IsBot); err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		found[strings.ToLower(user.Username)] = &user
+	}
+
+	return found, rows.Err()
+}
+
+// getTelegramUser returns the user we saw with userId, or a user with only
+// the ID when we never saw them.
+func getTelegramUser(conn shared.DBTX, userId int64) (*structs.User, error) {
+	user := structs.User{ID: userId}
+	err := conn.QueryRow(context.Background(), `
+		SELECT COALESCE(username, ''), COALESCE(first_name, ''), COALESCE(last_name, ''), is_bot
+		FROM telegram_users
+		WHERE user_id = $1
+	`, userId).Scan(&user.Username, &user.FirstName, &user.LastName, &user.IsBot)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("query user %d: %w", userId, err)
+	}
+
+	return &user, nil
+}
+
+// commandTarget picks the user a command is about: the first user it
+// mentions, else the author of the message it replies to, else, with
+// orSender, whoever sent it. It gives nil after telling the chat why when a
+// mentioned username is one we haven't seen, or when there is no target and
+// orSender is false.
+func commandTarget(c *CommandContext, orSender bool) (*structs.User, error) {
+	if len(c.Mentions) > 0 {
+		mention := c.Mentions[0]
+		if mention.User != nil {
+			return mention.User, nil
+		}
+
+		known, err := findUsersByUsername(c.Conn, []string{mention.Username})
+		if err != nil {
+			return nil, err
+		}
+		if user := known[strings.ToLower(mention.Username)]; user != nil {
+			return user, nil
+		}
+
+		text := i18n.T(c.Lang, "karma.unknown_users", i18n.Args{"usernames": "@" + mention.Username})
+		return nil, SendMessageWithReply(c.ChatID(), c.ThreadID(), c.Message.MessageID, text)
+	}
+
+	if reply := c.Message.ReplyToMessage; reply != nil && reply.From != nil {
+		return reply.From, nil
+	}
+
+	if orSender {
+		return c.Message.From, nil
+	}
+
+	return nil, SendMessageWithReply(c.ChatID(), c.ThreadID(), c.Message.MessageID, c.Spec.usageHelp(c.Lang))
+}
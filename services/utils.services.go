@@ -2,122 +2,209 @@ package services
 
 import (
 	"bot/telegram/errors"
+	"bot/telegram/i18n"
+	"bot/telegram/shared"
 	"bot/telegram/structs"
 	"context"
 	stdErrors "errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 )
 
-func KarmaValidations(update structs.Update, conn *pgx.Conn) error {
-	if update.Message.ReplyToMessage == nil || update.Message.ReplyToMessage.From == nil {
-		return stdErrors.New("no reply or sender")
-	}
-
-	chatId := update.Message.Chat.ID
-	replyToMessageId := update.Message.ReplyToMessage.MessageID
+// KarmaValidations drops the targets the sender may not give karma to and
+// tells the chat why. It returns an error when no target is left. replyTo is
+// the message the explanations answer.
+func KarmaValidations(conn shared.DBTX, message *structs.Message, targets []*structs.User, replyTo int, karmaValue int, lang string) ([]*structs.User, error) {
+	chatId := message.Chat.ID
+	threadId := messageThreadID(message)
 
 	// If user try to give karma to itself
-	if update.Message.ReplyToMessage.From.ID == update.Message.From.ID {
-		err := SendMessageWithReply(chatId, replyToMessageId, "Wew. You can't give karma to yourself dummy ~")
+	allowed := make([]*structs.User, 0, len(targets))
+	for _, target := range targets {
+		if target.ID != message.From.ID {
+			allowed = append(allowed, target)
+		}
+	}
+	if len(allowed) < len(targets) {
+		err := SendMessageWithReply(chatId, threadId, replyTo, i18n.T(lang, "karma.self", nil))
 		if err != nil {
 			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-				GroupID:    chatId,
-				SenderID:   update.Message.From.ID,
-				ReceiverID: update.Message.ReplyToMessage.From.ID,
-				Error:      err.Error(),
+				GroupID:  chatId,
+				SenderID: message.From.ID,
+				Error:    err.Error(),
 			})
 		}
-		return stdErrors.New("can't give karma to yourself")
 	}
-
-	// If user is not inside the time frame
-	if err := DbUserRestrictions(conn, update.Message); err != nil {
-		return err
+	if len(allowed) == 0 {
+		return nil, stdErrors.New("can't give karma to yourself")
 	}
 
-	return nil
+	// If user is not inside the time frame
+	return DbUserRestrictions(conn, message, allowed, replyTo, karmaValue, lang)
 }
 
-func DbUserRestrictions(conn *pgx.Conn, currentMessage *structs.Message) error {
-	chatId := currentMessage.Chat.ID
-	replyToMessageId := currentMessage.ReplyToMessage.MessageID
-	receiverId := currentMessage.ReplyToMessage.From.ID
+// DbUserRestrictions applies the per-user restrictions stored in
+// users_ranking and the chat's karma settings: the sender must be allowed to
+// give karma, be a member for long enough, be out of the cooldown and under
+// the daily cap, and each target must be allowed to receive it. A message with
+// several targets counts as one giving for the cooldown and as one karma per
+// target for the daily cap.
+func DbUserRestrictions(conn shared.DBTX, message *structs.Message, targets []*structs.User, replyTo int, karmaValue int, lang string) ([]*structs.User, error) {
+	chatId := message.Chat.ID
+	senderId := message.From.ID
+
+	settings, err := getKarmaSettings(conn, chatId)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID:  chatId,
+			SenderID: senderId,
+			Error:    err.Error(),
+		})
+		return nil, err
+	}
 
-	var lastMessageDateTime time.Time
-	var allowedToGiveKarma bool
+	// No row means this is the first time this user gives karma here; the
+	// row and last_karma_given are created when the karma is added.
+	var lastMessageDateTime *time.Time
+	allowedToGiveKarma := true
 
-	validationSenderSql := "SELECT last_karma_given, allowed_to_give_karma FROM users_ranking WHERE user_id = $1 AND group_id = $2"
-	err := conn.QueryRow(context.Background(), validationSenderSql, currentMessage.From.ID, currentMessage.Chat.ID).Scan(&lastMessageDateTime, &allowedToGiveKarma)
+	// Restrictions with an end date stop applying once it passes.
+	validationSenderSql := `
+		SELECT last_karma_given, COALESCE(allowed_to_give_karma, TRUE) OR COALESCE(give_karma_blocked_until <= CURRENT_TIMESTAMP, FALSE)
+		FROM users_ranking
+		WHERE user_id = $1 AND group_id = $2
+	`
+	err = conn.QueryRow(context.Background(), validationSenderSql, senderId, chatId).Scan(&lastMessageDateTime, &allowedToGiveKarma)
 
 	if err != nil && err != pgx.ErrNoRows {
 		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-			GroupID:    chatId,
-			SenderID:   currentMessage.From.ID,
-			ReceiverID: currentMessage.ReplyToMessage.From.ID,
-			Error:      err.Error(),
+			GroupID:  chatId,
+			SenderID: senderId,
+			Error:    err.Error(),
 		})
-		return fmt.Errorf("error querying last_karma_given: %w", err)
-	}
-
-	if err == pgx.ErrNoRows {
-		// If no record found, it means this is the first time this user is giving karma.
-		// Allow it, and the last_karma_given will be set when karma is successfully added.
-		return nil
+		return nil, fmt.Errorf("error querying last_karma_given: %w", err)
 	}
 
 	if !allowedToGiveKarma {
-		_ = SendMessageWithReply(chatId, replyToMessageId, "Sorry bro you can't give aura points around here.")
-		return stdErrors.New("can't give karma to yourself")
+		refuseKarma(conn, message, replyTo, i18n.T(lang, "karma.sender_blocked", nil))
+		return nil, stdErrors.New("sender not allowed to give karma")
 	}
 
-	var allowedToReceiveKarma bool
-	validationReceiverSql := "SELECT allowed_to_receive_karma FROM users_ranking WHERE user_id = $1 AND group_id = $2"
-	err = conn.QueryRow(context.Background(), validationReceiverSql, receiverId, currentMessage.Chat.ID).Scan(&allowedToReceiveKarma)
-
-	if !allowedToReceiveKarma {
-		_ = SendMessageWithReply(chatId, replyToMessageId, "Sorry bro this person can't receive aura points.")
-		return stdErrors.New("receiver not allowed to receive karma")
+	if karmaValue < 0 && !settings.AllowNegative {
+		refuseKarma(conn, message, replyTo, i18n.T(lang, "karma.negative_disabled", nil))
+		return nil, stdErrors.New("negative karma is disabled")
 	}
 
-	if err != nil && err != pgx.ErrNoRows {
-		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-			GroupID:    chatId,
-			SenderID:   currentMessage.From.ID,
-			ReceiverID: currentMessage.ReplyToMessage.From.ID,
-			Error:      err.Error(),
-		})
-		return fmt.Errorf("error querying receiver restrictions: %w", err)
+	if settings.MinMemberAge > 0 {
+		since, err := chatMemberSince(conn, chatId, senderId)
+		if err != nil {
+			return nil, err
+		}
+		if since == nil || time.Since(*since) < settings.MinMemberAge {
+			hours := int(settings.MinMemberAge.Hours())
+			refuseKarma(conn, message, replyTo, i18n.N(lang, "karma.too_new", hours, nil))
+			return nil, stdErrors.New("sender is too new to give karma")
+		}
 	}
 
-	thresholdMessageLimit := 60 * time.Second
-	if time.Since(lastMessageDateTime) < thresholdMessageLimit {
-		err := SendMessageWithReply(chatId, replyToMessageId, "Whoops you are not allowed to give karma yet :(")
-		if err != nil {
+	allowed := make([]*structs.User, 0, len(targets))
+	blocked, bots := false, false
+	var recent []string
+	for _, target := range targets {
+		if target.IsBot && !settings.BotsCanReceive {
+			bots = true
+			continue
+		}
+
+		allowedToReceiveKarma := true
+		validationReceiverSql := `
+			SELECT COALESCE(allowed_to_receive_karma, TRUE) OR COALESCE(receive_karma_blocked_until <= CURRENT_TIMESTAMP, FALSE)
+			FROM users_ranking
+			WHERE user_id = $1 AND group_id = $2
+		`
+		err = conn.QueryRow(context.Background(), validationReceiverSql, target.ID, chatId).Scan(&allowedToReceiveKarma)
+		if err != nil && err != pgx.ErrNoRows {
 			_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
 				GroupID:    chatId,
-				SenderID:   currentMessage.From.ID,
-				ReceiverID: currentMessage.ReplyToMessage.From.ID,
+				SenderID:   senderId,
+				ReceiverID: target.ID,
 				Error:      err.Error(),
 			})
+			return nil, fmt.Errorf("error querying receiver restrictions: %w", err)
+		}
+		if !allowedToReceiveKarma {
+			blocked = true
+			continue
+		}
+
+		if settings.ReceiverCooldown > 0 {
+			gave, err := gaveKarmaToSince(conn, chatId, senderId, target.ID, time.Now().UTC().Add(-settings.ReceiverCooldown))
+			if err != nil {
+				return nil, err
+			}
+			if gave {
+				recent = append(recent, telegramUserDisplayName(target))
+				continue
+			}
+		}
+
+		allowed = append(allowed, target)
+	}
+	if bots {
+		refuseKarma(conn, message, replyTo, i18n.T(lang, "karma.bots_blocked", nil))
+	}
+	if blocked {
+		refuseKarma(conn, message, replyTo, i18n.T(lang, "karma.receiver_blocked", nil))
+	}
+	if len(recent) > 0 {
+		refuseKarma(conn, message, replyTo, i18n.T(lang, "karma.receiver_cooldown", i18n.Args{"names": strings.Join(recent, ", ")}))
+	}
+	if len(allowed) == 0 {
+		return nil, stdErrors.New("no target can receive karma")
+	}
+
+	if lastMessageDateTime != nil && time.Since(*lastMessageDateTime) < settings.Cooldown {
+		refuseKarma(conn, message, replyTo, i18n.T(lang, "karma.cooldown", nil))
+		return nil, stdErrors.New("can't give karma yet")
+	}
+
+	if settings.DailyCap > 0 {
+		given, err := countKarmaGivenSince(conn, chatId, senderId, time.Now().UTC().Add(-24*time.Hour))
+		if err != nil {
+			return nil, err
+		}
+		if given+len(allowed) > settings.DailyCap {
+			refuseKarma(conn, message, replyTo, i18n.N(lang, "karma.daily_cap", settings.DailyCap, nil))
+			return nil, stdErrors.New("daily karma cap reached")
 		}
-		return stdErrors.New("can't give karma yet")
 	}
 
 	// Update last_karma_given for the sender
-	fmt.Printf("Executing UPDATE for last_karma_given for sender %d in group %d\n", currentMessage.From.ID, currentMessage.Chat.ID)
-	_, err = conn.Exec(context.Background(), "UPDATE users_ranking SET last_karma_given = $3 WHERE user_id = $1 AND group_id = $2", currentMessage.From.ID, currentMessage.Chat.ID, time.Now().UTC())
+	fmt.Printf("Executing UPDATE for last_karma_given for sender %d in group %d\n", senderId, chatId)
+	_, err = conn.Exec(context.Background(), "UPDATE users_ranking SET last_karma_given = $3 WHERE user_id = $1 AND group_id = $2", senderId, chatId, time.Now().UTC())
 	if err != nil {
 		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
-			GroupID:    chatId,
-			SenderID:   currentMessage.From.ID,
-			ReceiverID: currentMessage.ReplyToMessage.From.ID,
-			Error:      err.Error(),
+			GroupID:  chatId,
+			SenderID: senderId,
+			Error:    err.Error(),
 		})
-		return fmt.Errorf("error updating last_karma_given for sender: %w", err)
+		return nil, fmt.Errorf("error updating last_karma_given for sender: %w", err)
 	}
 
-	return nil
+	return allowed, nil
+}
+
+// refuseKarma tells the chat why karma wasn't given.
+func refuseKarma(conn shared.DBTX, message *structs.Message, replyTo int, text string) {
+	err := SendMessageWithReply(message.Chat.ID, messageThreadID(message), replyTo, text)
+	if err != nil {
+		_ = errors.CreateErrorRecord(conn, errors.ErrorRecordInput{
+			GroupID:  message.Chat.ID,
+			SenderID: message.From.ID,
+			Error:    err.Error(),
+		})
+	}
 }
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"bot/telegram/botapi"
+	"bot/telegram/structs"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const telegramSecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// Telegram never sends webhook payloads larger than a few kilobytes; anything
+// bigger than this is not an update.
+const maxWebhookBodyBytes = 1 << 20
+
+func SetWebhook(webhookURL string, secretToken string) error {
+	return TelegramClient().SetWebhook(context.Background(), botapi.SetWebhookParams{
+		URL:            webhookURL,
+		SecretToken:    secretToken,
+		AllowedUpdates: []string{"message", "edited_message", "callback_query"},
+	})
+}
+
+func DeleteWebhook() error {
+	return TelegramClient().DeleteWebhook(context.Background(), botapi.DeleteWebhookParams{DropPendingUpdates: false})
+}
+
+// NewWebhookHandler returns the HTTP handler Telegram posts updates to. Requests
+// without the configured secret token are rejected before the body is read.
+// The response is held until the update is processed, so Telegram redelivers
+// anything that failed.
+func NewWebhookHandler(secretToken string, dispatcher *UpdateDispatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		received := r.Header.Get(telegramSecretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(received), []byte(secretToken)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		var update structs.Update
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxWebhookBodyBytes)).Decode(&update); err != nil {
+			// Telegram keeps retrying anything that isn't a 2xx, so a payload we
+			// can't decode is acknowledged and logged instead of retried forever.
+			fmt.Printf("Failed to decode webhook update: %s\n", err)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if err := dispatcher.DispatchAndWait(r.Context(), update); err != nil {
+			fmt.Printf("Failed to process webhook update %d: %s\n", update.UpdateID, err)
+			http.Error(w, "update not processed", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
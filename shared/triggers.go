@@ -0,0 +1,200 @@
+package shared
+
+import (
+	"bot/telegram/structs"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxKarmaTriggerValue bounds how much karma a single trigger moves.
+	MaxKarmaTriggerValue = 10
+	// MaxKarmaTriggerPattern keeps patterns, regular expressions especially,
+	// small enough to run on every message.
+	MaxKarmaTriggerPattern = 200
+)
+
+// Reasons ValidateKarmaTrigger rejects a trigger.
+var (
+	ErrKarmaTriggerKind        = errors.New("unknown trigger kind")
+	ErrKarmaTriggerPosition    = errors.New("unknown trigger position")
+	ErrKarmaTriggerValue       = fmt.Errorf("trigger value must be between -%d and %d and not 0", MaxKarmaTriggerValue, MaxKarmaTriggerValue)
+	ErrKarmaTriggerPattern     = fmt.Errorf("trigger pattern must have 1 to %d characters", MaxKarmaTriggerPattern)
+	ErrKarmaTriggerTokenSpaces = errors.New("a token can't contain spaces")
+	ErrKarmaTriggerRegex       = errors.New("invalid regular expression")
+)
+
+var karmaRegexCache sync.Map
+
+// ValidateKarmaTrigger checks a trigger before it is saved.
+func ValidateKarmaTrigger(trigger structs.KarmaTrigger) error {
+	switch trigger.Kind {
+	case structs.KarmaTriggerToken, structs.KarmaTriggerEmoji, structs.KarmaTriggerKeyword, structs.KarmaTriggerRegex:
+	default:
+		return fmt.Errorf("%w %q", ErrKarmaTriggerKind, trigger.Kind)
+	}
+
+	switch trigger.Position {
+	case structs.KarmaTriggerStart, structs.KarmaTriggerAnywhere, structs.KarmaTriggerWhole:
+	default:
+		return fmt.Errorf("%w %q", ErrKarmaTriggerPosition, trigger.Position)
+	}
+
+	if trigger.Value == 0 || trigger.Value > MaxKarmaTriggerValue || trigger.Value < -MaxKarmaTriggerValue {
+		return ErrKarmaTriggerValue
+	}
+
+	pattern := strings.TrimSpace(trigger.Pattern)
+	if pattern == "" || utf8.RuneCountInString(pattern) > MaxKarmaTriggerPattern {
+		return ErrKarmaTriggerPattern
+	}
+	if trigger.Kind == structs.KarmaTriggerToken && len(strings.Fields(pattern)) != 1 {
+		return ErrKarmaTriggerTokenSpaces
+	}
+	if trigger.Kind == structs.KarmaTriggerRegex {
+		if _, err := karmaRegex(pattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MatchKarmaTrigger returns the first trigger that matches text. The built-in
+// "+1"/"-1" rule of ParsePlusMinusOneFromMessage always comes first.
+func MatchKarmaTrigger(text string, triggers []structs.KarmaTrigger) (structs.KarmaTrigger, bool) {
+	if ok, value := ParsePlusMinusOneFromMessage(text); ok {
+		return structs.KarmaTrigger{Kind: structs.KarmaTriggerToken, Pattern: strings.Fields(text)[0], Position: structs.KarmaTriggerStart, Value: *value}, true
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return structs.KarmaTrigger{}, false
+	}
+
+	for _, trigger := range triggers {
+		if triggerMatches(text, trigger) {
+			return trigger, true
+		}
+	}
+
+	return structs.KarmaTrigger{}, false
+}
+
+func triggerMatches(text string, trigger structs.KarmaTrigger) bool {
+	pattern := strings.TrimSpace(trigger.Pattern)
+
+	switch trigger.Kind {
+	case structs.KarmaTriggerToken:
+		tokens := strings.Fields(text)
+		switch trigger.Position {
+		case structs.KarmaTriggerStart:
+			return strings.EqualFold(tokens[0], pattern)
+		case structs.KarmaTriggerWhole:
+			return len(tokens) == 1 && strings.EqualFold(tokens[0], pattern)
+		default:
+			for _, token := range tokens {
+				if strings.EqualFold(token, pattern) {
+					return true
+				}
+			}
+		}
+	case structs.KarmaTriggerEmoji:
+		switch trigger.Position {
+		case structs.KarmaTriggerStart:
+			return strings.HasPrefix(text, pattern)
+		case structs.KarmaTriggerWhole:
+			return text == pattern
+		default:
+			return strings.Contains(text, pattern)
+		}
+	case structs.KarmaTriggerKeyword:
+		lowered := strings.ToLower(text)
+		for _, keyword := range strings.Split(pattern, ",") {
+			keyword = strings.ToLower(strings.TrimSpace(keyword))
+			if keyword != "" && keywordMatches(lowered, keyword, trigger.Position) {
+				return true
+			}
+		}
+	case structs.KarmaTriggerRegex:
+		re, err := karmaRegex(pattern)
+		if err != nil {
+			return false
+		}
+		switch trigger.Position {
+		case structs.KarmaTriggerStart:
+			loc := re.FindStringIndex(text)
+			return loc != nil && loc[0] == 0
+		case structs.KarmaTriggerWhole:
+			for _, loc := range re.FindAllStringIndex(text, -1) {
+				if loc[0] == 0 && loc[1] == len(text) {
+					return true
+				}
+			}
+			return false
+		default:
+			return re.MatchString(text)
+		}
+	}
+
+	return false
+}
+
+// keywordMatches finds keyword in text as whole words: the runes around it
+// must not be letters or digits, so "thanks" doesn't match "thanksgiving".
+func keywordMatches(text string, keyword string, position string) bool {
+	switch position {
+	case structs.KarmaTriggerWhole:
+		return strings.TrimRightFunc(text, unicode.IsPunct) == keyword
+	case structs.KarmaTriggerStart:
+		if !strings.HasPrefix(text, keyword) {
+			return false
+		}
+		after, _ := utf8.DecodeRuneInString(text[len(keyword):])
+		return len(text) == len(keyword) || !isWordRune(after)
+	}
+
+	for start := 0; start <= len(text)-len(keyword); {
+		index := strings.Index(text[start:], keyword)
+		if index < 0 {
+			return false
+		}
+		index += start
+		end := index + len(keyword)
+
+		before, _ := utf8.DecodeLastRuneInString(text[:index])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (index == 0 || !isWordRune(before)) && (end == len(text) || !isWordRune(after)) {
+			return true
+		}
+
+		_, size := utf8.DecodeRuneInString(text[index:])
+		start = index + size
+	}
+
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// karmaRegex compiles a trigger's expression once. Matching ignores case.
+func karmaRegex(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := karmaRegexCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKarmaTriggerRegex, err)
+	}
+	karmaRegexCache.Store(pattern, re)
+
+	return re, nil
+}
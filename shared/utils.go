@@ -2,7 +2,9 @@ package shared
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -52,3 +54,62 @@ func Abs[T Integer](x T) T {
 	}
 	return x
 }
+
+var durationUnits = map[string]time.Duration{
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseDuration reads a whole number of minutes, hours, days or weeks, as in
+// "30m", "12h", "7d" or "2w".
+func ParseDuration(text string) (time.Duration, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if len(text) < 2 {
+		return 0, fmt.Errorf("invalid duration %q", text)
+	}
+
+	unit, ok := durationUnits[text[len(text)-1:]]
+	if !ok {
+		return 0, fmt.Errorf("invalid duration unit in %q", text)
+	}
+
+	amount, err := strconv.ParseInt(text[:len(text)-1], 10, 64)
+	if err != nil || amount <= 0 {
+		return 0, fmt.Errorf("invalid duration amount in %q", text)
+	}
+	if amount > math.MaxInt64/int64(unit) {
+		return 0, fmt.Errorf("duration %q is too long", text)
+	}
+
+	return time.Duration(amount) * unit, nil
+}
+
+// PeriodStart returns the start, in UTC, of the calendar period of the given
+// number of months that t falls in: months 1 gives the month, 3 the quarter.
+func PeriodStart(t time.Time, months int) time.Time {
+	t = t.UTC()
+	month := (int(t.Month())-1)/months*months + 1
+	return time.Date(t.Year(), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// NextPeriodStart returns the start of the period after the one t falls in.
+func NextPeriodStart(t time.Time, months int) time.Time {
+	return PeriodStart(t, months).AddDate(0, months, 0)
+}
+
+// CarryKarma returns what is left of raw and weighted karma when a season
+// ends and keeps carryPercent of it. Raw karma is cut towards zero; weighted
+// karma is worked out in whole hundredths and rounded half away from zero,
+// as NUMERIC rounding does, so 2.07 at 50% keeps 1.04.
+func CarryKarma(karma int, weighted float64, carryPercent int) (int, float64) {
+	kept := int64(math.Round(weighted*100)) * int64(carryPercent)
+	if kept >= 0 {
+		kept = (kept + 50) / 100
+	} else {
+		kept = (kept - 50) / 100
+	}
+
+	return karma * carryPercent / 100, float64(kept) / 100
+}
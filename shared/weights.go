@@ -0,0 +1,51 @@
+package shared
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// KarmaWeightMin and KarmaWeightMax bound what one karma can be worth
+	// when a chat weighs karma.
+	KarmaWeightMin = 0.1
+	KarmaWeightMax = 2.0
+	// KarmaWeightFullTenure is how long a giver must have been in the chat
+	// for their karma to count in full.
+	KarmaWeightFullTenure = 30 * 24 * time.Hour
+	// KarmaWeightRecentWindow is how far back a giver's recent karma is
+	// counted.
+	KarmaWeightRecentWindow = 24 * time.Hour
+
+	// karmaWeightReputationScale is the giver karma worth one more point of
+	// weight; negative karma takes it away at the same rate, down to
+	// karmaWeightReputationMin.
+	karmaWeightReputationScale = 100
+	karmaWeightReputationMin   = 0.5
+	karmaWeightTenureMin       = 0.25
+	// karmaWeightBusyGiver is how much karma given within
+	// KarmaWeightRecentWindow halves the weight.
+	karmaWeightBusyGiver = 10
+)
+
+// KarmaWeight is what one karma is worth from a giver with giverKarma who
+// was first seen in the chat tenure ago and gave recentGiven karma within
+// KarmaWeightRecentWindow. Givers with karma of their own weigh more;
+// newcomers and people handing out a lot of karma weigh less.
+func KarmaWeight(giverKarma int, tenure time.Duration, recentGiven int) float64 {
+	reputation := min(max(1+float64(giverKarma)/karmaWeightReputationScale, karmaWeightReputationMin), KarmaWeightMax)
+	seniority := min(max(float64(tenure)/float64(KarmaWeightFullTenure), karmaWeightTenureMin), 1)
+	restraint := 1 / (1 + float64(max(recentGiven, 0))/karmaWeightBusyGiver)
+
+	return roundHundredths(min(max(reputation*seniority*restraint, KarmaWeightMin), KarmaWeightMax))
+}
+
+// WeighKarma returns value times weight, rounded to hundredths like the
+// weighted karma columns.
+func WeighKarma(value int, weight float64) float64 {
+	return roundHundredths(float64(value) * weight)
+}
+
+func roundHundredths(x float64) float64 {
+	return math.Round(x*100) / 100
+}
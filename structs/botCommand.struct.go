@@ -0,0 +1,20 @@
+package structs
+
+type BotCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// BotCommandScope selects which chats and users a setMyCommands list applies to.
+type BotCommandScope struct {
+	Type   string `json:"type"`
+	ChatID int64  `json:"chat_id,omitempty"`
+	UserID int64  `json:"user_id,omitempty"`
+}
+
+const (
+	BotCommandScopeDefault               = "default"
+	BotCommandScopeAllPrivateChats       = "all_private_chats"
+	BotCommandScopeAllGroupChats         = "all_group_chats"
+	BotCommandScopeAllChatAdministrators = "all_chat_administrators"
+)
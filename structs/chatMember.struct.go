@@ -0,0 +1,6 @@
+package structs
+
+type ChatMember struct {
+	Status string `json:"status"`
+	User   *User  `json:"user"`
+}
@@ -0,0 +1,36 @@
+package structs
+
+// KarmaTrigger is a chat's rule for recognising a karma message.
+type KarmaTrigger struct {
+	ID int64
+	// Kind is one of the KarmaTriggerKind values.
+	Kind string
+	// Pattern is the token, emoji, comma-separated keyword list or regular
+	// expression to look for.
+	Pattern string
+	// Position is one of the KarmaTriggerPosition values.
+	Position string
+	// Value is the karma the trigger gives, negative to take karma.
+	Value int
+}
+
+const (
+	// KarmaTriggerToken matches a whitespace-separated word, ignoring case.
+	KarmaTriggerToken = "token"
+	// KarmaTriggerEmoji matches an emoji, also when glued to other text.
+	KarmaTriggerEmoji = "emoji"
+	// KarmaTriggerKeyword matches any of a comma-separated list of words or
+	// phrases, as whole words and ignoring case.
+	KarmaTriggerKeyword = "keyword"
+	// KarmaTriggerRegex matches a regular expression (RE2 syntax).
+	KarmaTriggerRegex = "regex"
+)
+
+const (
+	// KarmaTriggerStart requires the match at the start of the message.
+	KarmaTriggerStart = "start"
+	// KarmaTriggerAnywhere accepts a match anywhere in the message.
+	KarmaTriggerAnywhere = "anywhere"
+	// KarmaTriggerWhole requires the match to be the whole message.
+	KarmaTriggerWhole = "whole"
+)
@@ -75,5 +75,5 @@ type Message struct {
 	// VideoChatEnded                *VideoChatEnded                `json:"video_chat_ended,omitempty"`
 	// VideoChatParticipantsInvited  *VideoChatParticipantsInvited  `json:"video_chat_participants_invited,omitempty"`
 	// WebAppData                    *WebAppData                    `json:"web_app_data,omitempty"`
-	// ReplyMarkup                   *InlineKeyboardMarkup          `json:"reply_markup,omitempty"`
+	ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
 }
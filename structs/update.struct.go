@@ -13,5 +13,5 @@ type Update struct {
 	EditedChannelPost *Message `json:"edited_channel_post,omitempty"`
 	// InlineQuery        *InlineQuery        `json:"inline_query,omitempty"`
 	// ChosenInlineResult *ChosenInlineResult `json:"chosen_inline_result,omitempty"`
-	// CallbackQuery      *CallbackQuery      `json:"callback_query,omitempty"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
 }
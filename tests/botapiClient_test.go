@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bot/telegram/botapi"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBotAPIClientDecodesErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/bottoken/getUpdates":
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`))
+		case "/bottoken/getChatAdministrators":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: group chat was upgraded to a supergroup chat","parameters":{"migrate_to_chat_id":-1001234}}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := botapi.NewClient(server.URL+"/bot", "token", server.Client())
+	ctx := context.Background()
+
+	_, err := client.GetUpdates(ctx, botapi.GetUpdatesParams{})
+	apiErr, ok := botapi.AsAPIError(err)
+	if !ok || !botapi.IsTooManyRequests(err) || apiErr.RetryAfter != 7 {
+		t.Errorf("expected 429 with retry_after 7, got %v", err)
+	}
+
+	_, err = client.GetChatAdministrators(ctx, -1)
+	if newChatID, migrated := botapi.IsChatMigrated(err); !migrated || newChatID != -1001234 {
+		t.Errorf("expected migrate_to_chat_id -1001234, got %v", err)
+	}
+
+	err = client.SetMyCommands(ctx, botapi.SetMyCommandsParams{})
+	if !botapi.IsBotBlocked(err) {
+		t.Errorf("expected bot blocked error, got %v", err)
+	}
+}
+
+func TestBotAPIClientRetriesAfterFloodWait(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":5,"date":0,"chat":{"id":-10,"type":"group"}}}`))
+	}))
+	defer server.Close()
+
+	client := botapi.NewClient(server.URL+"/bot", "token", server.Client())
+	client.Limiter = botapi.NewRateLimiter()
+
+	start := time.Now()
+	message, err := client.SendMessage(context.Background(), botapi.SendMessageParams{ChatID: -10, Text: "hi"})
+	if err != nil {
+		t.Fatalf("expected send to succeed after retry, got %v", err)
+	}
+	if message.MessageID != 5 || calls != 2 {
+		t.Errorf("expected message 5 after 2 calls, got message %d after %d calls", message.MessageID, calls)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected to wait retry_after before resending, waited %v", elapsed)
+	}
+}
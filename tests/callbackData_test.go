@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bot/telegram/config"
+	"bot/telegram/services"
+	"strings"
+	"testing"
+)
+
+func TestCallbackDataRoundTrip(t *testing.T) {
+	config.Current.WebAppContextSecret = "test-secret"
+
+	data, err := services.EncodeCallbackData("evdel", "42", "yes")
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if len(data) > 64 {
+		t.Errorf("callback data %q is longer than 64 bytes", data)
+	}
+
+	action, args, err := services.DecodeCallbackData(data)
+	if err != nil || action != "evdel" || len(args) != 2 || args[0] != "42" || args[1] != "yes" {
+		t.Errorf("decode %q returned %q %v %v", data, action, args, err)
+	}
+
+	forged := strings.Replace(data, "|42|", "|43|", 1)
+	if _, _, err := services.DecodeCallbackData(forged); err == nil {
+		t.Errorf("expected forged callback data %q to be rejected", forged)
+	}
+
+	if _, err := services.EncodeCallbackData("evdel", strings.Repeat("x", 64)); err == nil {
+		t.Error("expected callback data over 64 bytes to be rejected")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bot/telegram/services"
+	"bot/telegram/tests/fakebotapi"
+	"testing"
+)
+
+func TestScenarioChatMigrationRunsOnce(t *testing.T) {
+	s := newScenario(t)
+	group := fakebotapi.Group(-9000000012)
+	supergroup := fakebotapi.Group(-1009000000012)
+	ana := fakebotapi.User(9000000030, "Ana")
+	bob := fakebotapi.User(9000000031, "Bob")
+
+	question := fakebotapi.TextMessage(group, bob, "Does anyone have a charger?")
+	s.send(question)
+	s.send(fakebotapi.Reply(question, ana, "+1"))
+
+	// The upgrade is announced in both chats; the second run must not merge
+	// the old rows again.
+	for i := 0; i < 2; i++ {
+		if err := services.MigrateChat(s.tx, group.ID, supergroup.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if karma := s.karma(supergroup.ID, bob.ID); karma != 1 {
+		t.Errorf("expected Bob to have 1 karma in the supergroup, got %d", karma)
+	}
+	if rows := s.queryInt(`SELECT COUNT(*) FROM users_ranking WHERE group_id = $1`, group.ID); rows != 0 {
+		t.Errorf("expected the old group's rows to be gone, got %d", rows)
+	}
+	if runs := s.queryInt(`SELECT COUNT(*) FROM chat_migrations WHERE old_chat_id = $1 AND new_chat_id = $2`, group.ID, supergroup.ID); runs != 1 {
+		t.Errorf("expected the migration to be recorded once, got %d", runs)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bot/telegram/i18n"
+	"bot/telegram/richtext"
+	"bot/telegram/services"
+	"strings"
+	"testing"
+)
+
+func TestCommandsHelpFitsInMessages(t *testing.T) {
+	for _, lang := range i18n.Languages() {
+		messages := services.CommandsHelpMessages(lang)
+		header := i18n.T(lang, "command.help_header", nil)
+		if len(messages) == 0 || !strings.HasPrefix(messages[0], header) {
+			t.Errorf("%s: help does not start with the header", lang)
+			continue
+		}
+
+		for i, message := range messages {
+			if n := richtext.UTF16Len(message); n > richtext.MessageLimit {
+				t.Errorf("%s: help message %d is %d UTF-16 units long", lang, i+1, n)
+			}
+			// Messages break between entries, so every one after the
+			// first starts with a command's usage.
+			if i > 0 && !strings.HasPrefix(message, "/") {
+				t.Errorf("%s: help message %d starts inside an entry: %q", lang, i+1, message[:min(len(message), 40)])
+			}
+		}
+
+		help := strings.Join(messages, "\n\n")
+		for _, command := range []string{"/command", "/karma_ban", "/season", "/season_history"} {
+			if !strings.Contains(help, "\n\n"+command) {
+				t.Errorf("%s: help has no entry for %s", lang, command)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bot/telegram/config"
+	"bot/telegram/i18n"
+	"bot/telegram/services"
+	"bot/telegram/tests/fakebotapi"
+	"context"
+	"strings"
+	"testing"
+)
+
+// startFakeBotAPI points the bot at a fresh fake Bot API for the test.
+func startFakeBotAPI(t *testing.T) *fakebotapi.Server {
+	t.Helper()
+
+	api := fakebotapi.New()
+	services.SetTelegramClient(api.BotClient())
+	t.Cleanup(func() {
+		services.SetTelegramClient(nil)
+		api.Close()
+	})
+
+	return api
+}
+
+func TestPollingStopsOnConflict(t *testing.T) {
+	api := startFakeBotAPI(t)
+	api.FailNext("getUpdates", fakebotapi.Conflict)
+
+	dispatcher := services.NewUpdateDispatcher("unused", 1, 0)
+	defer dispatcher.Shutdown(context.Background())
+
+	offset, err := services.ProcessTelegramMessages(context.Background(), dispatcher, 0)
+	if err == nil || !strings.Contains(err.Error(), "409") {
+		t.Fatalf("expected a 409 conflict error, got %v", err)
+	}
+	if offset != 0 {
+		t.Errorf("offset moved to %d on a failed poll", offset)
+	}
+	if calls := len(api.Calls("getUpdates")); calls != 1 {
+		t.Errorf("a conflict must not be retried, got %d getUpdates calls", calls)
+	}
+}
+
+func TestPollingRetriesServerErrors(t *testing.T) {
+	api := startFakeBotAPI(t)
+	api.FailNext("getUpdates", fakebotapi.BadGateway)
+
+	dispatcher := services.NewUpdateDispatcher("unused", 1, 0)
+	defer dispatcher.Shutdown(context.Background())
+
+	if _, err := services.ProcessTelegramMessages(context.Background(), dispatcher, 0); err != nil {
+		t.Fatalf("expected the poll to recover from a 502, got %v", err)
+	}
+	if calls := len(api.Calls("getUpdates")); calls != 2 {
+		t.Errorf("expected one retry, got %d getUpdates calls", calls)
+	}
+}
+
+func TestSendRetriesAfterFloodWait(t *testing.T) {
+	api := startFakeBotAPI(t)
+	api.FailNext("sendMessage", fakebotapi.TooManyRequests)
+
+	if err := services.SendMessage(-100, "hello"); err != nil {
+		t.Fatalf("expected the send to succeed after the flood wait, got %v", err)
+	}
+	if texts := api.SentTexts(-100); len(texts) != 2 || texts[1] != "hello" {
+		t.Errorf("expected the message to be sent twice, got %q", texts)
+	}
+}
+
+func TestRegisterBotCommandsForEveryLanguage(t *testing.T) {
+	api := startFakeBotAPI(t)
+
+	if err := services.RegisterBotCommands(); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := api.Calls("setMyCommands")
+	if len(calls) != 4*len(i18n.Languages()) {
+		t.Fatalf("expected 4 scopes per language, got %d calls", len(calls))
+	}
+
+	spanish := 0
+	for _, call := range calls {
+		switch call.Params["language_code"] {
+		case nil:
+		case "es":
+			spanish++
+		default:
+			t.Errorf("unexpected language_code %v", call.Params["language_code"])
+		}
+	}
+	if spanish != 4 {
+		t.Errorf("expected 4 Spanish scopes, got %d", spanish)
+	}
+}
+
+func TestMagisteriumAnswersInHTML(t *testing.T) {
+	api := startFakeBotAPI(t)
+	magisterium := fakebotapi.NewMagisterium("**Grace** is a free gift.", fakebotapi.Citation{
+		DocumentTitle: "Catechism of the Catholic Church",
+		SourceURL:     "https://www.vatican.va/archive/ENG0015/__P6Y.HTM",
+	})
+	defer magisterium.Close()
+
+	previous := config.Current
+	config.Current.MagisteriumAPIKey = "test"
+	config.Current.MagisteriumAPIURL = magisterium.URL()
+	t.Cleanup(func() { config.Current = previous })
+
+	update := fakebotapi.TextMessage(fakebotapi.Group(-200), fakebotapi.User(1, "Ana"), "/ask_catholic_church What is grace?")
+	if err := services.AskCatholicChurchFromCommand(update, "What is grace?", "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	if questions := magisterium.Questions(); len(questions) != 1 || questions[0] != "What is grace?" {
+		t.Errorf("unexpected questions %q", questions)
+	}
+
+	calls := api.Calls("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("expected one reply, got %d", len(calls))
+	}
+	if calls[0].Params["parse_mode"] != "HTML" {
+		t.Errorf("expected an HTML reply, got parse_mode %v", calls[0].Params["parse_mode"])
+	}
+	if text := calls[0].Text(); !strings.HasPrefix(text, "<b>Grace</b> is a free gift.") || !strings.Contains(text, "Catechism") {
+		t.Errorf("unexpected answer %q", text)
+	}
+}
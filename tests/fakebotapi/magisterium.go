@@ -0,0 +1,83 @@
+package fakebotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Citation is one source the fake Magisterium API cites.
+type Citation struct {
+	DocumentTitle     string `json:"document_title"`
+	DocumentReference string `json:"document_reference,omitempty"`
+	SourceURL         string `json:"source_url,omitempty"`
+}
+
+// Magisterium fakes the Magisterium chat completions API with a fixed answer.
+type Magisterium struct {
+	Answer    string
+	Citations []Citation
+	// Status, when set, is returned instead of the answer.
+	Status int
+
+	http *httptest.Server
+
+	mu        sync.Mutex
+	questions []string
+}
+
+func NewMagisterium(answer string, citations ...Citation) *Magisterium {
+	m := &Magisterium{Answer: answer, Citations: citations}
+	m.http = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+
+	return m
+}
+
+func (m *Magisterium) Close() {
+	m.http.Close()
+}
+
+// URL is what config.Env.MagisteriumAPIURL would be for this server.
+func (m *Magisterium) URL() string {
+	return m.http.URL + "/api/v1/chat/completions"
+}
+
+// Questions returns the questions asked so far.
+func (m *Magisterium) Questions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.questions...)
+}
+
+func (m *Magisterium) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	for _, message := range request.Messages {
+		m.questions = append(m.questions, message.Content)
+	}
+	m.mu.Unlock()
+
+	if m.Status != 0 && m.Status != http.StatusOK {
+		http.Error(w, http.StatusText(m.Status), m.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"choices": []map[string]any{{
+			"message": map[string]string{"role": "assistant", "content": m.Answer},
+		}},
+		"citations": m.Citations,
+	})
+}
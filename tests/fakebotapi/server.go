@@ -0,0 +1,309 @@
+// Package fakebotapi is an in-process stand-in for the Telegram Bot API. It
+// serves scripted updates, records every call the bot makes and can fail
+// methods on demand, so scenario tests run without the network.
+package fakebotapi
+
+import (
+	"bot/telegram/botapi"
+	"bot/telegram/structs"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token is the bot token the fake expects in request paths.
+const Token = "123456:TEST"
+
+// Call is one request the bot made.
+type Call struct {
+	Method string
+	Params map[string]any
+	Raw    json.RawMessage
+}
+
+// ChatID returns the chat_id parameter, or 0 when the call has none.
+func (c Call) ChatID() int64 {
+	value, _ := c.Params["chat_id"].(float64)
+	return int64(value)
+}
+
+// Text returns the text parameter.
+func (c Call) Text() string {
+	value, _ := c.Params["text"].(string)
+	return value
+}
+
+// Failure is an error answer for one call, shaped like Telegram's.
+type Failure struct {
+	Code            int
+	Description     string
+	RetryAfter      int
+	MigrateToChatID int64
+}
+
+// Common failures.
+var (
+	TooManyRequests = Failure{Code: http.StatusTooManyRequests, Description: "Too Many Requests: retry after 1", RetryAfter: 1}
+	Conflict        = Failure{Code: http.StatusConflict, Description: "Conflict: terminated by other getUpdates request"}
+	BadGateway      = Failure{Code: http.StatusBadGateway, Description: "Bad Gateway"}
+	BotBlocked      = Failure{Code: http.StatusForbidden, Description: "Forbidden: bot was blocked by the user"}
+)
+
+// Server is the fake Bot API. Create it with New and Close it when done.
+type Server struct {
+	// Bot is what getMe returns.
+	Bot structs.User
+
+	http *httptest.Server
+
+	mu            sync.Mutex
+	updates       []structs.Update
+	calls         []Call
+	failures      map[string][]Failure
+	admins        map[int64][]int64
+	nextMessageID int
+}
+
+func New() *Server {
+	s := &Server{
+		Bot:           structs.User{ID: 123456, IsBot: true, FirstName: "Test Bot", Username: "test_karma_bot"},
+		failures:      make(map[string][]Failure),
+		admins:        make(map[int64][]int64),
+		nextMessageID: 1000,
+	}
+	s.http = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+func (s *Server) Close() {
+	s.http.Close()
+}
+
+// BaseURL is what config.Env.TelegramBaseURL would be for this server.
+func (s *Server) BaseURL() string {
+	return s.http.URL + "/bot"
+}
+
+// BotClient returns a client for the fake. It has no rate limiter, so tests
+// don't wait between sends.
+func (s *Server) BotClient() *botapi.Client {
+	return botapi.NewClient(s.BaseURL(), Token, s.http.Client())
+}
+
+// QueueUpdates adds updates for getUpdates to return.
+func (s *Server) QueueUpdates(updates ...structs.Update) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updates = append(s.updates, updates...)
+}
+
+// FailNext makes the next calls to method fail, one failure per call, before
+// it answers normally again.
+func (s *Server) FailNext(method string, failures ...Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[method] = append(s.failures[method], failures...)
+}
+
+// SetAdministrators sets who getChatAdministrators reports for chatID. The
+// bot's own ID is reported as the bot.
+func (s *Server) SetAdministrators(chatID int64, userIDs ...int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.admins[chatID] = userIDs
+}
+
+// Calls returns the recorded calls to method, or every call when method is "".
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, call := range s.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// SentTexts returns the texts of the messages sent to chatID, in order.
+func (s *Server) SentTexts(chatID int64) []string {
+	var texts []string
+	for _, call := range s.Calls("sendMessage") {
+		if call.ChatID() == chatID {
+			texts = append(texts, call.Text())
+		}
+	}
+
+	return texts
+}
+
+// Reset forgets recorded calls, queued updates and pending failures.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updates = nil
+	s.calls = nil
+	s.failures = make(map[string][]Failure)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := "/bot" + Token + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeError(w, Failure{Code: http.StatusUnauthorized, Description: "Unauthorized"})
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, prefix)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, Failure{Code: http.StatusBadRequest, Description: err.Error()})
+		return
+	}
+
+	params := map[string]any{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &params); err != nil {
+			writeError(w, Failure{Code: http.StatusBadRequest, Description: "Bad Request: can't parse JSON"})
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: method, Params: params, Raw: body})
+	if pending := s.failures[method]; len(pending) > 0 {
+		s.failures[method] = pending[1:]
+		s.mu.Unlock()
+		writeError(w, pending[0])
+		return
+	}
+	s.mu.Unlock()
+
+	switch method {
+	case "getUpdates":
+		writeResult(w, s.pendingUpdates(params))
+	case "getMe":
+		writeResult(w, s.Bot)
+	case "sendMessage":
+		writeResult(w, s.sentMessage(params))
+	case "editMessageText":
+		writeResult(w, s.sentMessage(params))
+	case "getChatAdministrators":
+		writeResult(w, s.administrators(params))
+	default:
+		// setMyCommands, answerCallbackQuery, setWebhook and the like only
+		// answer true.
+		writeResult(w, true)
+	}
+}
+
+// pendingUpdates drops the updates getUpdates' offset confirms and returns the
+// rest, like Telegram does.
+func (s *Server) pendingUpdates(params map[string]any) []structs.Update {
+	offset, _ := params["offset"].(float64)
+	limit, _ := params["limit"].(float64)
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remaining := s.updates[:0]
+	for _, update := range s.updates {
+		if float64(update.UpdateID) >= offset {
+			remaining = append(remaining, update)
+		}
+	}
+	s.updates = remaining
+
+	result := make([]structs.Update, 0, len(remaining))
+	for i := 0; i < len(remaining) && i < int(limit); i++ {
+		result = append(result, remaining[i])
+	}
+
+	return result
+}
+
+func (s *Server) sentMessage(params map[string]any) structs.Message {
+	s.mu.Lock()
+	s.nextMessageID++
+	messageID := s.nextMessageID
+	s.mu.Unlock()
+
+	chatID, _ := params["chat_id"].(float64)
+	text, _ := params["text"].(string)
+	if id, ok := params["message_id"].(float64); ok {
+		messageID = int(id)
+	}
+
+	return structs.Message{
+		MessageID: messageID,
+		Date:      int(time.Now().Unix()),
+		Chat:      structs.Chat{ID: int64(chatID)},
+		From:      &s.Bot,
+		Text:      text,
+	}
+}
+
+func (s *Server) administrators(params map[string]any) []structs.ChatMember {
+	chatID, _ := params["chat_id"].(float64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := make([]structs.ChatMember, 0, len(s.admins[int64(chatID)]))
+	for i, userID := range s.admins[int64(chatID)] {
+		status := "administrator"
+		if i == 0 {
+			status = "creator"
+		}
+		user := structs.User{ID: userID, FirstName: fmt.Sprintf("Admin %d", userID)}
+		if userID == s.Bot.ID {
+			user = s.Bot
+		}
+		members = append(members, structs.ChatMember{Status: status, User: &user})
+	}
+
+	return members
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func writeError(w http.ResponseWriter, failure Failure) {
+	response := map[string]any{
+		"ok":          false,
+		"error_code":  failure.Code,
+		"description": failure.Description,
+	}
+
+	parameters := map[string]any{}
+	if failure.RetryAfter > 0 {
+		parameters["retry_after"] = failure.RetryAfter
+	}
+	if failure.MigrateToChatID != 0 {
+		parameters["migrate_to_chat_id"] = failure.MigrateToChatID
+	}
+	if len(parameters) > 0 {
+		response["parameters"] = parameters
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(failure.Code)
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,78 @@
+package fakebotapi
+
+import (
+	"bot/telegram/structs"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	idsMu         sync.Mutex
+	lastUpdateID  = 1000
+	lastMessageID = 1
+)
+
+func nextIDs() (int, int) {
+	idsMu.Lock()
+	defer idsMu.Unlock()
+
+	lastUpdateID++
+	lastMessageID++
+	return lastUpdateID, lastMessageID
+}
+
+// Group returns a group chat.
+func Group(chatID int64) structs.Chat {
+	return structs.Chat{ID: chatID, Type: "supergroup", Title: "Test group"}
+}
+
+// User returns a human user.
+func User(userID int64, firstName string) *structs.User {
+	return &structs.User{ID: userID, FirstName: firstName, Username: strings.ToLower(firstName), LanguageCode: "en"}
+}
+
+// TextMessage returns an update with a text message from user in chat. A
+// leading /command gets its bot_command entity, as Telegram would send it.
+func TextMessage(chat structs.Chat, from *structs.User, text string) structs.Update {
+	updateID, messageID := nextIDs()
+	message := &structs.Message{
+		MessageID: messageID,
+		From:      from,
+		Date:      int(time.Now().Unix()),
+		Chat:      chat,
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		message.Entities = []structs.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+
+	return structs.Update{UpdateID: updateID, Message: message}
+}
+
+// Reply returns an update with a text message answering original.
+func Reply(original structs.Update, from *structs.User, text string) structs.Update {
+	update := TextMessage(original.Message.Chat, from, text)
+	update.Message.ReplyToMessage = original.Message
+
+	return update
+}
+
+// ButtonPress returns an update for a press of the inline button carrying data
+// under message, which is usually one the bot sent.
+func ButtonPress(message structs.Message, from *structs.User, data string) structs.Update {
+	updateID, _ := nextIDs()
+
+	return structs.Update{
+		UpdateID: updateID,
+		CallbackQuery: &structs.CallbackQuery{
+			ID:           fmt.Sprintf("cb%d", updateID),
+			From:         from,
+			Message:      &message,
+			ChatInstance: "test",
+			Data:         data,
+		},
+	}
+}
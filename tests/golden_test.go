@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bot/telegram/config"
+	"bot/telegram/services"
+	"bot/telegram/structs"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Golden fixtures live in testdata/golden as pairs of files:
+//
+//	<name>.input.json   what Telegram sends: a getUpdates payload, the chat
+//	                    administrators and the table columns to compare
+//	<name>.golden.json  what the bot did: every Bot API call it made and the
+//	                    listed columns of the listed tables afterwards
+//
+// Re-record the golden files after an intended behaviour change with
+//
+//	TEST_DB_NAME=telegram_bot_test go test ./tests -run TestGoldenFixtures -update
+//
+// and review the diff.
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+const goldenDir = "testdata/golden"
+
+type goldenInput struct {
+	Description string `json:"description,omitempty"`
+	// Administrators maps a chat ID to the user IDs getChatAdministrators
+	// reports; the first one is the creator.
+	Administrators map[string][]int64 `json:"administrators,omitempty"`
+	// Updates has the shape getUpdates returns, so real payloads can be
+	// pasted in as they are.
+	Updates structs.UpdateResponse `json:"updates"`
+	// Tables lists the columns to snapshot per table. Leave out columns that
+	// change from run to run, like serial IDs of unrelated rows or timestamps.
+	Tables map[string][]string `json:"tables,omitempty"`
+}
+
+type goldenOutput struct {
+	Calls []goldenCall                `json:"calls"`
+	DB    map[string][]map[string]any `json:"db"`
+}
+
+type goldenCall struct {
+	Method string         `json:"method"`
+	Params map[string]any `json:"params"`
+}
+
+func TestGoldenFixtures(t *testing.T) {
+	inputs := goldenInputs(t)
+	pool := openTestDatabase(t)
+
+	for _, path := range inputs {
+		name := strings.TrimSuffix(filepath.Base(path), ".input.json")
+		t.Run(name, func(t *testing.T) {
+			input := readGoldenInput(t, path)
+			got := marshalGolden(t, runGoldenFixture(t, pool, input))
+
+			goldenPath := filepath.Join(goldenDir, name+".golden.json")
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (record it with -update)", err)
+			}
+			if !bytes.Equal(bytes.TrimSpace(want), bytes.TrimSpace(got)) {
+				t.Errorf("%s differs from the recorded behaviour (- want, + got):\n%s", goldenPath, lineDiff(string(want), string(got)))
+			}
+		})
+	}
+}
+
+// TestGoldenFixturesAreWellFormed runs without a database, so broken fixtures
+// show up even where the golden tests are skipped.
+func TestGoldenFixturesAreWellFormed(t *testing.T) {
+	for _, path := range goldenInputs(t) {
+		input := readGoldenInput(t, path)
+		if len(input.Updates.Result) == 0 {
+			t.Errorf("%s has no updates", path)
+		}
+		for chat := range input.Administrators {
+			if _, err := strconv.ParseInt(chat, 10, 64); err != nil {
+				t.Errorf("%s: administrators key %q is not a chat ID", path, chat)
+			}
+		}
+
+		goldenPath := strings.TrimSuffix(path, ".input.json") + ".golden.json"
+		data, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Errorf("%s has no golden file: %v", path, err)
+			continue
+		}
+		var output goldenOutput
+		if err := json.Unmarshal(data, &output); err != nil {
+			t.Errorf("%s: %v", goldenPath, err)
+		}
+	}
+}
+
+func goldenInputs(t *testing.T) []string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(goldenDir, "*.input.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no fixtures in %s", goldenDir)
+	}
+
+	return paths
+}
+
+func readGoldenInput(t *testing.T, path string) goldenInput {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var input goldenInput
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+
+	return input
+}
+
+// runGoldenFixture starts from empty tables, feeds the updates through the
+// polling loop and the update dispatcher, and collects what the bot did.
+func runGoldenFixture(t *testing.T, pool *pgxpool.Pool, input goldenInput) goldenOutput {
+	t.Helper()
+
+	ctx := context.Background()
+	resetTestTables(t, pool)
+
+	api := startFakeBotAPI(t)
+	for chat, userIDs := range input.Administrators {
+		chatID, _ := strconv.ParseInt(chat, 10, 64)
+		api.SetAdministrators(chatID, userIDs...)
+	}
+	api.QueueUpdates(input.Updates.Result...)
+
+	lastUpdateID := 0
+	for _, update := range input.Updates.Result {
+		lastUpdateID = max(lastUpdateID, update.UpdateID)
+	}
+
+	// One worker keeps the calls of different chats in a stable order.
+	dispatcher := services.NewUpdateDispatcher(config.Current.DBName, 1, 0)
+	deadline := time.Now().Add(30 * time.Second)
+	for offset := 0; offset <= lastUpdateID; {
+		var err error
+		offset, err = services.ProcessTelegramMessages(ctx, dispatcher, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("updates were not processed in time, stuck at offset %d", offset)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := dispatcher.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	output := goldenOutput{Calls: []goldenCall{}, DB: map[string][]map[string]any{}}
+	for _, call := range api.Calls("") {
+		if call.Method == "getUpdates" {
+			continue
+		}
+		output.Calls = append(output.Calls, goldenCall{Method: call.Method, Params: call.Params})
+	}
+	for table, columns := range input.Tables {
+		output.DB[table] = snapshotTable(t, pool, table, columns)
+	}
+
+	return output
+}
+
+// resetTestTables empties every table the migrations don't own, and restarts
+// their sequences so serial IDs are the same on every run.
+func resetTestTables(t *testing.T, pool *pgxpool.Pool) {
+	t.Helper()
+
+	ctx := context.Background()
+	rows, err := pool.Query(ctx, `
+		SELECT tablename FROM pg_tables
+		WHERE schemaname = current_schema()
+			AND tablename NOT IN ('schema_migrations', 'bot_update_offset')
+		ORDER BY tablename
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (string, error) {
+		var table string
+		err := row.Scan(&table)
+		return pgx.Identifier{table}.Sanitize(), err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pool.Exec(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func snapshotTable(t *testing.T, pool *pgxpool.Pool, table string, columns []string) []map[string]any {
+	t.Helper()
+
+	quoted := make([]string, len(columns))
+	ordered := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = pgx.Identifier{column}.Sanitize()
+		ordered[i] = "t." + quoted[i]
+	}
+
+	sql := fmt.Sprintf(
+		"SELECT to_jsonb(t) FROM (SELECT %s FROM %s) t ORDER BY %s",
+		strings.Join(quoted, ", "),
+		pgx.Identifier{table}.Sanitize(),
+		strings.Join(ordered, ", "),
+	)
+	rows, err := pool.Query(context.Background(), sql)
+	if err != nil {
+		t.Fatalf("snapshot %s: %v", table, err)
+	}
+
+	snapshot, err := pgx.CollectRows(rows, pgx.RowTo[map[string]any])
+	if err != nil {
+		t.Fatalf("snapshot %s: %v", table, err)
+	}
+	if snapshot == nil {
+		snapshot = []map[string]any{}
+	}
+
+	return snapshot
+}
+
+// marshalGolden writes the output with sorted keys and unescaped HTML, so the
+// golden files read like the messages users see.
+func marshalGolden(t *testing.T, output goldenOutput) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(output); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+// lineDiff lists the lines only in want with "-" and the lines only in got
+// with "+", around their longest common subsequence.
+func lineDiff(want string, got string) string {
+	a := strings.Split(strings.TrimSpace(want), "\n")
+	b := strings.Split(strings.TrimSpace(got), "\n")
+
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out = append(out, "  "+a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || common[i][j+1] >= common[i+1][j]):
+			out = append(out, "+ "+b[j])
+			j++
+		default:
+			out = append(out, "- "+a[i])
+			i++
+		}
+	}
+
+	return strings.Join(trimDiffContext(out, 3), "\n")
+}
+
+// trimDiffContext keeps the changed lines and context lines around them.
+func trimDiffContext(lines []string, around int) []string {
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if strings.HasPrefix(line, "  ") {
+			continue
+		}
+		for j := max(0, i-around); j <= min(len(lines)-1, i+around); j++ {
+			keep[j] = true
+		}
+	}
+
+	var out []string
+	skipped := false
+	for i, line := range lines {
+		if !keep[i] {
+			skipped = true
+			continue
+		}
+		if skipped && len(out) > 0 {
+			out = append(out, "  ...")
+		}
+		skipped = false
+		out = append(out, line)
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bot/telegram/i18n"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+
+func placeholders(message string) string {
+	found := placeholderPattern.FindAllString(message, -1)
+	sort.Strings(found)
+	return strings.Join(found, ",")
+}
+
+func TestCatalogsHaveTheSameKeysAndPlaceholders(t *testing.T) {
+	for _, language := range i18n.Languages() {
+		for _, key := range i18n.Keys(i18n.DefaultLanguage) {
+			want := i18n.Forms(i18n.DefaultLanguage, key)
+			got := i18n.Forms(language, key)
+			if len(got) != len(want) {
+				t.Errorf("%s: %s has %d forms, want %d", language, key, len(got), len(want))
+				continue
+			}
+			for i := range want {
+				if placeholders(got[i]) != placeholders(want[i]) {
+					t.Errorf("%s: %s uses %q, want %q", language, key, placeholders(got[i]), placeholders(want[i]))
+				}
+			}
+		}
+
+		if extra := len(i18n.Keys(language)) - len(i18n.Keys(i18n.DefaultLanguage)); extra > 0 {
+			t.Errorf("%s has %d keys the default language lacks", language, extra)
+		}
+	}
+}
+
+func TestCatalogPluralsAndPlaceholders(t *testing.T) {
+	if got := i18n.N("es", "events.header", 1, nil); got != "1 evento activo:" {
+		t.Errorf("singular: got %q", got)
+	}
+	if got := i18n.N("es", "events.header", 3, nil); got != "3 eventos activos:" {
+		t.Errorf("plural: got %q", got)
+	}
+	if got := i18n.T("en", "karma.given", i18n.Args{"name": "Ana", "total": 5}); got != "Karma given to Ana. Total karma: 5" {
+		t.Errorf("placeholders: got %q", got)
+	}
+}
+
+func TestCatalogFallbacks(t *testing.T) {
+	if got := i18n.T("fr", "events.deleted", i18n.Args{"id": 7}); got != "Event #7 deleted." {
+		t.Errorf("unsupported language should fall back to English, got %q", got)
+	}
+	if got := i18n.T("es", "no.such.key", nil); got != "no.such.key" {
+		t.Errorf("missing key should return the key, got %q", got)
+	}
+
+	cases := map[string]string{"es-419": "es", "ES": "es", "en_US": "en", "fr": "", "": ""}
+	for code, want := range cases {
+		if got := i18n.Normalize(code); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
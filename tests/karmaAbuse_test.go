@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bot/telegram/services"
+	"bot/telegram/tests/fakebotapi"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReduceKarma(t *testing.T) {
+	cases := []struct {
+		value   int
+		percent int
+		want    float64
+	}{
+		{1, 50, 0.5},
+		{-1, 50, -0.5},
+		{1, 33, 0.33},
+		{-1, 1, -0.01},
+		{1, 100, 1},
+		{1, 0, 0},
+		{3, 50, 1.5},
+	}
+	for _, tc := range cases {
+		if got := services.ReduceKarma(tc.value, tc.percent); got != tc.want {
+			t.Errorf("ReduceKarma(%d, %d) = %v, want %v", tc.value, tc.percent, got, tc.want)
+		}
+	}
+}
+
+func TestKarmaAbuseRule(t *testing.T) {
+	defaults := services.KarmaSettings{AbusePairLimit: 3, AbuseBurstGivers: 3}
+	noPairs := services.KarmaSettings{AbusePairLimit: 0, AbuseBurstGivers: 3}
+	noBursts := services.KarmaSettings{AbusePairLimit: 3, AbuseBurstGivers: 0}
+
+	cases := []struct {
+		name     string
+		value    int
+		settings services.KarmaSettings
+		counts   services.KarmaAbuseCounts
+		want     string
+	}{
+		{"first karma", 1, defaults, services.KarmaAbuseCounts{}, ""},
+		{"under the pair limit", 1, defaults, services.KarmaAbuseCounts{Given: 2}, ""},
+		{"at the pair limit", 1, defaults, services.KarmaAbuseCounts{Given: 3}, "repeated"},
+		{"negative at the pair limit", -1, defaults, services.KarmaAbuseCounts{Given: 3}, "repeated"},
+		{"pair checks off", 1, noPairs, services.KarmaAbuseCounts{Given: 10, GivenBack: 10}, ""},
+		{"trading karma", 1, defaults, services.KarmaAbuseCounts{Given: 1, GivenBack: 2}, "reciprocal"},
+		{"one-sided trade", 1, defaults, services.KarmaAbuseCounts{Given: 1, GivenBack: 1}, ""},
+		{"trading only counts positive karma", -1, defaults, services.KarmaAbuseCounts{Given: 1, GivenBack: 2}, ""},
+		{"burst", -1, defaults, services.KarmaAbuseCounts{OtherBurstGivers: 2}, "burst"},
+		{"too few for a burst", -1, defaults, services.KarmaAbuseCounts{OtherBurstGivers: 1}, ""},
+		{"burst checks off", -1, noBursts, services.KarmaAbuseCounts{OtherBurstGivers: 5}, ""},
+		{"positive karma is never a burst", 1, defaults, services.KarmaAbuseCounts{OtherBurstGivers: 5}, ""},
+		{"burst comes first", -1, defaults, services.KarmaAbuseCounts{OtherBurstGivers: 2, Given: 3}, "burst"},
+		{"burst with pair checks off", -1, noPairs, services.KarmaAbuseCounts{OtherBurstGivers: 2}, "burst"},
+	}
+	for _, tc := range cases {
+		if got := services.KarmaAbuseRule(tc.value, tc.settings, tc.counts); got != tc.want {
+			t.Errorf("%s: KarmaAbuseRule(%d, %+v) = %q, want %q", tc.name, tc.value, tc.counts, got, tc.want)
+		}
+	}
+}
+
+func TestScenarioKarmaAbuse(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000010)
+	alma := fakebotapi.User(9000000023, "Alma")
+	ana := fakebotapi.User(9000000024, "Ana")
+	bob := fakebotapi.User(9000000025, "Bob")
+	carl := fakebotapi.User(9000000026, "Carl")
+	s.api.SetAdministrators(chat.ID, alma.ID, s.api.Bot.ID)
+
+	// The checks are off until a chat opts in.
+	s.send(fakebotapi.TextMessage(chat, alma, "/karma_settings abuse_pair_limit 1"))
+	fromBob := fakebotapi.TextMessage(chat, bob, "I fixed the build")
+	s.send(fromBob)
+	for i := 0; i < 2; i++ {
+		s.resetCooldowns(chat.ID)
+		s.send(fakebotapi.Reply(fromBob, ana, "+1"))
+	}
+	if flags := s.queryInt(`SELECT COUNT(*) FROM karma_abuse_flags WHERE chat_id = $1`, chat.ID); flags != 0 {
+		t.Errorf("expected no flags before opting in, got %d", flags)
+	}
+
+	s.send(fakebotapi.TextMessage(chat, alma, "/karma_settings abuse approve"))
+	s.resetCooldowns(chat.ID)
+	s.run(chat.ID, scenarioStep{fakebotapi.Reply(fromBob, ana, "+1"), "Karma for Bob is waiting for an admin to approve it"})
+	if karma := s.karma(chat.ID, bob.ID); karma != 2 {
+		t.Errorf("held karma was counted, Bob has %d", karma)
+	}
+	if text := s.lastSent(alma.ID).Text(); !strings.Contains(text, "Ana keeps giving Bob karma") {
+		t.Errorf("unexpected report to the admin %q", text)
+	}
+	for _, call := range s.api.Calls("sendMessage") {
+		if call.ChatID() == s.api.Bot.ID {
+			t.Errorf("the bot reported karma abuse to itself: %q", call.Text())
+		}
+	}
+
+	flagId := s.queryInt(`SELECT id FROM karma_abuse_flags WHERE chat_id = $1 AND status = 'pending'`, chat.ID)
+	s.run(chat.ID, scenarioStep{fakebotapi.TextMessage(chat, alma, fmt.Sprintf("/karma_approve %d", flagId)), "Bob now has 3 karma"})
+	s.send(fakebotapi.TextMessage(chat, alma, fmt.Sprintf("/karma_approve %d", flagId)))
+	if karma := s.karma(chat.ID, bob.ID); karma != 3 {
+		t.Errorf("expected the approved karma to count once, Bob has %d", karma)
+	}
+
+	// Two people taking karma from Bob at once is a burst; one person taking
+	// karma never is. Reducing only changes weighted karma, so it needs
+	// weighting on, and weighting can't go off while it is used.
+	s.run(chat.ID,
+		scenarioStep{fakebotapi.TextMessage(chat, alma, "/karma_settings abuse_burst_givers 1"), "abuse_burst_givers takes 0 to turn it off, or a number from 2 to 100"},
+		scenarioStep{fakebotapi.TextMessage(chat, alma, "/karma_settings abuse_burst_givers 2"), "abuse_burst_givers is now 2"},
+		scenarioStep{fakebotapi.TextMessage(chat, alma, "/karma_settings abuse reduce"), "abuse can only be reduce while weighting is on"},
+		scenarioStep{fakebotapi.TextMessage(chat, alma, "/karma_settings weighting on"), "weighting is now on"},
+		scenarioStep{fakebotapi.TextMessage(chat, alma, "/karma_settings abuse reduce"), "abuse is now reduce"},
+		scenarioStep{fakebotapi.TextMessage(chat, alma, "/karma_settings weighting off"), "weighting can't be turned off while abuse is reduce"},
+		scenarioStep{fakebotapi.TextMessage(chat, alma, "/karma_settings abuse ignore"), "abuse is now ignore"},
+	)
+	s.resetCooldowns(chat.ID)
+	s.send(fakebotapi.Reply(fromBob, carl, "-1"))
+	s.run(chat.ID, scenarioStep{fakebotapi.Reply(fromBob, ana, "-1"), "Karma for Bob wasn't counted"})
+	if karma := s.karma(chat.ID, bob.ID); karma != 2 {
+		t.Errorf("expected only Carl's -1 to count, Bob has %d", karma)
+	}
+
+	s.send(fakebotapi.TextMessage(chat, alma, "/karma_settings abuse reduce"))
+
+	// Reduced karma moves the count by one but adds only part of its
+	// weighted value, and the flag keeps the value karma_events has.
+	weightedBefore := s.weightedCents(chat.ID, bob.ID)
+	s.resetCooldowns(chat.ID)
+	s.run(chat.ID, scenarioStep{fakebotapi.Reply(fromBob, ana, "+1"), "weighted karma after a reduction. Total karma: 3"})
+	eventCents := s.queryInt(`
+		SELECT (weighted_value * 100)::int FROM karma_events
+		WHERE chat_id = $1 AND giver_user_id = $2 AND receiver_user_id = $3
+		ORDER BY id DESC LIMIT 1
+	`, chat.ID, ana.ID, bob.ID)
+	flagCents := s.queryInt(`SELECT (applied_value * 100)::int FROM karma_abuse_flags WHERE chat_id = $1 AND status = 'reduced'`, chat.ID)
+	if added := s.weightedCents(chat.ID, bob.ID) - weightedBefore; added != eventCents || flagCents != eventCents || eventCents <= 0 || eventCents >= 100 {
+		t.Errorf("expected a reduced share of one karma everywhere, Bob got %d hundredths, the ledger %d and the flag %d", added, eventCents, flagCents)
+	}
+
+	s.send(fakebotapi.TextMessage(chat, alma, "/karma_flags"))
+	text := s.lastSent(chat.ID).Text()
+	for _, want := range []string{"several people took karma from Bob at once", "not counted", "approved by Alma", "counted as +0."} {
+		if !strings.Contains(text, want) {
+			t.Errorf("flags %q do not contain %q", text, want)
+		}
+	}
+}
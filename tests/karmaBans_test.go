@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bot/telegram/tests/fakebotapi"
+	"strings"
+	"testing"
+)
+
+func TestScenarioKarmaBans(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000007)
+	admin := fakebotapi.User(9000000016, "Alma")
+	bob := fakebotapi.User(9000000017, "Bob")
+	s.api.SetAdministrators(chat.ID, admin.ID)
+
+	hello := fakebotapi.TextMessage(chat, bob, "hello")
+	s.send(hello)
+
+	s.run(chat.ID, scenarioStep{fakebotapi.Reply(hello, admin, "/karma_mute_receive 2h"), "Bob can't receive karma here until"})
+
+	s.send(fakebotapi.Reply(hello, admin, "+1"))
+	if karma := s.karma(chat.ID, bob.ID); karma != 0 {
+		t.Errorf("a muted user received karma: %d", karma)
+	}
+
+	// Once the end date passes the restriction no longer applies.
+	s.exec(`
+		UPDATE users_ranking SET receive_karma_blocked_until = CURRENT_TIMESTAMP - INTERVAL '1 minute', last_karma_given = NULL
+		WHERE group_id = $1
+	`, chat.ID)
+	s.send(fakebotapi.Reply(hello, admin, "+1"))
+	if karma := s.karma(chat.ID, bob.ID); karma != 1 {
+		t.Errorf("an expired mute still applied, karma %d", karma)
+	}
+
+	s.send(fakebotapi.Reply(hello, admin, "/karma_ban"))
+	s.run(chat.ID, scenarioStep{fakebotapi.TextMessage(chat, admin, "/karma_bans"), "Bob: can't give karma until an admin lifts it"})
+
+	s.send(fakebotapi.Reply(hello, admin, "/karma_unban"))
+	s.send(fakebotapi.TextMessage(chat, admin, "/karma_bans log"))
+	text := s.lastSent(chat.ID).Text()
+	for _, want := range []string{"Alma lifted Bob's karma restrictions", "Alma stopped Bob from giving karma", "Alma stopped Bob from receiving karma until"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("log %q does not contain %q", text, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bot/telegram/tests/fakebotapi"
+	"strings"
+	"testing"
+)
+
+func TestScenarioKarmaUndoAndRebuild(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000004)
+	ana := fakebotapi.User(9000000008, "Ana")
+	bob := fakebotapi.User(9000000009, "Bob")
+	s.api.SetAdministrators(chat.ID, ana.ID)
+
+	question := fakebotapi.TextMessage(chat, bob, "Who wants coffee?")
+	s.send(question)
+	s.send(fakebotapi.Reply(question, ana, "+1"))
+	if events := s.queryInt(`SELECT COUNT(*) FROM karma_events WHERE chat_id = $1 AND kind = 'karma'`, chat.ID); events != 1 {
+		t.Fatalf("expected 1 karma event, got %d", events)
+	}
+
+	s.send(fakebotapi.TextMessage(chat, ana, "/undo_karma"))
+	if karma := s.karma(chat.ID, bob.ID); karma != 0 {
+		t.Errorf("expected Bob's karma to be taken back, got %d", karma)
+	}
+	if given := s.queryInt(`SELECT karma_given FROM users_ranking WHERE group_id = $1 AND user_id = $2`, chat.ID, ana.ID); given != 0 {
+		t.Errorf("expected Ana's karma_given to be taken back, got %d", given)
+	}
+
+	// A second undo has nothing left to take back.
+	s.send(fakebotapi.TextMessage(chat, ana, "/undo_karma"))
+	if undos := s.queryInt(`SELECT COUNT(*) FROM karma_events WHERE chat_id = $1 AND kind = 'undo'`, chat.ID); undos != 1 {
+		t.Errorf("expected 1 undo event, got %d", undos)
+	}
+
+	s.exec(`UPDATE users_ranking SET karma = 42 WHERE group_id = $1 AND user_id = $2`, chat.ID, bob.ID)
+	s.send(fakebotapi.TextMessage(chat, ana, "/rebuild_karma"))
+	if karma := s.karma(chat.ID, bob.ID); karma != 0 {
+		t.Errorf("expected the rebuild to restore Bob's karma to 0, got %d", karma)
+	}
+
+	s.send(fakebotapi.TextMessage(chat, bob, "/karma_history"))
+	if text := s.lastSent(chat.ID).Text(); strings.Count(text, "Ana → Bob") != 2 {
+		t.Errorf("expected the karma and its undo in the history, got %q", text)
+	}
+}
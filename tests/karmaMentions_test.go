@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bot/telegram/structs"
+	"bot/telegram/tests/fakebotapi"
+	"strings"
+	"testing"
+)
+
+func TestScenarioKarmaByMention(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000003)
+	ana := fakebotapi.User(9000000005, "Ana")
+	bob := fakebotapi.User(9000000006, "Bob")
+	eve := &structs.User{ID: 9000000007, FirstName: "Eve"}
+
+	// Bob has to show up once before @bob can be resolved; Eve has no
+	// username and is tapped as a text mention.
+	s.send(fakebotapi.TextMessage(chat, bob, "hi all"))
+
+	update := fakebotapi.TextMessage(chat, ana, "+1 @bob @ghost Eve @BOB")
+	update.Message.Entities = []structs.MessageEntity{
+		{Type: "mention", Offset: 3, Length: 4},
+		{Type: "mention", Offset: 8, Length: 6},
+		{Type: "text_mention", Offset: 15, Length: 3, User: eve},
+		{Type: "mention", Offset: 19, Length: 4},
+	}
+	s.send(update)
+
+	for _, user := range []*structs.User{bob, eve} {
+		if karma := s.karma(chat.ID, user.ID); karma != 1 {
+			t.Errorf("expected %s to have 1 karma, got %d", user.FirstName, karma)
+		}
+	}
+	if given := s.queryInt(`SELECT karma_given FROM users_ranking WHERE group_id = $1 AND user_id = $2`, chat.ID, ana.ID); given != 2 {
+		t.Errorf("expected Ana to have given karma twice, got %d", given)
+	}
+
+	texts := s.api.SentTexts(chat.ID)
+	if len(texts) != 2 || !strings.Contains(texts[0], "@ghost") || !strings.Contains(texts[1], "Bob") || !strings.Contains(texts[1], "Eve") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
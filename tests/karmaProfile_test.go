@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bot/telegram/tests/fakebotapi"
+	"strings"
+	"testing"
+)
+
+func TestScenarioKarmaProfileWithTies(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000005)
+	ana := fakebotapi.User(9000000010, "Ana")
+	bob := fakebotapi.User(9000000011, "Bob")
+	carl := fakebotapi.User(9000000012, "Carl")
+	dan := fakebotapi.User(9000000013, "Dan")
+
+	fromBob := fakebotapi.TextMessage(chat, bob, "I fixed the printer")
+	fromDan := fakebotapi.TextMessage(chat, dan, "I brought cake")
+	s.send(fromBob)
+	s.send(fromDan)
+	s.send(fakebotapi.Reply(fromBob, ana, "+1"))
+	s.send(fakebotapi.Reply(fromDan, carl, "+1"))
+
+	s.send(fakebotapi.Reply(fromBob, dan, "/karma"))
+	text := s.lastSent(chat.ID).Text()
+	for _, want := range []string{"Bob has 1 karma", "#1 of 4, tied with 1 other", "Ana (+1)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("profile %q does not contain %q", text, want)
+		}
+	}
+
+	s.send(fakebotapi.TextMessage(chat, ana, "/karma"))
+	if text := s.lastSent(chat.ID).Text(); !strings.Contains(text, "#3 of 4") || !strings.Contains(text, "Karma given: 1") {
+		t.Errorf("unexpected profile for Ana %q", text)
+	}
+}
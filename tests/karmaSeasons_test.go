@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bot/telegram/services"
+	"bot/telegram/shared"
+	"bot/telegram/tests/fakebotapi"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCarryKarma(t *testing.T) {
+	cases := []struct {
+		karma        int
+		weighted     float64
+		percent      int
+		wantKarma    int
+		wantWeighted float64
+	}{
+		{10, 10, 50, 5, 5},
+		{10, 10, 100, 10, 10},
+		{10, 10, 0, 0, 0},
+		// Raw karma is cut towards zero, on both sides.
+		{7, 7, 50, 3, 3.5},
+		{-7, -7, 50, -3, -3.5},
+		{1, 0.25, 33, 0, 0.08},
+		{-1, -0.25, 33, 0, -0.08},
+		{0, 2.07, 50, 0, 1.04},
+		{250, 412.5, 10, 25, 41.25},
+	}
+	for _, tc := range cases {
+		karma, weighted := shared.CarryKarma(tc.karma, tc.weighted, tc.percent)
+		if karma != tc.wantKarma || weighted != tc.wantWeighted {
+			t.Errorf("CarryKarma(%d, %v, %d) = %d, %v, want %d, %v", tc.karma, tc.weighted, tc.percent, karma, weighted, tc.wantKarma, tc.wantWeighted)
+		}
+	}
+}
+
+func TestScenarioKarmaSeasons(t *testing.T) {
+	s := newScenario(t)
+	ctx := context.Background()
+	chat := fakebotapi.Group(-1009000000008)
+	ana := fakebotapi.User(9000000018, "Ana")
+	bob := fakebotapi.User(9000000019, "Bob")
+	s.api.SetAdministrators(chat.ID, ana.ID)
+
+	hello := fakebotapi.TextMessage(chat, bob, "hello")
+	s.send(hello)
+	s.send(fakebotapi.Reply(hello, ana, "+1"))
+
+	// Bob also had karma from before the ledger.
+	s.exec(`INSERT INTO karma_opening_balances (chat_id, user_id, karma) VALUES ($1, $2, 9)`, chat.ID, bob.ID)
+	s.exec(`UPDATE users_ranking SET karma = 10 WHERE group_id = $1 AND user_id = $2`, chat.ID, bob.ID)
+
+	s.send(fakebotapi.TextMessage(chat, ana, "/karma_settings season_carry 50"))
+	s.run(chat.ID, scenarioStep{fakebotapi.TextMessage(chat, bob, "/season end"), "Only group admins can end the season"})
+
+	s.send(fakebotapi.TextMessage(chat, ana, "/season end"))
+	text := s.lastSent(chat.ID).Text()
+	for _, want := range []string{"Karma season 1 is over", "🥇 Bob — 10", "keeps 50% of their karma into season 2"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("announcement %q does not contain %q", text, want)
+		}
+	}
+	if karma := s.karma(chat.ID, bob.ID); karma != 5 {
+		t.Errorf("expected Bob to carry 5 karma into season 2, got %d", karma)
+	}
+
+	// The ledger agrees with the carried karma.
+	s.send(fakebotapi.TextMessage(chat, ana, "/rebuild_karma"))
+	if karma := s.karma(chat.ID, bob.ID); karma != 5 {
+		t.Errorf("expected the rebuild to keep Bob's 5 karma, got %d", karma)
+	}
+
+	// A monthly season that started two months ago is due.
+	s.send(fakebotapi.TextMessage(chat, ana, "/karma_settings season monthly"))
+	s.exec(`UPDATE karma_seasons SET started_at = NOW() - INTERVAL '62 days' WHERE chat_id = $1 AND ended_at IS NULL`, chat.ID)
+	if err := services.ProcessDueKarmaSeasons(ctx, s.tx); err != nil {
+		t.Fatal(err)
+	}
+	if text := s.lastSent(chat.ID).Text(); !strings.Contains(text, "Karma season 2 is over") {
+		t.Errorf("unexpected scheduled announcement %q", text)
+	}
+	if open := s.queryInt(`SELECT number FROM karma_seasons WHERE chat_id = $1 AND ended_at IS NULL`, chat.ID); open != 3 {
+		t.Errorf("expected season 3 to be open, got %d", open)
+	}
+
+	s.send(fakebotapi.TextMessage(chat, bob, "/season_history"))
+	text = s.lastSent(chat.ID).Text()
+	for _, want := range []string{"Champions of the last 2 seasons", "Bob (10)", "Bob (5)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("history %q does not contain %q", text, want)
+		}
+	}
+}
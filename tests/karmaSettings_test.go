@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bot/telegram/tests/fakebotapi"
+	"strings"
+	"testing"
+)
+
+func TestScenarioKarmaSettings(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000006)
+	admin := fakebotapi.User(9000000014, "Alma")
+	bob := fakebotapi.User(9000000015, "Bob")
+	s.api.SetAdministrators(chat.ID, admin.ID)
+
+	s.run(chat.ID,
+		// Only admins change settings.
+		scenarioStep{fakebotapi.TextMessage(chat, bob, "/karma_settings negative off"), "Only group admins"},
+		scenarioStep{fakebotapi.TextMessage(chat, admin, "/karma_settings negative off"), "negative is now off"},
+		scenarioStep{fakebotapi.TextMessage(chat, admin, "/karma_settings cooldown 0"), "cooldown is now 0"},
+		scenarioStep{fakebotapi.TextMessage(chat, admin, "/karma_settings daily_cap 1"), "daily_cap is now 1"},
+		scenarioStep{fakebotapi.TextMessage(chat, admin, "/karma_settings cooldown -5"), "cooldown takes a number from 0 to 86400"},
+		scenarioStep{fakebotapi.TextMessage(chat, admin, "/karma_settings negative maybe"), "negative takes on or off"},
+	)
+	s.send(fakebotapi.TextMessage(chat, admin, "/karma_settings"))
+	text := s.lastSent(chat.ID).Text()
+	for _, want := range []string{"negative = off", "cooldown = 0", "daily_cap = 1"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("settings %q do not contain %q", text, want)
+		}
+	}
+
+	hello := fakebotapi.TextMessage(chat, bob, "hello")
+	s.send(hello)
+	s.run(chat.ID, scenarioStep{fakebotapi.Reply(hello, admin, "-1"), "Negative karma is turned off"})
+
+	s.send(fakebotapi.Reply(hello, admin, "+1"))
+	s.send(fakebotapi.Reply(hello, admin, "+1"))
+	if karma := s.karma(chat.ID, bob.ID); karma != 1 {
+		t.Errorf("expected the daily cap to stop at 1 karma, got %d", karma)
+	}
+}
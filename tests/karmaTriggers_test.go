@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bot/telegram/shared"
+	"bot/telegram/structs"
+	"bot/telegram/tests/fakebotapi"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMatchKarmaTrigger(t *testing.T) {
+	triggers := []structs.KarmaTrigger{
+		{ID: 1, Kind: structs.KarmaTriggerToken, Pattern: "++", Position: structs.KarmaTriggerStart, Value: 1},
+		{ID: 2, Kind: structs.KarmaTriggerKeyword, Pattern: "gracias, thanks", Position: structs.KarmaTriggerAnywhere, Value: 1},
+		{ID: 3, Kind: structs.KarmaTriggerEmoji, Pattern: "👎", Position: structs.KarmaTriggerAnywhere, Value: -1},
+		{ID: 4, Kind: structs.KarmaTriggerRegex, Pattern: `\+1!+`, Position: structs.KarmaTriggerWhole, Value: 2},
+	}
+
+	cases := []struct {
+		text  string
+		value int
+		ok    bool
+	}{
+		{"+1 good answer", 1, true},
+		{"-1", -1, true},
+		{"++ nice", 1, true},
+		{"nice ++", 0, false},
+		{"Thanks a lot!", 1, true},
+		{"muchas GRACIAS", 1, true},
+		{"happy thanksgiving", 0, false},
+		{"that's wrong 👎", -1, true},
+		{"+1!!", 2, true},
+		{"+1!! and more", 0, false},
+		{"hello", 0, false},
+		{"", 0, false},
+	}
+
+	for _, c := range cases {
+		trigger, ok := shared.MatchKarmaTrigger(c.text, triggers)
+		if ok != c.ok || trigger.Value != c.value {
+			t.Errorf("%q: got (%d, %t), want (%d, %t)", c.text, trigger.Value, ok, c.value, c.ok)
+		}
+	}
+}
+
+func TestValidateKarmaTrigger(t *testing.T) {
+	valid := []structs.KarmaTrigger{
+		{Kind: structs.KarmaTriggerToken, Pattern: "++", Position: structs.KarmaTriggerStart, Value: 1},
+		{Kind: structs.KarmaTriggerToken, Pattern: "--", Position: structs.KarmaTriggerWhole, Value: -1},
+		{Kind: structs.KarmaTriggerEmoji, Pattern: "👍", Position: structs.KarmaTriggerAnywhere, Value: shared.MaxKarmaTriggerValue},
+		{Kind: structs.KarmaTriggerKeyword, Pattern: "gracias, thank you", Position: structs.KarmaTriggerAnywhere, Value: -shared.MaxKarmaTriggerValue},
+		{Kind: structs.KarmaTriggerRegex, Pattern: `^\+\d+$`, Position: structs.KarmaTriggerWhole, Value: 2},
+		{Kind: structs.KarmaTriggerKeyword, Pattern: strings.Repeat("ñ", shared.MaxKarmaTriggerPattern), Position: structs.KarmaTriggerStart, Value: 1},
+	}
+	for _, trigger := range valid {
+		if err := shared.ValidateKarmaTrigger(trigger); err != nil {
+			t.Errorf("valid trigger %+v refused: %v", trigger, err)
+		}
+	}
+
+	cases := []struct {
+		trigger structs.KarmaTrigger
+		want    error
+	}{
+		{structs.KarmaTrigger{Kind: "word", Pattern: "++", Position: structs.KarmaTriggerStart, Value: 1}, shared.ErrKarmaTriggerKind},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerToken, Pattern: "++", Position: "end", Value: 1}, shared.ErrKarmaTriggerPosition},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerToken, Pattern: "++", Position: structs.KarmaTriggerStart, Value: 0}, shared.ErrKarmaTriggerValue},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerToken, Pattern: "++", Position: structs.KarmaTriggerStart, Value: 11}, shared.ErrKarmaTriggerValue},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerKeyword, Pattern: " ", Position: structs.KarmaTriggerStart, Value: 1}, shared.ErrKarmaTriggerPattern},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerToken, Pattern: "thank you", Position: structs.KarmaTriggerStart, Value: 1}, shared.ErrKarmaTriggerTokenSpaces},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerRegex, Pattern: "(+1", Position: structs.KarmaTriggerAnywhere, Value: 1}, shared.ErrKarmaTriggerRegex},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerToken, Pattern: "++", Position: structs.KarmaTriggerStart, Value: -11}, shared.ErrKarmaTriggerValue},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerEmoji, Pattern: "", Position: structs.KarmaTriggerAnywhere, Value: 1}, shared.ErrKarmaTriggerPattern},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerKeyword, Pattern: strings.Repeat("ñ", shared.MaxKarmaTriggerPattern+1), Position: structs.KarmaTriggerStart, Value: 1}, shared.ErrKarmaTriggerPattern},
+		{structs.KarmaTrigger{Kind: structs.KarmaTriggerToken, Pattern: "thanks\tmate", Position: structs.KarmaTriggerStart, Value: 1}, shared.ErrKarmaTriggerTokenSpaces},
+	}
+
+	for _, c := range cases {
+		if err := shared.ValidateKarmaTrigger(c.trigger); !errors.Is(err, c.want) {
+			t.Errorf("%+v: got %v, want %v", c.trigger, err, c.want)
+		}
+	}
+}
+
+func TestScenarioDuplicateKarmaTrigger(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000014)
+	alma := fakebotapi.User(9000000034, "Alma")
+	s.api.SetAdministrators(chat.ID, alma.ID)
+
+	for i, want := range []string{"Karma trigger #", "This group already has that karma trigger."} {
+		update := fakebotapi.TextMessage(chat, alma, "/add_karma_trigger token start 1 ++")
+		if status := s.process(update); status != "processed" {
+			t.Errorf("trigger %d: expected the update to be processed, got %q", i+1, status)
+		}
+		if text := s.lastSent(chat.ID).Text(); !strings.Contains(text, want) {
+			t.Errorf("trigger %d: unexpected reply %q", i+1, text)
+		}
+	}
+	if triggers := s.queryInt(`SELECT COUNT(*) FROM karma_triggers WHERE chat_id = $1`, chat.ID); triggers != 1 {
+		t.Errorf("expected one trigger, got %d", triggers)
+	}
+	// The rest of the duplicate's update was kept.
+	if members := s.queryInt(`SELECT COUNT(*) FROM chat_members WHERE chat_id = $1 AND user_id = $2`, chat.ID, alma.ID); members != 1 {
+		t.Errorf("expected Alma to be remembered as a member, got %d rows", members)
+	}
+}
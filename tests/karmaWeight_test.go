@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bot/telegram/shared"
+	"bot/telegram/tests/fakebotapi"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKarmaWeight(t *testing.T) {
+	const day = 24 * time.Hour
+
+	cases := []struct {
+		name        string
+		giverKarma  int
+		tenure      time.Duration
+		recentGiven int
+		want        float64
+	}{
+		{"settled member", 0, 30 * day, 0, 1},
+		{"newcomer", 0, 0, 0, 0.25},
+		{"half tenure", 0, 15 * day, 0, 0.5},
+		{"respected member", 100, 60 * day, 0, 2},
+		{"reputation is capped", 500, 60 * day, 0, 2},
+		{"disliked member", -80, 30 * day, 0, 0.5},
+		{"busy giver", 0, 30 * day, 10, 0.5},
+		{"busy respected member", 100, 30 * day, 1, 1.82},
+		{"floor", -200, 0, 100, 0.1},
+		// Each factor is clamped before they are multiplied.
+		{"reputation floor", -1000, 30 * day, 0, 0.5},
+		{"tenure cap", 0, 365 * day, 0, 1},
+		{"tenure floor", 0, 0, 0, 0.25},
+		{"clock skew", 0, -day, 0, 0.25},
+		{"negative recent giving", 0, 30 * day, -5, 1},
+		{"capped reputation, busy", 1000, 365 * day, 10, 1},
+		{"everything low", -1000, 0, 1000, 0.1},
+	}
+	for _, tc := range cases {
+		if got := shared.KarmaWeight(tc.giverKarma, tc.tenure, tc.recentGiven); got != tc.want {
+			t.Errorf("%s: KarmaWeight(%d, %v, %d) = %v, want %v", tc.name, tc.giverKarma, tc.tenure, tc.recentGiven, got, tc.want)
+		}
+	}
+}
+
+func TestWeighKarma(t *testing.T) {
+	cases := []struct {
+		value  int
+		weight float64
+		want   float64
+	}{
+		{1, 0.25, 0.25},
+		{-1, 1.82, -1.82},
+		{2, 0.333, 0.67},
+		{1, 1, 1},
+	}
+	for _, tc := range cases {
+		if got := shared.WeighKarma(tc.value, tc.weight); got != tc.want {
+			t.Errorf("WeighKarma(%d, %v) = %v, want %v", tc.value, tc.weight, got, tc.want)
+		}
+	}
+}
+
+func TestScenarioWeightedKarma(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000011)
+	alma := fakebotapi.User(9000000027, "Alma")
+	ana := fakebotapi.User(9000000028, "Ana")
+	bob := fakebotapi.User(9000000029, "Bob")
+	s.api.SetAdministrators(chat.ID, alma.ID)
+
+	s.send(fakebotapi.TextMessage(chat, alma, "/karma_settings weighting on"))
+
+	// Ana just arrived, so her karma counts for a quarter.
+	fromBob := fakebotapi.TextMessage(chat, bob, "I fixed the build")
+	s.send(fromBob)
+	s.run(chat.ID, scenarioStep{fakebotapi.Reply(fromBob, ana, "+1"), "Total karma: 1 (counted as +0.25 weighted)"})
+
+	// Two months in, with 100 karma and one karma given today, it counts
+	// for 2 / 1.1.
+	s.exec(`UPDATE chat_members SET first_seen_at = CURRENT_TIMESTAMP - INTERVAL '60 days' WHERE chat_id = $1 AND user_id = $2`, chat.ID, ana.ID)
+	s.exec(`UPDATE users_ranking SET karma = 100, last_karma_given = NULL WHERE group_id = $1 AND user_id = $2`, chat.ID, ana.ID)
+	s.run(chat.ID, scenarioStep{fakebotapi.Reply(fromBob, ana, "+1"), "Total karma: 2 (counted as +1.82 weighted)"})
+	if cents := s.weightedCents(chat.ID, bob.ID); cents != 207 {
+		t.Errorf("expected Bob to have 2.07 weighted karma, got %d hundredths", cents)
+	}
+
+	for command, want := range map[string]string{
+		"/lovedusers":               "Bob — 2\n",
+		"/lovedusers weighted":      "Bob — 2.07\n",
+		"/lovedusers week weighted": "Bob — 2.07\n",
+	} {
+		s.send(fakebotapi.TextMessage(chat, bob, command))
+		if text := s.lastSent(chat.ID).Text(); !strings.Contains(text, want) {
+			t.Errorf("%s: %q does not contain %q", command, text, want)
+		}
+	}
+	s.run(chat.ID, scenarioStep{fakebotapi.TextMessage(chat, bob, "/generoususers weighted"), "Only /lovedusers and /hatedusers can be weighted"})
+
+	// Undoing takes back what the karma was worth, and rebuilding from the
+	// ledger lands on the same weighted total.
+	s.send(fakebotapi.TextMessage(chat, ana, "/undo_karma"))
+	if cents := s.weightedCents(chat.ID, bob.ID); cents != 25 {
+		t.Errorf("expected Bob to have 0.25 weighted karma after the undo, got %d hundredths", cents)
+	}
+	s.exec(`UPDATE users_ranking SET weighted_karma = 0 WHERE group_id = $1`, chat.ID)
+	s.send(fakebotapi.TextMessage(chat, alma, "/rebuild_karma"))
+	if cents := s.weightedCents(chat.ID, bob.ID); cents != 25 {
+		t.Errorf("expected the rebuild to restore 0.25 weighted karma, got %d hundredths", cents)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bot/telegram/services"
+	"testing"
+	"time"
+)
+
+func TestParseLeaderboardOptions(t *testing.T) {
+	cases := []struct {
+		text string
+		want services.LeaderboardOptions
+		ok   bool
+	}{
+		{"", services.LeaderboardOptions{Window: "all", Limit: 10}, true},
+		{"week", services.LeaderboardOptions{Window: "week", Limit: 10}, true},
+		{"25 Month topic", services.LeaderboardOptions{Window: "month", Limit: 25, TopicOnly: true}, true},
+		{"topic weighted today 1", services.LeaderboardOptions{Window: "today", Limit: 1, TopicOnly: true, Weighted: true}, true},
+		{"year 50", services.LeaderboardOptions{Window: "year", Limit: 50}, true},
+		{"0", services.LeaderboardOptions{}, false},
+		{"51", services.LeaderboardOptions{}, false},
+		{"-3", services.LeaderboardOptions{}, false},
+		{"week month", services.LeaderboardOptions{}, false},
+		{"5 10", services.LeaderboardOptions{}, false},
+		{"topic topic", services.LeaderboardOptions{}, false},
+		{"fortnight", services.LeaderboardOptions{}, false},
+	}
+	for _, tc := range cases {
+		got, ok := services.ParseLeaderboardOptions(tc.text)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("ParseLeaderboardOptions(%q) = %+v, %v, want %+v, %v", tc.text, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestLeaderboardWindowStart(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	// A Saturday afternoon.
+	now := time.Date(2026, time.October, 17, 15, 4, 0, 0, time.UTC)
+
+	cases := []struct {
+		window string
+		at     time.Time
+		want   time.Time
+	}{
+		{"today", now, date(2026, time.October, 17)},
+		{"week", now, date(2026, time.October, 12)},
+		{"week", date(2026, time.October, 12), date(2026, time.October, 12)},
+		// Sunday still belongs to the week that began on Monday.
+		{"week", date(2026, time.October, 18), date(2026, time.October, 12)},
+		// A week can begin in the previous year.
+		{"week", date(2027, time.January, 1), date(2026, time.December, 28)},
+		{"month", now, date(2026, time.October, 1)},
+		{"year", now, date(2026, time.January, 1)},
+		// Just after midnight in UTC+2 is still the previous day in UTC.
+		{"today", time.Date(2026, time.October, 18, 1, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)), date(2026, time.October, 17)},
+	}
+	for _, tc := range cases {
+		got := services.LeaderboardWindowStart(tc.window, tc.at)
+		if got == nil || !got.Equal(tc.want) {
+			t.Errorf("LeaderboardWindowStart(%q, %v) = %v, want %v", tc.window, tc.at, got, tc.want)
+		}
+	}
+	if got := services.LeaderboardWindowStart("all", now); got != nil {
+		t.Errorf("LeaderboardWindowStart(\"all\", %v) = %v, want nil", now, got)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bot/telegram/tests/fakebotapi"
+	"strings"
+	"testing"
+)
+
+func TestScenarioLeaderboardWindows(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000009)
+	ana := fakebotapi.User(9000000020, "Ana")
+	bob := fakebotapi.User(9000000021, "Bob")
+	carl := fakebotapi.User(9000000022, "Carl")
+
+	fromBob := fakebotapi.TextMessage(chat, bob, "I brought cake")
+	s.send(fromBob)
+	s.send(fakebotapi.Reply(fromBob, ana, "+1"))
+
+	// Bob's karma is from over a year ago; Carl's is from today.
+	s.exec(`UPDATE karma_events SET created_at = NOW() - INTERVAL '400 days' WHERE chat_id = $1`, chat.ID)
+	s.resetCooldowns(chat.ID)
+	fromCarl := fakebotapi.TextMessage(chat, carl, "I ate the cake")
+	s.send(fromCarl)
+	s.send(fakebotapi.Reply(fromCarl, ana, "-1"))
+
+	boards := []struct {
+		command string
+		want    string
+	}{
+		{"/lovedusers week", "No karma was given in this period."},
+		{"/lovedusers all", "1) Bob — 1"},
+		{"/hatedusers today", "1) Carl — -1"},
+		{"/criticalusers today 5", "1) Ana — 1"},
+		{"/generoususers year", "No karma was given in this period."},
+		{"/generoususers", "1) Ana — 1"},
+		{"/lovedusers 51", "Usage:"},
+		{"/lovedusers week month", "Usage:"},
+	}
+	for _, board := range boards {
+		s.send(fakebotapi.TextMessage(chat, bob, board.command))
+		if text := s.lastSent(chat.ID).Text(); !strings.Contains(text, board.want) {
+			t.Errorf("%s: %q does not contain %q", board.command, text, board.want)
+		}
+	}
+}
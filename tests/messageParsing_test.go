@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bot/telegram/services"
+	"bot/telegram/structs"
+	"testing"
+)
+
+func TestParseMessageUsesUTF16Offsets(t *testing.T) {
+	// The emoji takes two UTF-16 code units, so the mention starts at 11
+	// although it is the tenth rune and the fifteenth byte.
+	ana := &structs.User{ID: 7, FirstName: "Ana"}
+	message := &structs.Message{
+		Text: "/karma@Test_Karma_Bot 🎂 @bob and Ana rock",
+		Entities: []structs.MessageEntity{
+			{Type: "bot_command", Offset: 0, Length: 21},
+			{Type: "mention", Offset: 25, Length: 4},
+			{Type: "text_mention", Offset: 34, Length: 3, User: ana},
+		},
+	}
+
+	parsed := services.ParseMessage(message)
+	if parsed.Command == nil || parsed.Command.Name != "karma" || parsed.Command.Bot != "Test_Karma_Bot" {
+		t.Fatalf("unexpected command %+v", parsed.Command)
+	}
+	if parsed.Command.Args != "🎂 @bob and Ana rock" {
+		t.Errorf("unexpected args %q", parsed.Command.Args)
+	}
+	if len(parsed.Mentions) != 2 {
+		t.Fatalf("expected 2 mentions, got %+v", parsed.Mentions)
+	}
+	if parsed.Mentions[0].Username != "bob" || parsed.Mentions[0].Text != "@bob" {
+		t.Errorf("unexpected mention %+v", parsed.Mentions[0])
+	}
+	if parsed.Mentions[1].User != ana || parsed.Mentions[1].Text != "Ana" {
+		t.Errorf("unexpected text mention %+v", parsed.Mentions[1])
+	}
+}
+
+func TestParseMessageNeedsALeadingCommandEntity(t *testing.T) {
+	cases := []*structs.Message{
+		// A slash without an entity, e.g. a path someone pasted.
+		{Text: "/usr/bin is full"},
+		// A command entity in the middle of the text.
+		{Text: "try /help", Entities: []structs.MessageEntity{{Type: "bot_command", Offset: 4, Length: 5}}},
+		// An entity that runs past the end of the text.
+		{Text: "/help", Entities: []structs.MessageEntity{{Type: "bot_command", Offset: 0, Length: 9}}},
+	}
+
+	for _, message := range cases {
+		if command := services.ParseMessage(message).Command; command != nil {
+			t.Errorf("%q parsed as command %+v", message.Text, command)
+		}
+	}
+}
+
+func TestBotUserIsFetchedOnce(t *testing.T) {
+	api := startFakeBotAPI(t)
+
+	for range 2 {
+		user, err := services.BotUser()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Username != api.Bot.Username {
+			t.Errorf("got @%s, want @%s", user.Username, api.Bot.Username)
+		}
+	}
+	if calls := len(api.Calls("getMe")); calls != 1 {
+		t.Errorf("expected one getMe call, got %d", calls)
+	}
+}
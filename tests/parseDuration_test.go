@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bot/telegram/shared"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	valid := map[string]time.Duration{
+		"30m": 30 * time.Minute,
+		"12h": 12 * time.Hour,
+		"7D":  7 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+	}
+	for text, want := range valid {
+		if got, err := shared.ParseDuration(text); err != nil || got != want {
+			t.Errorf("%q: got (%v, %v), want %v", text, got, err, want)
+		}
+	}
+
+	for _, text := range []string{"", "h", "0d", "-1h", "1.5h", "10s", "7 d", "15251w", "99999999999999999999d"} {
+		if _, err := shared.ParseDuration(text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
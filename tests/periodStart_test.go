@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bot/telegram/shared"
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		at     time.Time
+		months int
+		start  time.Time
+		next   time.Time
+	}{
+		{time.Date(2026, time.October, 17, 15, 4, 0, 0, time.UTC), 1, date(2026, time.October, 1), date(2026, time.November, 1)},
+		{date(2026, time.December, 31), 1, date(2026, time.December, 1), date(2027, time.January, 1)},
+		{date(2026, time.October, 1), 3, date(2026, time.October, 1), date(2027, time.January, 1)},
+		{date(2026, time.August, 31), 3, date(2026, time.July, 1), date(2026, time.October, 1)},
+		{date(2026, time.March, 31), 3, date(2026, time.January, 1), date(2026, time.April, 1)},
+		// Just after midnight in UTC+2 is still the previous month in UTC.
+		{time.Date(2026, time.November, 1, 1, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)), 1, date(2026, time.October, 1), date(2026, time.November, 1)},
+	}
+	for _, tc := range cases {
+		if got := shared.PeriodStart(tc.at, tc.months); !got.Equal(tc.start) {
+			t.Errorf("PeriodStart(%v, %d) = %v, want %v", tc.at, tc.months, got, tc.start)
+		}
+		if got := shared.NextPeriodStart(tc.at, tc.months); !got.Equal(tc.next) {
+			t.Errorf("NextPeriodStart(%v, %d) = %v, want %v", tc.at, tc.months, got, tc.next)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bot/telegram/services"
+	"testing"
+	"time"
+)
+
+func TestParseRestrictionDuration(t *testing.T) {
+	valid := map[string]time.Duration{
+		"":        0,
+		"  ":      0,
+		"7d":      7 * 24 * time.Hour,
+		" 12h ":   12 * time.Hour,
+		"52w":     52 * 7 * 24 * time.Hour,
+		"8760h":   365 * 24 * time.Hour,
+		"525600m": 365 * 24 * time.Hour,
+	}
+	for text, want := range valid {
+		if got, ok := services.ParseRestrictionDuration(text); !ok || got != want {
+			t.Errorf("%q: got (%v, %v), want %v", text, got, ok, want)
+		}
+	}
+
+	// Longer than a year, too big to count and malformed durations all get
+	// the usage message rather than a permanent restriction.
+	for _, text := range []string{"366d", "53w", "15251w", "99999999999999999999d", "7d 2h", "soon"} {
+		if got, ok := services.ParseRestrictionDuration(text); ok {
+			t.Errorf("%q: expected it to be refused, got %v", text, got)
+		}
+	}
+}
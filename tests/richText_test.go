@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bot/telegram/richtext"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMarkdownToHTML(t *testing.T) {
+	cases := map[string]string{
+		"**bold** and *italic* and _also_":     "<b>bold</b> and <i>italic</i> and <i>also</i>",
+		"snake_case_name stays":                "snake_case_name stays",
+		"`a < b` & ~~gone~~":                   "<code>a &lt; b</code> &amp; <s>gone</s>",
+		"[docs](https://example.com/?a=1&b=2)": `<a href="https://example.com/?a=1&amp;b=2">docs</a>`,
+		"[bad](javascript:alert(1))":           "bad",
+		"## Heading":                           "<b>Heading</b>",
+		"- one\n* two\n1. three":               "• one\n• two\n1. three",
+		"> quoted **text**\n> more":            "<blockquote>quoted <b>text</b>\nmore</blockquote>",
+		"```go\nif a < b {}\n```":              `<pre><code class="language-go">if a &lt; b {}</code></pre>`,
+		"**unclosed bold":                      "**unclosed bold",
+		"**bold with *italic* inside**":        "<b>bold with <i>italic</i> inside</b>",
+	}
+
+	for input, want := range cases {
+		if got := richtext.ToHTML(input); got != want {
+			t.Errorf("ToHTML(%q)\n got %q\nwant %q", input, got, want)
+		}
+	}
+}
+
+func TestMarkdownToMarkdownV2(t *testing.T) {
+	got := richtext.ToMarkdownV2("**Total:** 1.5 (approx) - ok!")
+	want := `*Total:* 1\.5 \(approx\) \- ok\!`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitHTMLReopensTags(t *testing.T) {
+	text := "<b>" + strings.Repeat("word ", 30) + "</b>"
+	chunks := richtext.SplitHTML(text, 40)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+
+	for _, chunk := range chunks {
+		if !strings.HasPrefix(chunk, "<b>") || !strings.HasSuffix(chunk, "</b>") {
+			t.Errorf("chunk %q does not carry its own <b> tags", chunk)
+		}
+		visible := strings.NewReplacer("<b>", "", "</b>", "").Replace(chunk)
+		if richtext.UTF16Len(visible) > 40 {
+			t.Errorf("chunk %q is %d UTF-16 units long", chunk, richtext.UTF16Len(visible))
+		}
+	}
+}
+
+func TestSplitCountsUTF16AndKeepsRunesWhole(t *testing.T) {
+	// Each emoji is one rune, four UTF-8 bytes and two UTF-16 code units.
+	text := strings.Repeat("\U0001F382", 10)
+	chunks := richtext.SplitText(text, 5)
+	if len(chunks) != 5 {
+		t.Fatalf("expected 5 chunks of 2 emoji, got %d: %q", len(chunks), chunks)
+	}
+	for _, chunk := range chunks {
+		if !utf8.ValidString(chunk) || richtext.UTF16Len(chunk) > 5 {
+			t.Errorf("bad chunk %q", chunk)
+		}
+	}
+
+	entities := richtext.SplitHTML(strings.Repeat("&amp;", 6), 4)
+	if len(entities) != 2 || entities[0] != "&amp;&amp;&amp;&amp;" {
+		t.Errorf("entities were split badly: %q", entities)
+	}
+}
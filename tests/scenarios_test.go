@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bot/telegram/config"
+	"bot/telegram/services"
+	"bot/telegram/structs"
+	"bot/telegram/tests/fakebotapi"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// scenario drives HandleUpdate against the fake Bot API and a real database.
+// Everything runs in one transaction that is rolled back at the end, so
+// scenarios don't see each other's rows.
+type scenario struct {
+	t   *testing.T
+	api *fakebotapi.Server
+	tx  pgx.Tx
+}
+
+func newScenario(t *testing.T) *scenario {
+	t.Helper()
+
+	pool := openTestDatabase(t)
+	tx, err := pool.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tx.Rollback(context.Background()) })
+
+	return &scenario{t: t, api: startFakeBotAPI(t), tx: tx}
+}
+
+// openTestDatabase needs a Postgres the tests may create a database in. Set
+// TEST_DB_NAME (and TEST_DB_USER, TEST_DB_PASSWORD, TEST_DB_HOST, TEST_DB_PORT
+// as needed) to run the tests that use it; without it they are skipped.
+func openTestDatabase(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dbName := os.Getenv("TEST_DB_NAME")
+	if dbName == "" {
+		t.Skip("TEST_DB_NAME is not set")
+	}
+
+	previous := config.Current
+	config.Current.DBSchema = testEnvOrDefault("TEST_DB_SCHEMA", "postgres")
+	config.Current.DBName = dbName
+	config.Current.DBUser = testEnvOrDefault("TEST_DB_USER", "postgres")
+	config.Current.DBPassword = os.Getenv("TEST_DB_PASSWORD")
+	config.Current.DBHost = testEnvOrDefault("TEST_DB_HOST", "localhost")
+	config.Current.DBPort = testEnvOrDefault("TEST_DB_PORT", "5432")
+	config.Current.DBDefaultName = testEnvOrDefault("TEST_DB_DEFAULT_NAME", "postgres")
+	config.Current.WebAppContextSecret = "test-secret"
+	t.Cleanup(func() { config.Current = previous })
+
+	// GetPool creates the database and the base tables the migrations alter.
+	pool, err := services.GlobalPoolManager.GetPool(dbName)
+	if err != nil {
+		t.Fatalf("connect to the test database: %v", err)
+	}
+
+	migrateTestDatabase(t)
+
+	return pool
+}
+
+func migrateTestDatabase(t *testing.T) {
+	t.Helper()
+
+	env := config.Current
+	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", env.DBUser, env.DBPassword, env.DBHost, env.DBPort, env.DBName)
+
+	m, err := migrate.New("file://../database/migrations", dbURL)
+	if err != nil {
+		t.Fatalf("create migrate instance: %v", err)
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		t.Fatalf("run migrations: %v", err)
+	}
+}
+
+func testEnvOrDefault(key string, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+func (s *scenario) send(update structs.Update) {
+	s.t.Helper()
+	services.HandleUpdate(s.tx, update)
+}
+
+// process runs update through ProcessUpdate, ledger and all, and returns the
+// status it was recorded with.
+func (s *scenario) process(update structs.Update) string {
+	s.t.Helper()
+
+	ctx := context.Background()
+	// Fixture IDs restart with every test run; drop any ledger row one left.
+	if _, err := s.tx.Exec(ctx, `DELETE FROM processed_updates WHERE update_id = $1`, update.UpdateID); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := services.ProcessUpdate(ctx, s.tx, update); err != nil {
+		s.t.Fatalf("process update %d: %v", update.UpdateID, err)
+	}
+
+	var status string
+	if err := s.tx.QueryRow(ctx, `SELECT status FROM processed_updates WHERE update_id = $1`, update.UpdateID).Scan(&status); err != nil {
+		s.t.Fatal(err)
+	}
+
+	return status
+}
+
+func (s *scenario) queryInt(sql string, args ...any) int {
+	s.t.Helper()
+
+	var value int
+	if err := s.tx.QueryRow(context.Background(), sql, args...).Scan(&value); err != nil {
+		s.t.Fatalf("%s: %v", strings.TrimSpace(sql), err)
+	}
+
+	return value
+}
+
+// exec runs a statement in the scenario's transaction, to set up state the
+// bot can't be made to produce quickly.
+func (s *scenario) exec(sql string, args ...any) {
+	s.t.Helper()
+	if _, err := s.tx.Exec(context.Background(), sql, args...); err != nil {
+		s.t.Fatalf("%s: %v", strings.TrimSpace(sql), err)
+	}
+}
+
+// karma returns userID's raw karma in chatID.
+func (s *scenario) karma(chatID int64, userID int64) int {
+	s.t.Helper()
+	return s.queryInt(`SELECT karma FROM users_ranking WHERE group_id = $1 AND user_id = $2`, chatID, userID)
+}
+
+// weightedCents returns userID's weighted karma in chatID in hundredths, so it
+// compares exactly.
+func (s *scenario) weightedCents(chatID int64, userID int64) int {
+	s.t.Helper()
+	return s.queryInt(`SELECT (weighted_karma * 100)::int FROM users_ranking WHERE group_id = $1 AND user_id = $2`, chatID, userID)
+}
+
+// resetCooldowns lets everyone in chatID give karma again right away.
+func (s *scenario) resetCooldowns(chatID int64) {
+	s.t.Helper()
+	s.exec(`UPDATE users_ranking SET last_karma_given = NULL WHERE group_id = $1`, chatID)
+}
+
+// scenarioStep is an update and a text the bot's last message to the chat
+// must contain afterwards.
+type scenarioStep struct {
+	update structs.Update
+	want   string
+}
+
+// run sends each step's update and checks what the bot last sent to chatID.
+func (s *scenario) run(chatID int64, steps ...scenarioStep) {
+	s.t.Helper()
+	for _, step := range steps {
+		s.send(step.update)
+		if text := s.lastSent(chatID).Text(); !strings.Contains(text, step.want) {
+			s.t.Errorf("after %q: got %q, want it to contain %q", updateText(step.update), text, step.want)
+		}
+	}
+}
+
+func updateText(update structs.Update) string {
+	if update.Message == nil {
+		return ""
+	}
+	return update.Message.Text
+}
+
+// lastSent returns the last message sent to chatID, failing when none was.
+func (s *scenario) lastSent(chatID int64) fakebotapi.Call {
+	s.t.Helper()
+
+	calls := s.api.Calls("sendMessage")
+	for i := len(calls) - 1; i >= 0; i-- {
+		if calls[i].ChatID() == chatID {
+			return calls[i]
+		}
+	}
+	s.t.Fatalf("nothing was sent to chat %d", chatID)
+
+	return fakebotapi.Call{}
+}
+
+// buttonData returns the callback data of the button labelled text in a sent
+// message's inline keyboard.
+func buttonData(t *testing.T, call fakebotapi.Call, text string) string {
+	t.Helper()
+
+	markup, _ := call.Params["reply_markup"].(map[string]any)
+	rows, _ := markup["inline_keyboard"].([]any)
+	for _, row := range rows {
+		buttons, _ := row.([]any)
+		for _, button := range buttons {
+			button, _ := button.(map[string]any)
+			if button["text"] == text {
+				data, _ := button["callback_data"].(string)
+				return data
+			}
+		}
+	}
+	t.Fatalf("no %q button in %s", text, call.Raw)
+
+	return ""
+}
+
+func TestScenarioKarmaByReply(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000001)
+	ana := fakebotapi.User(9000000001, "Ana")
+	bob := fakebotapi.User(9000000002, "Bob")
+
+	question := fakebotapi.TextMessage(chat, bob, "Does anyone have a charger?")
+	s.send(question)
+	s.send(fakebotapi.Reply(question, ana, "+1"))
+
+	if karma := s.karma(chat.ID, bob.ID); karma != 1 {
+		t.Errorf("expected Bob to have 1 karma, got %d", karma)
+	}
+	if text := s.lastSent(chat.ID).Text(); !strings.Contains(text, "Bob") || !strings.Contains(text, "1") {
+		t.Errorf("unexpected karma reply %q", text)
+	}
+}
+
+func TestScenarioBirthdayThenDeleteEvent(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000002)
+	admin := fakebotapi.User(9000000003, "Carla")
+	dan := fakebotapi.User(9000000004, "Dan")
+	s.api.SetAdministrators(chat.ID, admin.ID)
+
+	hello := fakebotapi.TextMessage(chat, dan, "hello")
+	s.send(fakebotapi.Reply(hello, admin, "/set_birthday 24-12-1990"))
+
+	eventID := s.queryInt(`SELECT id FROM events WHERE chat_id = $1 AND type = 'birthday'`, chat.ID)
+	if text := s.lastSent(chat.ID).Text(); !strings.Contains(text, fmt.Sprint(eventID)) {
+		t.Errorf("birthday reply %q does not mention event %d", text, eventID)
+	}
+
+	s.send(fakebotapi.TextMessage(chat, admin, "/show_events"))
+	if text := s.lastSent(chat.ID).Text(); !strings.Contains(text, fmt.Sprintf("#%d", eventID)) {
+		t.Errorf("event list %q does not show event %d", text, eventID)
+	}
+
+	s.send(fakebotapi.TextMessage(chat, admin, fmt.Sprintf("/delete_event %d", eventID)))
+	confirmation := s.lastSent(chat.ID)
+	data := buttonData(t, confirmation, "Delete")
+
+	// Someone who isn't an admin can't confirm.
+	sent := structs.Message{MessageID: 1, Chat: chat, Text: confirmation.Text()}
+	s.send(fakebotapi.ButtonPress(sent, dan, data))
+	if remaining := s.queryInt(`SELECT COUNT(*) FROM events WHERE id = $1`, eventID); remaining != 1 {
+		t.Fatalf("a non-admin deleted the event")
+	}
+
+	s.send(fakebotapi.ButtonPress(sent, admin, data))
+	if remaining := s.queryInt(`SELECT COUNT(*) FROM events WHERE id = $1`, eventID); remaining != 0 {
+		t.Errorf("the event was not deleted")
+	}
+	if edits := s.api.Calls("editMessageText"); len(edits) != 1 {
+		t.Errorf("expected the confirmation to be edited once, got %d edits", len(edits))
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bot/telegram/tests/fakebotapi"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestScenarioFailedUpdateRecordsCause(t *testing.T) {
+	s := newScenario(t)
+	chat := fakebotapi.Group(-1009000000013)
+	ana := fakebotapi.User(9000000032, "Ana")
+	bob := fakebotapi.User(9000000033, "Bob")
+
+	fromBob := fakebotapi.TextMessage(chat, bob, "I fixed the build")
+	if status := s.process(fromBob); status != "processed" {
+		t.Fatalf("expected a plain message to be processed, got %q", status)
+	}
+
+	// Without the karma table the karma handler's statement fails and
+	// aborts the update.
+	s.exec(`ALTER TABLE users_ranking RENAME TO users_ranking_moved`)
+	karma := fakebotapi.Reply(fromBob, ana, "+1")
+	if status := s.process(karma); status != "failed" {
+		t.Fatalf("expected the update to fail, got %q", status)
+	}
+
+	var cause string
+	if err := s.tx.QueryRow(context.Background(), `SELECT error FROM processed_updates WHERE update_id = $1`, karma.UpdateID).Scan(&cause); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cause, `"users_ranking" does not exist`) {
+		t.Errorf("expected the failed statement to be recorded, got %q", cause)
+	}
+}